@@ -358,6 +358,18 @@ func (app *BaseApp) SetDeliverStateToCommit() {
 	app.stateToCommit = app.deliverState
 }
 
+// ResetCheckState resets the check state to the latest committed state of the
+// multistore, under the header of the state to commit, as Commit does. Apps
+// committing the multistore themselves rather than through Commit use it to
+// keep CheckTx running against the committed state.
+func (app *BaseApp) ResetCheckState() {
+	var header tmproto.Header
+	if app.stateToCommit != nil {
+		header = app.stateToCommit.ctx.BlockHeader()
+	}
+	app.setCheckState(header)
+}
+
 // Commit implements the ABCI interface. It will commit all state that exists in
 // the deliver state's multi-store and includes the resulting commit ID in the
 // returned abci.ResponseCommit. Commit will set the check state based on the
@@ -225,3 +225,17 @@ func TestHandleQueryStore_NonQueryableMultistore(t *testing.T) {
 type mockNonQueryableMultiStore struct {
 	types.CommitMultiStore
 }
+
+func TestResetCheckState(t *testing.T) {
+	app := setupBaseApp(t)
+	app.InitChain(context.Background(), &abci.RequestInitChain{})
+
+	// the check state keeps its own writes until it is reset
+	app.GetCheckCtx().KVStore(capKey1).Set([]byte("key"), []byte("checked"))
+	app.cms.GetCommitKVStore(capKey1).Set([]byte("key"), []byte("value"))
+	app.cms.Commit(true)
+	require.Equal(t, []byte("checked"), app.GetCheckCtx().KVStore(capKey1).Get([]byte("key")))
+
+	app.ResetCheckState()
+	require.Equal(t, []byte("value"), app.GetCheckCtx().KVStore(capKey1).Get([]byte("key")))
+}
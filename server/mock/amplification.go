@@ -0,0 +1,211 @@
+package mock
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	dbm "github.com/tendermint/tm-db"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Amplification counts the store accesses of a block, from the time it
+// starts being finalized until it is committed. Logical accesses are the
+// reads and writes handlers issue to the stores, db accesses the ones the
+// stores issue to the db backing the app to serve them and commit the block,
+// e.g. the IAVL nodes loaded and saved. Iterators count as a read per item.
+type Amplification struct {
+	LogicalReads  uint64
+	LogicalWrites uint64
+	DBReads       uint64
+	DBWrites      uint64
+}
+
+// ReadAmplification returns the number of db reads per logical read, zero
+// if there was no logical read.
+func (a Amplification) ReadAmplification() float64 {
+	if a.LogicalReads == 0 {
+		return 0
+	}
+	return float64(a.DBReads) / float64(a.LogicalReads)
+}
+
+// WriteAmplification returns the number of db writes per logical write,
+// zero if there was no logical write.
+func (a Amplification) WriteAmplification() float64 {
+	if a.LogicalWrites == 0 {
+		return 0
+	}
+	return float64(a.DBWrites) / float64(a.LogicalWrites)
+}
+
+// LastAmplification returns the store accesses of the last committed block.
+// They are only counted when amplification tracking is enabled.
+func (app *App) LastAmplification() Amplification {
+	return app.lastAmplification
+}
+
+// AssertAmplification returns an error if the read or write amplification of
+// the last committed block of app exceeds maxRead or maxWrite.
+func AssertAmplification(app abci.Application, maxRead, maxWrite float64) error {
+	mockApp, ok := app.(*App)
+	if !ok {
+		return fmt.Errorf("unexpected app type %T", app)
+	}
+	if mockApp.accessCounts == nil {
+		return fmt.Errorf("amplification tracking is not enabled")
+	}
+	a := mockApp.LastAmplification()
+	if r := a.ReadAmplification(); r > maxRead {
+		return fmt.Errorf("read amplification %.2f (%d db reads for %d logical reads) exceeds %.2f", r, a.DBReads, a.LogicalReads, maxRead)
+	}
+	if w := a.WriteAmplification(); w > maxWrite {
+		return fmt.Errorf("write amplification %.2f (%d db writes for %d logical writes) exceeds %.2f", w, a.DBWrites, a.LogicalWrites, maxWrite)
+	}
+	return nil
+}
+
+// accessCounts holds the counters behind an Amplification, updated
+// atomically as txs may execute concurrently.
+type accessCounts struct {
+	logicalReads, logicalWrites uint64
+	dbReads, dbWrites           uint64
+}
+
+func (c *accessCounts) reset() {
+	atomic.StoreUint64(&c.logicalReads, 0)
+	atomic.StoreUint64(&c.logicalWrites, 0)
+	atomic.StoreUint64(&c.dbReads, 0)
+	atomic.StoreUint64(&c.dbWrites, 0)
+}
+
+func (c *accessCounts) snapshot() Amplification {
+	return Amplification{
+		LogicalReads:  atomic.LoadUint64(&c.logicalReads),
+		LogicalWrites: atomic.LoadUint64(&c.logicalWrites),
+		DBReads:       atomic.LoadUint64(&c.dbReads),
+		DBWrites:      atomic.LoadUint64(&c.dbWrites),
+	}
+}
+
+// countingKVStore counts the logical accesses to the store it wraps.
+type countingKVStore struct {
+	sdk.KVStore
+
+	counts *accessCounts
+}
+
+func (s *countingKVStore) Get(key []byte) []byte {
+	atomic.AddUint64(&s.counts.logicalReads, 1)
+	return s.KVStore.Get(key)
+}
+
+func (s *countingKVStore) Has(key []byte) bool {
+	atomic.AddUint64(&s.counts.logicalReads, 1)
+	return s.KVStore.Has(key)
+}
+
+func (s *countingKVStore) Set(key, value []byte) {
+	atomic.AddUint64(&s.counts.logicalWrites, 1)
+	s.KVStore.Set(key, value)
+}
+
+func (s *countingKVStore) Delete(key []byte) {
+	atomic.AddUint64(&s.counts.logicalWrites, 1)
+	s.KVStore.Delete(key)
+}
+
+func (s *countingKVStore) Iterator(start, end []byte) sdk.Iterator {
+	return &countingIterator{Iterator: s.KVStore.Iterator(start, end), reads: &s.counts.logicalReads}
+}
+
+func (s *countingKVStore) ReverseIterator(start, end []byte) sdk.Iterator {
+	return &countingIterator{Iterator: s.KVStore.ReverseIterator(start, end), reads: &s.counts.logicalReads}
+}
+
+// countingIterator counts a read for every item it moves to.
+type countingIterator struct {
+	dbm.Iterator
+
+	reads *uint64
+}
+
+func (it *countingIterator) Next() {
+	atomic.AddUint64(it.reads, 1)
+	it.Iterator.Next()
+}
+
+// countingDB counts the accesses to the db backing the app.
+type countingDB struct {
+	dbm.DB
+
+	counts *accessCounts
+}
+
+func (db *countingDB) Get(key []byte) ([]byte, error) {
+	atomic.AddUint64(&db.counts.dbReads, 1)
+	return db.DB.Get(key)
+}
+
+func (db *countingDB) Has(key []byte) (bool, error) {
+	atomic.AddUint64(&db.counts.dbReads, 1)
+	return db.DB.Has(key)
+}
+
+func (db *countingDB) Set(key, value []byte) error {
+	atomic.AddUint64(&db.counts.dbWrites, 1)
+	return db.DB.Set(key, value)
+}
+
+func (db *countingDB) SetSync(key, value []byte) error {
+	atomic.AddUint64(&db.counts.dbWrites, 1)
+	return db.DB.SetSync(key, value)
+}
+
+func (db *countingDB) Delete(key []byte) error {
+	atomic.AddUint64(&db.counts.dbWrites, 1)
+	return db.DB.Delete(key)
+}
+
+func (db *countingDB) DeleteSync(key []byte) error {
+	atomic.AddUint64(&db.counts.dbWrites, 1)
+	return db.DB.DeleteSync(key)
+}
+
+func (db *countingDB) Iterator(start, end []byte) (dbm.Iterator, error) {
+	it, err := db.DB.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &countingIterator{Iterator: it, reads: &db.counts.dbReads}, nil
+}
+
+func (db *countingDB) ReverseIterator(start, end []byte) (dbm.Iterator, error) {
+	it, err := db.DB.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &countingIterator{Iterator: it, reads: &db.counts.dbReads}, nil
+}
+
+func (db *countingDB) NewBatch() dbm.Batch {
+	return &countingBatch{Batch: db.DB.NewBatch(), writes: &db.counts.dbWrites}
+}
+
+// countingBatch counts a write for every write it batches.
+type countingBatch struct {
+	dbm.Batch
+
+	writes *uint64
+}
+
+func (b *countingBatch) Set(key, value []byte) error {
+	atomic.AddUint64(b.writes, 1)
+	return b.Batch.Set(key, value)
+}
+
+func (b *countingBatch) Delete(key []byte) error {
+	atomic.AddUint64(b.writes, 1)
+	return b.Batch.Delete(key)
+}
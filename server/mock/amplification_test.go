@@ -0,0 +1,38 @@
+package mock
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmplification(t *testing.T) {
+	app := setupTestApp(t, nil, SetAmplificationTracking(true))
+	require.Equal(t, Amplification{}, app.LastAmplification())
+
+	writes := make([][]byte, 50)
+	keys := make([]string, len(writes))
+	for i := range writes {
+		writes[i] = []byte(fmt.Sprintf("key%02d=value", i))
+		keys[i] = fmt.Sprintf("key%02d", i)
+	}
+
+	// every write of a fresh key saves an IAVL leaf and the inner nodes above
+	// it, which the block shares
+	finalizeAndCommit(t, app, 1, writes...)
+	a := app.LastAmplification()
+	require.Equal(t, uint64(len(writes)), a.LogicalWrites)
+	require.Greater(t, a.DBWrites, a.LogicalWrites)
+	require.NoError(t, AssertAmplification(app, 1, 3))
+	require.Error(t, AssertAmplification(app, 1, 1))
+
+	// reads of recently written keys are served by the IAVL node cache
+	finalizeAndCommit(t, app, 2, NewReadTx(keys...))
+	a = app.LastAmplification()
+	require.Equal(t, uint64(len(keys)), a.LogicalReads)
+	require.Zero(t, a.LogicalWrites)
+	require.NoError(t, AssertAmplification(app, 0.1, 0))
+
+	require.EqualError(t, AssertAmplification(setupTestApp(t, nil), 1, 1), "amplification tracking is not enabled")
+}
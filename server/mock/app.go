@@ -1,100 +1,782 @@
 package mock
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/libs/log"
 	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+	"go.opentelemetry.io/otel/trace"
 
 	bam "github.com/cosmos/cosmos-sdk/baseapp"
 	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/store/gaskv"
 	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/cosmos/cosmos-sdk/testutil"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 )
 
+// App is the mock kvstore application returned by NewApp. It embeds BaseApp
+// and keeps the extra bookkeeping backing the "/mock" query paths.
+type App struct {
+	*bam.BaseApp
+
+	db              dbm.DB
+	capKeyMainStore sdk.StoreKey
+
+	// extraStoreKeys are mounted next to the main store, storeLoader
+	// overrides how the mounted stores are loaded from disk.
+	extraStoreNames []string
+	extraStoreKeys  []sdk.StoreKey
+	storeLoader     bam.StoreLoader
+
+	// shadowStoreKey is the store mirroring the main store, only mounted when
+	// shadowStore is set.
+	shadowStore    bool
+	shadowStoreKey sdk.StoreKey
+
+	strictGenesis bool
+
+	txEventIndexSize int
+	txEvents         *txEventIndex
+
+	onCacheEvent func(kind string, height int64)
+
+	dbWrapper func(dbm.DB) dbm.DB
+	inMemory  bool
+
+	kvGasConfigs map[string]storetypes.GasConfig
+	upgradeRules *UpgradeRules
+
+	mergeFunc MergeFunc
+
+	middlewares []HandlerMiddleware
+
+	writeOnce bool
+
+	strictReads bool
+
+	// maxValueSize > 0 caps the size of the values written by txs, see
+	// SetMaxValueSize.
+	maxValueSize int
+
+	flatFee sdk.Coins
+
+	tracer trace.Tracer
+
+	// accessCounts is only set when amplification tracking is enabled.
+	accessCounts      *accessCounts
+	lastAmplification Amplification
+
+	// inFlight is only set when in-flight key tracking is enabled, and
+	// committedKeys holds the keys to release from it on the next commit.
+	inFlight      *inFlightKeys
+	committedKeys [][]byte
+
+	shuffleProposals bool
+
+	// retainWindow is the number of recent blocks consensus is asked to keep,
+	// retainHeight the retain height computed for the block being committed.
+	retainWindow int64
+	retainHeight int64
+
+	blockGasLimit uint64
+
+	recoveryHandlers []bam.RecoveryHandler
+
+	twoPhaseCommit bool
+
+	appHashEvents bool
+
+	txCounting bool
+
+	supplyInvariant bool
+
+	blockStats *blockStatsWriter
+
+	// commitInterval > 1 only persists the block state every commitInterval
+	// blocks, see SetCommitInterval.
+	commitInterval int64
+
+	// txDelay and rateLimiter throttle tx execution in FinalizeBlock.
+	txDelay     time.Duration
+	rateLimiter *txRateLimiter
+
+	pruning *storetypes.PruningOptions
+
+	msgGasLimit uint64
+	partialMsgs bool
+
+	// concurrencyWorkers > 0 runs the txs of a block through the OCC
+	// scheduler, scheduleHook wrapping the execution of every tx.
+	concurrencyWorkers int
+	scheduleHook       func(txIndex int, run func())
+
+	// reexecutionBudget caps how many times a tx is re-executed by the OCC
+	// scheduler unless negative, lastReexecutions accounts for the last block.
+	reexecutionBudget int
+	lastReexecutions  ReexecutionInfo
+
+	rejectNoopTxs bool
+
+	initChainEvents []abci.Event
+	initChainRan    bool
+
+	// blockEvents holds the tx events of the block being finalized until it
+	// is committed, committedEvents the ones of the last committed block,
+	// committed at committedHeight. Queries read the committed events while
+	// Commit replaces them, so eventsMtx guards both.
+	blockEvents     []BlockEvent
+	eventsMtx       sync.RWMutex
+	committedEvents []BlockEvent
+	committedHeight int64
+
+	dedupTxs bool
+
+	refundFailedTxGas bool
+	lastBlockGas      BlockGasInfo
+
+	appHashFunc         AppHashFunc
+	experimentalAppHash []byte
+
+	changeFeeds []*changeFeed
+
+	maxBlockTxs int
+
+	timeSource func() time.Time
+
+	determinismCheck bool
+
+	simpleStores map[string]struct{}
+	flushPolicy  FlushPolicy
+
+	schedulerHints bool
+
+	commitSync *bool
+
+	// lifecycleMtx serializes InitChain, FinalizeBlock and Commit, which all
+	// replace the BaseApp states.
+	lifecycleMtx sync.Mutex
+
+	// pendingBlock is set from the time a block starts being finalized until
+	// it is committed, or until its finalization fails.
+	pendingBlock bool
+
+	// finalizeCtx is the context of the FinalizeBlock call in progress, whose
+	// cancellation interrupts the block.
+	finalizeCtx context.Context
+
+	// tempDir is the root directory of an app resumed from a checkpoint,
+	// removed once the app is closed.
+	tempDir string
+}
+
 // NewApp creates a simple mock kvstore app for testing. It should work
 // similar to a real app. Make sure rootDir is empty before running the test,
 // in order to guarantee consistent results
-func NewApp(rootDir string, logger log.Logger) (abci.Application, error) {
-	db, err := sdk.NewLevelDB("mock", filepath.Join(rootDir, "data"))
-	if err != nil {
+func NewApp(rootDir string, logger log.Logger, opts ...Option) (abci.Application, error) {
+	app := &App{
+		// Capabilities key to access the main KVStore.
+		capKeyMainStore:   sdk.NewKVStoreKey("main"),
+		txEventIndexSize:  DefaultTxEventIndexSize,
+		reexecutionBudget: -1,
+	}
+	for _, opt := range opts {
+		opt(app)
+	}
+	if err := app.checkFlushPolicy(); err != nil {
 		return nil, err
 	}
+	var db dbm.DB = dbm.NewMemDB()
+	if !app.inMemory {
+		var err error
+		if db, err = sdk.NewLevelDB("mock", filepath.Join(rootDir, "data")); err != nil {
+			return nil, err
+		}
+	}
+	app.txEvents = newTxEventIndex(app.txEventIndexSize)
 
-	// Capabilities key to access the main KVStore.
-	capKeyMainStore := sdk.NewKVStoreKey("main")
+	app.db = db
+	if app.dbWrapper != nil {
+		app.db = app.dbWrapper(db)
+	}
+	if app.commitSync != nil {
+		app.db = &syncDB{DB: app.db, sync: *app.commitSync}
+	}
+	if app.accessCounts != nil {
+		app.db = &countingDB{DB: app.db, counts: app.accessCounts}
+	}
 
 	// Create BaseApp.
-	baseApp := bam.NewBaseApp("kvstore", logger, db, decodeTx, nil, &testutil.TestAppOpts{})
+	var baseAppOpts []func(*bam.BaseApp)
+	if app.pruning != nil {
+		baseAppOpts = append(baseAppOpts, bam.SetPruning(*app.pruning))
+	}
+	app.BaseApp = bam.NewBaseApp("kvstore", logger, app.db, decodeTx, nil, &testutil.TestAppOpts{}, baseAppOpts...)
 
 	// Set mounts for BaseApp's MultiStore.
-	baseApp.MountStores(capKeyMainStore)
-
-	baseApp.SetInitChainer(InitChainer(capKeyMainStore))
-	baseApp.SetFinalizeBlocker(func(ctx sdk.Context, req *abci.RequestFinalizeBlock) (*abci.ResponseFinalizeBlock, error) {
-		txResults := []*abci.ExecTxResult{}
-		for _, txbz := range req.Txs {
-			tx, err := decodeTx(txbz)
-			if err != nil {
-				txResults = append(txResults, &abci.ExecTxResult{})
+	for _, name := range app.extraStoreNames {
+		app.extraStoreKeys = append(app.extraStoreKeys, sdk.NewKVStoreKey(name))
+	}
+	if app.shadowStore {
+		app.shadowStoreKey = sdk.NewKVStoreKey(ShadowStoreName)
+		app.extraStoreKeys = append(app.extraStoreKeys, app.shadowStoreKey)
+	}
+	for _, key := range app.storeKeys() {
+		if _, ok := app.simpleStores[key.Name()]; ok {
+			app.MountStore(key, storetypes.StoreTypeDB)
+		} else {
+			app.MountStores(key)
+		}
+	}
+	if app.storeLoader != nil {
+		app.SetStoreLoader(app.storeLoader)
+	}
+
+	app.SetInitChainer(app.initChainer)
+	app.SetFinalizeBlocker(app.finalizeBlocker)
+	app.SetPreCommitHandler(app.preCommitHandler)
+	app.SetAnteHandler(app.anteHandler)
+	app.SetPrepareProposalHandler(app.prepareProposalHandler)
+	if app.maxValueSize > 0 {
+		app.AddRunTxRecoveryHandler(recoverValueSize)
+	}
+	app.AddRunTxRecoveryHandler(app.recoveryHandlers...)
+
+	app.Router().AddRoute(sdk.NewRoute("kvstore", chainMiddlewares(app.kvStoreHandler, app.middlewares)))
+	app.Router().AddRoute(sdk.NewRoute("bank", app.bankHandler))
+	app.Router().AddRoute(sdk.NewRoute("swap", app.swapHandler))
+	app.Router().AddRoute(sdk.NewRoute("read", app.readHandler))
+	app.Router().AddRoute(sdk.NewRoute("delete", app.deleteHandler))
+
+	// Load latest version.
+	if err := app.LoadLatestVersion(); err != nil {
+		return nil, err
+	}
+	if err := app.loadAppVersion(); err != nil {
+		return nil, err
+	}
+	app.setCommittedEvents(nil)
+
+	return app, nil
+}
+
+// NewAppAtHeight opens the mock app stored in rootDir like NewApp, rolled
+// back to the committed height given: the versions above it are discarded,
+// so the app resumes from that height as if the later blocks had never been
+// committed. height must be a committed height of the app.
+func NewAppAtHeight(rootDir string, height int64, logger log.Logger, opts ...Option) (abci.Application, error) {
+	app, err := NewApp(rootDir, logger, opts...)
+	if err != nil {
+		return nil, err
+	}
+	mockApp := app.(*App)
+
+	if latest := mockApp.LastBlockHeight(); height <= 0 || height > latest {
+		mockApp.Close()
+		return nil, fmt.Errorf("cannot roll back to height %d, last committed height is %d", height, latest)
+	}
+	if err := mockApp.CommitMultiStore().RollbackToVersion(height); err != nil {
+		mockApp.Close()
+		return nil, fmt.Errorf("failed to roll back to height %d: %w", height, err)
+	}
+	// reopen the app so BaseApp loads its states from the rolled back height
+	if err := mockApp.Close(); err != nil {
+		return nil, err
+	}
+	return NewApp(rootDir, logger, opts...)
+}
+
+func (app *App) finalizeBlocker(ctx sdk.Context, req *abci.RequestFinalizeBlock) (_ *abci.ResponseFinalizeBlock, err error) {
+	// the deliver state cache has been branched off the commit multistore by
+	// the time the finalize blocker runs
+	app.emitCacheEvent(CacheEventWrap, req.Height)
+	app.pendingBlock = true
+	// no Commit follows a failed block, so it isn't pending anymore
+	defer func() {
+		if err != nil {
+			app.pendingBlock = false
+		}
+	}()
+	start := time.Now()
+	ctx, endBlockSpan := app.startBlockSpan(ctx, req)
+	defer endBlockSpan()
+
+	blockGasMeter := app.newBlockGasMeter()
+	ctx = ctx.WithValue(blockGasMeterKey{}, blockGasMeter)
+	app.lastBlockGas = BlockGasInfo{Limit: app.blockGasLimit}
+	app.lastReexecutions = ReexecutionInfo{Budget: app.reexecutionBudget}
+	if app.accessCounts != nil {
+		app.accessCounts.reset()
+	}
+	if app.rateLimiter != nil {
+		app.rateLimiter.refill()
+	}
+
+	txResults := make([]*abci.ExecTxResult, len(req.Txs))
+	entries := make([]*sdk.DeliverTxEntry, 0, len(req.Txs))
+	seen := make(map[[32]byte]struct{}, len(req.Txs))
+	for i, txbz := range req.Txs {
+		if app.maxBlockTxs > 0 && i >= app.maxBlockTxs {
+			txResults[i] = errorTxResult(ErrBlockTxLimit)
+			continue
+		}
+		tx, err := decodeTx(txbz)
+		if err != nil {
+			txResults[i] = &abci.ExecTxResult{}
+			continue
+		}
+		txHash := sha256.Sum256(txbz)
+		if app.dedupTxs {
+			if _, ok := seen[txHash]; ok {
+				txResults[i] = errorTxResult(ErrDuplicateTx)
 				continue
 			}
-			deliverTxResp := baseApp.DeliverTx(ctx, abci.RequestDeliverTx{
-				Tx: txbz,
-			}, tx, sha256.Sum256(txbz))
-			txResults = append(txResults, &abci.ExecTxResult{
-				Code:      deliverTxResp.Code,
-				Data:      deliverTxResp.Data,
-				Log:       deliverTxResp.Log,
-				Info:      deliverTxResp.Info,
-				GasWanted: deliverTxResp.GasWanted,
-				GasUsed:   deliverTxResp.GasUsed,
-				Events:    deliverTxResp.Events,
-				Codespace: deliverTxResp.Codespace,
-			})
-		}
-		return &abci.ResponseFinalizeBlock{
-			TxResults: txResults,
-		}, nil
-	})
-
-	baseApp.Router().AddRoute(sdk.NewRoute("kvstore", KVStoreHandler(capKeyMainStore)))
+			seen[txHash] = struct{}{}
+		}
+		if app.rateLimiter != nil && !app.rateLimiter.take() {
+			txResults[i] = errorTxResult(ErrRateLimited)
+			continue
+		}
+		entries = append(entries, &sdk.DeliverTxEntry{
+			Request:       abci.RequestDeliverTx{Tx: txbz},
+			SdkTx:         tx,
+			Checksum:      txHash,
+			AbsoluteIndex: i,
+		})
+	}
 
-	// Load latest version.
-	if err := baseApp.LoadLatestVersion(); err != nil {
+	responses, err := app.deliverTxs(ctx, blockGasMeter, entries)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.mergeWrites(ctx); err != nil {
+		return nil, err
+	}
+	app.lastBlockGas.Consumed = blockGasMeter.GasConsumedToLimit()
+	app.blockEvents = nil
+
+	// an interrupted block only reports the txs up to the first one left
+	// unexecuted
+	var blockEvents []abci.Event
+	if app.appHashEvents {
+		lastCommitID := app.LastCommitID()
+		blockEvents = append(blockEvents, abci.Event{
+			Type: EventTypeLastAppHash,
+			Attributes: []abci.EventAttribute{
+				{Key: []byte(AttributeKeyHeight), Value: []byte(strconv.FormatInt(lastCommitID.Version, 10))},
+				{Key: []byte(AttributeKeyAppHash), Value: []byte(fmt.Sprintf("%X", lastCommitID.Hash))},
+			},
+		})
+	}
+	if len(responses) < len(entries) {
+		cut := entries[len(responses)].AbsoluteIndex
+		blockEvents = append(blockEvents, abci.Event{
+			Type: EventTypeInterrupted,
+			Attributes: []abci.EventAttribute{
+				{Key: []byte(AttributeKeyExecuted), Value: []byte(strconv.Itoa(cut))},
+				{Key: []byte(AttributeKeyTotal), Value: []byte(strconv.Itoa(len(req.Txs)))},
+			},
+		})
+		txResults = txResults[:cut]
+		entries = entries[:len(responses)]
+	}
+	var applied uint64
+	minted := sdk.NewCoins()
+	for i, entry := range entries {
+		deliverTxResp := responses[i]
+		if deliverTxResp.Code == 0 {
+			applied++
+			for _, msg := range entry.SdkTx.GetMsgs() {
+				if bTx, ok := msg.(kvBankTx); ok {
+					minted = minted.Add(bTx.coins...)
+				}
+			}
+		}
+		tx, _ := unwrapTx(entry.SdkTx).(kvstoreTx)
+		if len(tx.meta) > 0 {
+			deliverTxResp.Info = string(tx.meta)
+		}
+		txResults[entry.AbsoluteIndex] = &abci.ExecTxResult{
+			Code:      deliverTxResp.Code,
+			Data:      deliverTxResp.Data,
+			Log:       deliverTxResp.Log,
+			Info:      deliverTxResp.Info,
+			GasWanted: deliverTxResp.GasWanted,
+			GasUsed:   deliverTxResp.GasUsed,
+			Events:    deliverTxResp.Events,
+			Codespace: deliverTxResp.Codespace,
+		}
+		app.txEvents.add(entry.Checksum, deliverTxResp.Events)
+		for _, event := range deliverTxResp.Events {
+			app.blockEvents = append(app.blockEvents, BlockEvent{TxIndex: entry.AbsoluteIndex, Event: event})
+		}
+		if app.inFlight != nil {
+			for _, msg := range entry.SdkTx.GetMsgs() {
+				if dTx, ok := msg.(kvstoreTx); ok {
+					app.committedKeys = append(app.committedKeys, dTx.key)
+				}
+			}
+		}
+	}
+	if app.txCounting {
+		if err := app.addTxCount(ctx, applied); err != nil {
+			return nil, err
+		}
+	}
+	if app.supplyInvariant {
+		if err := app.checkSupply(ctx, minted); err != nil {
+			return nil, err
+		}
+	}
+	app.SetDeliverStateToCommit()
+
+	// ResponseFinalizeBlock has no retain height, the one computed here is
+	// reported to consensus by the following Commit.
+	if app.retainWindow > 0 && req.Height > app.retainWindow {
+		app.retainHeight = req.Height - app.retainWindow
+	} else {
+		app.retainHeight = 0
+	}
+
+	res := &abci.ResponseFinalizeBlock{
+		Events:           blockEvents,
+		TxResults:        txResults,
+		ValidatorUpdates: validatorUpdates(txResults),
+	}
+	if app.twoPhaseCommit {
+		// flush the block into the commit multistore so its working hash is
+		// the app hash the following Commit persists
+		app.WriteState()
+		app.emitCacheEvent(CacheEventWrite, req.Height)
+		res.AppHash = app.GetWorkingHash()
+	}
+	if app.blockStats != nil {
+		// the stats are a report, failing to write them doesn't fail the block
+		if err := app.blockStats.write(req.Height, len(txResults), app.lastBlockGas.Consumed, time.Since(start), app.lastReexecutions.Total); err != nil {
+			ctx.Logger().Error("failed to write block stats", "height", req.Height, "err", err)
+		}
+	}
+	return res, nil
+}
+
+// errorTxResult returns the result of a tx rejected with err without being
+// executed.
+func errorTxResult(err error) *abci.ExecTxResult {
+	space, code, log := sdkerrors.ABCIInfo(err, false)
+	return &abci.ExecTxResult{Codespace: space, Code: code, Log: log}
+}
+
+func (app *App) preCommitHandler(ctx sdk.Context) error {
+	// with a two-phase commit the cache has been written in FinalizeBlock
+	if !app.twoPhaseCommit {
+		app.emitCacheEvent(CacheEventWrite, ctx.BlockHeight())
+	}
+	return nil
+}
+
+// InitChain implements the ABCI interface. It waits for a FinalizeBlock or
+// Commit call in progress to return, and fails with ErrBlockPending rather
+// than resetting the states of a block finalized but not committed yet.
+func (app *App) InitChain(ctx context.Context, req *abci.RequestInitChain) (*abci.ResponseInitChain, error) {
+	app.lifecycleMtx.Lock()
+	defer app.lifecycleMtx.Unlock()
+
+	if app.pendingBlock {
+		return nil, sdkerrors.Wrap(ErrBlockPending, "cannot init chain")
+	}
+	return app.BaseApp.InitChain(ctx, req)
+}
+
+// FinalizeBlock stamps the block with the time of the time source set with
+// SetTimeSource when the request leaves the block time unset, then executes
+// it as BaseApp does.
+//
+// Cancelling ctx while the txs of the block execute sequentially interrupts
+// the block: the txs left are not executed, the response only holds the
+// results of the txs before them and carries an EventTypeInterrupted block
+// event. Blocks executed concurrently always run to completion.
+func (app *App) FinalizeBlock(ctx context.Context, req *abci.RequestFinalizeBlock) (*abci.ResponseFinalizeBlock, error) {
+	app.lifecycleMtx.Lock()
+	defer app.lifecycleMtx.Unlock()
+
+	app.finalizeCtx = ctx
+	defer func() { app.finalizeCtx = nil }()
+
+	if app.timeSource != nil && req.Time.IsZero() {
+		stamped := *req
+		stamped.Time = app.timeSource()
+		req = &stamped
+	}
+	return app.BaseApp.FinalizeBlock(ctx, req)
+}
+
+// Commit implements the ABCI interface. It delegates to BaseApp and notifies
+// the cache event observer once the commit multistore has been committed.
+//
+// With a commit interval set, the blocks in between commit boundaries are
+// committed without their state, which the following blocks keep building on
+// until a boundary persists all of it at once.
+//
+// The stores panic when the underlying db fails to persist a version; such
+// panics are returned as errors and the app stays at the last committed height.
+func (app *App) Commit(ctx context.Context) (res *abci.ResponseCommit, err error) {
+	app.lifecycleMtx.Lock()
+	defer app.lifecycleMtx.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, fmt.Errorf("failed to commit block: %v", r)
+		}
+	}()
+
+	if height := app.LastBlockHeight() + 1; app.commitInterval > 1 && height%app.commitInterval != 0 {
+		// leave the deliver state in place for the next block to keep writing
+		// to, and only bump the version of the commit multistore, which keeps
+		// the state of the last boundary
+		app.CommitMultiStore().Commit(true)
+		app.ResetCheckState()
+		res, err = app.afterCommit(&abci.ResponseCommit{})
+		if err != nil {
+			return nil, err
+		}
+		// the blocks since the last boundary are not persisted, consensus must
+		// keep them around to replay
+		if boundary := height - height%app.commitInterval; res.RetainHeight > boundary {
+			res.RetainHeight = boundary
+		}
+		return res, nil
+	}
+
+	res, err = app.BaseApp.Commit(ctx)
+	if err != nil {
 		return nil, err
 	}
+	return app.afterCommit(res)
+}
 
-	return baseApp, nil
+// afterCommit updates the bookkeeping of the app once a block is committed,
+// whether its state was persisted or not.
+func (app *App) afterCommit(res *abci.ResponseCommit) (*abci.ResponseCommit, error) {
+	if app.accessCounts != nil {
+		app.lastAmplification = app.accessCounts.snapshot()
+	}
+	if app.inFlight != nil {
+		app.inFlight.release(app.committedKeys...)
+		app.committedKeys = nil
+	}
+	app.setCommittedEvents(app.blockEvents)
+	app.blockEvents = nil
+	app.pendingBlock = false
+	if app.retainWindow > 0 {
+		res.RetainHeight = app.retainHeight
+	}
+	if err := app.loadAppVersion(); err != nil {
+		return nil, err
+	}
+	app.computeExperimentalAppHash()
+	app.emitCacheEvent(CacheEventCommit, app.LastBlockHeight())
+	return res, nil
+}
+
+// setCommittedEvents records events as the ones of the last committed block.
+func (app *App) setCommittedEvents(events []BlockEvent) {
+	app.eventsMtx.Lock()
+	defer app.eventsMtx.Unlock()
+
+	app.committedEvents = events
+	app.committedHeight = app.LastBlockHeight()
+}
+
+// Close releases the db backing the app, after which the app can be
+// reopened with NewApp on the same root directory. Closing an app returned
+// by ResumeApp also removes its temporary root directory.
+func (app *App) Close() error {
+	app.lifecycleMtx.Lock()
+	defer app.lifecycleMtx.Unlock()
+
+	for _, feed := range app.changeFeeds {
+		feed.close()
+	}
+	err := app.db.Close()
+	if app.tempDir != "" {
+		os.RemoveAll(app.tempDir)
+	}
+	return err
+}
+
+func (app *App) emitCacheEvent(kind string, height int64) {
+	if app.onCacheEvent != nil {
+		app.onCacheEvent(kind, height)
+	}
+}
+
+// ReservedKeyPrefix prefixes the keys holding the app's own bookkeeping. Txs
+// writing a key with this prefix are rejected with ErrReservedKey.
+const ReservedKeyPrefix = "__sys__"
+
+// GenesisKey is the reserved key InitChain stores the raw app state under.
+const GenesisKey = ReservedKeyPrefix + "genesis"
+
+// TimeKey is a special key: whatever value a tx sets for it, the handler
+// stores the current block time instead, formatted as RFC3339Nano.
+const TimeKey = "__time__"
+
+// TxIndexKey is a special key: whatever value a tx sets for it, the handler
+// stores the position of the tx in its block instead.
+const TxIndexKey = "__txindex__"
+
+// TxIndex returns the position in its block of the tx being executed with
+// ctx. It is set for every tx of FinalizeBlock, sequential or concurrent.
+func TxIndex(ctx sdk.Context) int {
+	return ctx.TxIndex()
+}
+
+// PanicKey is a special key: the handler writes the value and then panics
+// with it, so tests can exercise the recovery of panicking txs.
+const PanicKey = "__panic__"
+
+// isSpecialKey reports whether key is one of the special keys the kvstore
+// handler gives a meaning to.
+func isSpecialKey(key []byte) bool {
+	switch string(key) {
+	case TimeKey, TxIndexKey, PanicKey, UpgradeKey, ValUpdateKey, BlockGasKey:
+		return true
+	}
+	return false
 }
 
 // KVStoreHandler is a simple handler that takes kvstoreTx and writes
 // them to the db
 func KVStoreHandler(storeKey sdk.StoreKey) sdk.Handler {
-	return func(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
-		dTx, ok := msg.(kvstoreTx)
-		if !ok {
-			return nil, errors.New("KVStoreHandler should only receive kvstoreTx")
-		}
+	return (&App{capKeyMainStore: storeKey}).kvStoreHandler
+}
+
+func (app *App) kvStoreHandler(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+	dTx, ok := msg.(kvstoreTx)
+	if !ok {
+		return nil, errors.New("KVStoreHandler should only receive kvstoreTx")
+	}
+	ctx.Logger().Debug("executing kvstore tx", "msgs", len(dTx.GetMsgs()))
 
-		// tx is already unmarshalled
-		key := dTx.key
-		value := dTx.value
+	if app.msgGasLimit > 0 || app.partialMsgs {
+		return app.setKVIsolated(ctx, dTx)
+	}
+	return app.setKV(ctx, dTx)
+}
+
+// setKV applies a single kvstoreTx msg to the main store.
+func (app *App) setKV(ctx sdk.Context, dTx kvstoreTx) (*sdk.Result, error) {
+	ctx = ctx.WithEventManager(sdk.NewEventManager())
+
+	// tx is already unmarshalled
+	key := dTx.key
+	value := dTx.value
+	if bytes.HasPrefix(key, []byte(ReservedKeyPrefix)) {
+		return nil, sdkerrors.Wrapf(ErrReservedKey, "key %s", key)
+	}
+	if app.upgraded(ctx) && app.upgradeRules.MaxValueSize > 0 && len(value) > app.upgradeRules.MaxValueSize {
+		return nil, sdkerrors.Wrapf(ErrValueTooLarge, "value of %s is %d bytes, max %d", key, len(value), app.upgradeRules.MaxValueSize)
+	}
+	switch string(key) {
+	case TimeKey:
+		value = []byte(ctx.BlockTime().UTC().Format(time.RFC3339Nano))
+	case TxIndexKey:
+		value = []byte(strconv.Itoa(TxIndex(ctx)))
+	case UpgradeKey:
+		if err := app.validateUpgrade(ctx, value); err != nil {
+			return nil, err
+		}
+		key = []byte(AppVersionKey)
+	case ValUpdateKey:
+		var err error
+		if key, value, err = parseValUpdate(value); err != nil {
+			return nil, err
+		}
+	case BlockGasKey:
+		value = []byte("unlimited")
+		if meter := BlockGasMeter(ctx); meter != nil && meter.Limit() > 0 {
+			value = []byte(strconv.FormatUint(meter.Limit()-meter.GasConsumedToLimit(), 10))
+		}
+	}
 
-		store := ctx.KVStore(storeKey)
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	if !isSpecialKey(dTx.key) {
+		if err := app.checkWriteOnce(store, key); err != nil {
+			return nil, err
+		}
+	}
+	if app.mergeFunc != nil && !isSpecialKey(dTx.key) {
+		// merged into the key once the block is executed; the staging key is
+		// reserved, so the size limit is checked here instead
+		if err := checkValueSize(app.maxValueSize, key, value); err != nil {
+			return nil, err
+		}
+		store.Set(mergeKey(TxIndex(ctx), key), value)
+	} else {
 		store.Set(key, value)
+	}
+	if string(key) == PanicKey {
+		panic(string(value))
+	}
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			EventTypeKVStore,
+			sdk.NewAttribute(AttributeKeyKey, string(key)),
+			sdk.NewAttribute(AttributeKeyValue, string(value)),
+		),
+	)
 
-		return &sdk.Result{
-			Log: fmt.Sprintf("set %s=%s", key, value),
-		}, nil
+	return &sdk.Result{
+		Log:    fmt.Sprintf("set %s=%s", key, value),
+		Events: ctx.EventManager().ABCIEvents(),
+	}, nil
+}
+
+// storeKeys returns the keys of all stores mounted on the app, the main store
+// first.
+func (app *App) storeKeys() []sdk.StoreKey {
+	return append([]sdk.StoreKey{app.capKeyMainStore}, app.extraStoreKeys...)
+}
+
+// kvStore fetches the KVStore for key from the context's multistore, metered
+// with the gas config configured for that store, or the upgraded one once
+// the upgrade height is reached.
+func (app *App) kvStore(ctx sdk.Context, key sdk.StoreKey) sdk.KVStore {
+	gasConfig, ok := app.kvGasConfigs[key.Name()]
+	if !ok {
+		gasConfig = storetypes.KVGasConfig()
+	}
+	if app.upgraded(ctx) && app.upgradeRules.GasConfig != nil {
+		gasConfig = *app.upgradeRules.GasConfig
+	}
+	store := gaskv.NewStore(ctx.MultiStore().GetKVStore(key), ctx.GasMeter(), gasConfig)
+	var kvStore sdk.KVStore = store
+	if app.accessCounts != nil {
+		kvStore = &countingKVStore{KVStore: kvStore, counts: app.accessCounts}
+	}
+	if key == app.capKeyMainStore {
+		kvStore = app.withShadow(ctx, kvStore)
+		if app.maxValueSize > 0 {
+			kvStore = &sizeLimitKVStore{KVStore: kvStore, max: app.maxValueSize}
+		}
 	}
+	return kvStore
 }
 
 // basic KV structure
@@ -105,28 +787,121 @@ type KV struct {
 
 // What Genesis JSON is formatted as
 type GenesisJSON struct {
-	Values []KV `json:"values"`
+	Values     []KV   `json:"values"`
+	AppVersion uint64 `json:"app_version,omitempty"`
 }
 
 // InitChainer returns a function that can initialize the chain
 // with key/value pairs
 func InitChainer(key sdk.StoreKey) func(sdk.Context, abci.RequestInitChain) abci.ResponseInitChain {
-	return func(ctx sdk.Context, req abci.RequestInitChain) abci.ResponseInitChain {
-		stateJSON := req.AppStateBytes
+	return (&App{capKeyMainStore: key}).initChainer
+}
 
-		genesisState := new(GenesisJSON)
-		err := json.Unmarshal(stateJSON, genesisState)
-		if err != nil {
-			panic(err) // TODO https://github.com/cosmos/cosmos-sdk/issues/468
-			// return sdk.ErrGenesisParse("").TraceCause(err, "")
+func (app *App) initChainer(ctx sdk.Context, req abci.RequestInitChain) abci.ResponseInitChain {
+	genesisState, err := validateGenesis(req.AppStateBytes, app.strictGenesis, app.maxValueSize)
+	if err != nil {
+		panic(err) // TODO https://github.com/cosmos/cosmos-sdk/issues/468
+		// return sdk.ErrGenesisParse("").TraceCause(err, "")
+	}
+
+	// genesis runs without a gas budget: values are written through the same
+	// metered store as txs, but against an infinite meter so no genesis
+	// entry can run out of gas whatever the configured costs
+	ctx = ctx.WithGasMeter(sdk.NewInfiniteGasMeter(1, 1))
+
+	em := sdk.NewEventManager()
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	for _, val := range genesisState.Values {
+		store.Set([]byte(val.Key), []byte(val.Value))
+		em.EmitEvent(sdk.NewEvent(
+			EventTypeInitChain,
+			sdk.NewAttribute(AttributeKeyKey, val.Key),
+			sdk.NewAttribute(AttributeKeyValue, val.Value),
+		))
+	}
+	if app.supplyInvariant {
+		if err := app.initSupply(store); err != nil {
+			panic(err)
 		}
+	}
+	// ResponseInitChain carries no events, keep them around instead; BaseApp
+	// runs the init chainer once per state, each run emitting the same events
+	app.initChainEvents = em.ABCIEvents()
+	// keep the raw app state around so it can be queried back verbatim
+	store.Set([]byte(GenesisKey), req.AppStateBytes)
+	if genesisState.AppVersion > 0 {
+		store.Set([]byte(AppVersionKey), []byte(strconv.FormatUint(genesisState.AppVersion, 10)))
+		// the standalone InitChainer runs without a BaseApp to report it
+		if app.BaseApp != nil {
+			app.SetProtocolVersion(genesisState.AppVersion)
+		}
+	}
+	app.initChainRan = true
+	return abci.ResponseInitChain{}
+}
+
+// Initialized reports whether InitChain has run on the app: either it ran
+// since the app was opened, its genesis state possibly not committed yet, or
+// the committed state holds the GenesisKey marker it writes.
+func (app *App) Initialized() bool {
+	return app.initChainRan || app.CommitMultiStore().GetKVStore(app.capKeyMainStore).Has([]byte(GenesisKey))
+}
+
+// InitChainEvents returns the events emitted while applying the genesis
+// state, one EventTypeInitChain event per genesis value.
+func (app *App) InitChainEvents() []abci.Event {
+	return app.initChainEvents
+}
 
-		for _, val := range genesisState.Values {
-			store := ctx.KVStore(key)
-			store.Set([]byte(val.Key), []byte(val.Value))
+// decodeGenesis parses the app state into a GenesisJSON. In strict mode any
+// field not part of the GenesisJSON shape is rejected instead of ignored.
+func decodeGenesis(stateJSON []byte, strict bool) (*GenesisJSON, error) {
+	genesisState := new(GenesisJSON)
+	if !strict {
+		if err := json.Unmarshal(stateJSON, genesisState); err != nil {
+			return nil, err
+		}
+		return genesisState, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(stateJSON))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(genesisState); err != nil {
+		return nil, fmt.Errorf("invalid genesis app state: %w", err)
+	}
+	return genesisState, nil
+}
+
+// validateGenesis decodes the app state and checks that every value has a
+// non-empty key that is not reserved. A reserved key is rejected with
+// ErrReservedKey, and a value longer than maxValueSize bytes, when it is set,
+// with ErrValueTooLarge, as they are when a tx writes them. Values repeating
+// a key are applied in order, the last one winning.
+func validateGenesis(stateJSON []byte, strict bool, maxValueSize int) (*GenesisJSON, error) {
+	genesisState, err := decodeGenesis(stateJSON, strict)
+	if err != nil {
+		return nil, err
+	}
+	for i, val := range genesisState.Values {
+		if len(val.Key) == 0 {
+			return nil, fmt.Errorf("genesis value %d has an empty key", i)
+		}
+		if strings.HasPrefix(val.Key, ReservedKeyPrefix) {
+			return nil, sdkerrors.Wrapf(ErrReservedKey, "genesis value %d has key %q", i, val.Key)
+		}
+		if maxValueSize > 0 && len(val.Value) > maxValueSize {
+			return nil, sdkerrors.Wrapf(ErrValueTooLarge, "genesis value %d is %d bytes, max %d", i, len(val.Value), maxValueSize)
 		}
-		return abci.ResponseInitChain{}
 	}
+	return genesisState, nil
+}
+
+// ValidateInitChain runs the validation InitChain applies to the genesis app
+// state of req without writing anything, returning the error InitChain would
+// panic with if it is invalid.
+func (app *App) ValidateInitChain(req abci.RequestInitChain) error {
+	_, err := validateGenesis(req.AppStateBytes, app.strictGenesis, app.maxValueSize)
+	return err
 }
 
 // AppGenState can be passed into InitCmd, returns a static string of a few
@@ -155,6 +930,34 @@ func AppGenStateEmpty(_ *codec.LegacyAmino, _ types.GenesisDoc, _ []json.RawMess
 	return
 }
 
+// AppGenStateFromFile returns a function with the signature of AppGenState
+// reading the app state from the JSON file at path, which is either the app
+// state itself or a genesis document holding it under "app_state". The app
+// state is validated as InitChain would, so an invalid file is reported
+// before the chain is initialized with it.
+func AppGenStateFromFile(path string) func(*codec.LegacyAmino, types.GenesisDoc, []json.RawMessage) (json.RawMessage, error) {
+	return func(_ *codec.LegacyAmino, _ types.GenesisDoc, _ []json.RawMessage) (json.RawMessage, error) {
+		bz, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read genesis file %q: %w", path, err)
+		}
+		var doc struct {
+			AppState json.RawMessage `json:"app_state"`
+		}
+		if err := json.Unmarshal(bz, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode genesis file %q: %w", path, err)
+		}
+		appState := json.RawMessage(bz)
+		if len(doc.AppState) > 0 {
+			appState = doc.AppState
+		}
+		if _, err := validateGenesis(appState, false, 0); err != nil {
+			return nil, fmt.Errorf("invalid genesis file %q: %w", path, err)
+		}
+		return appState, nil
+	}
+}
+
 // Manually write the handlers for this custom message
 type MsgServer interface {
 	Test(ctx context.Context, msg *kvstoreTx) (*sdk.Result, error)
@@ -2,11 +2,23 @@ package mock
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
 	"github.com/tendermint/tendermint/types"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 )
 
 // TestInitApp makes sure we can initialize this thing without an error
@@ -81,3 +93,699 @@ func TestDeliverTx(t *testing.T) {
 	require.Equal(t, uint32(0), qres.Code, qres.Log)
 	require.Equal(t, []byte(value), qres.Value)
 }
+
+func TestStrictGenesis(t *testing.T) {
+	// "valeus" is a typo of "values"
+	typo := []byte(`{"valeus": [{"key": "foo", "value": "bar"}]}`)
+
+	_, err := decodeGenesis(typo, false)
+	require.NoError(t, err, "lenient decoding should ignore unknown fields")
+
+	_, err = decodeGenesis(typo, true)
+	require.Error(t, err)
+
+	_, err = decodeGenesis([]byte(`{"values": [{"key": "foo", "valu": "bar"}]}`), true)
+	require.Error(t, err, "unknown fields in nested values should be rejected as well")
+
+	appState, err := AppGenState(nil, types.GenesisDoc{}, nil)
+	require.NoError(t, err)
+	_, err = decodeGenesis(appState, true)
+	require.NoError(t, err)
+
+	app, err := NewApp(t.TempDir(), log.NewNopLogger(), SetStrictGenesis(true))
+	require.NoError(t, err)
+	require.Panics(t, func() {
+		app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: typo}) //nolint:errcheck
+	})
+}
+
+// setupTestApp returns a fresh app on a temporary directory which has already
+// gone through InitChain with the given genesis values.
+func setupTestApp(t *testing.T, genesis []KV, opts ...Option) *App {
+	t.Helper()
+
+	app, err := NewApp(t.TempDir(), log.NewNopLogger(), opts...)
+	require.NoError(t, err)
+
+	appState, err := json.Marshal(GenesisJSON{Values: genesis})
+	require.NoError(t, err)
+	_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: appState})
+	require.NoError(t, err)
+
+	return app.(*App)
+}
+
+// benchmarkBlocks measures running txs as one block on a fresh app built with
+// opts. A first block is run before the timer starts so that one-off costs,
+// like creating the stores, are not measured.
+func benchmarkBlocks(b *testing.B, txs [][]byte, opts ...Option) {
+	b.Helper()
+
+	app, err := NewApp(b.TempDir(), log.NewNopLogger(), opts...)
+	require.NoError(b, err)
+	_, err = RunBlocks(app, [][][]byte{txs})
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := RunBlocks(app, [][][]byte{txs}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// finalizeAndCommit executes txs as the block at the given height and commits it.
+func finalizeAndCommit(t *testing.T, app abci.Application, height int64, txs ...[]byte) *abci.ResponseFinalizeBlock {
+	t.Helper()
+
+	goCtx := context.Background()
+	resp, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: height, Txs: txs})
+	require.NoError(t, err)
+	_, err = app.Commit(goCtx)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestKVGasConfig(t *testing.T) {
+	tx := []byte("key=value")
+	keyLen, valueLen := uint64(len("key")), uint64(len("value"))
+
+	defaultCfg := storetypes.KVGasConfig()
+	writeHeavy := storetypes.GasConfig{ReadCostFlat: 1, ReadCostPerByte: 1, WriteCostFlat: 100000, WriteCostPerByte: 1000}
+	readHeavy := storetypes.GasConfig{ReadCostFlat: 100000, ReadCostPerByte: 1000, WriteCostFlat: 1, WriteCostPerByte: 1}
+
+	for name, tc := range map[string]struct {
+		opts []Option
+		cfg  storetypes.GasConfig
+	}{
+		"default":     {nil, defaultCfg},
+		"write heavy": {[]Option{SetKVGasConfig("main", writeHeavy)}, writeHeavy},
+		"read heavy":  {[]Option{SetKVGasConfig("main", readHeavy)}, readHeavy},
+		"other store": {[]Option{SetKVGasConfig("other", writeHeavy)}, defaultCfg},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, tc.opts...)
+			resp := finalizeAndCommit(t, app, 1, tx, tx)
+
+			// the handler only writes, so only write costs are charged, and each
+			// tx is metered independently of the previous ones
+			expected := tc.cfg.WriteCostFlat + tc.cfg.WriteCostPerByte*(keyLen+valueLen)
+			for _, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, res.Log)
+				require.Equal(t, int64(expected), res.GasUsed)
+			}
+		})
+	}
+}
+
+func TestRetainWindow(t *testing.T) {
+	const window = 3
+	goCtx := context.Background()
+
+	for name, tc := range map[string]struct {
+		opts     []Option
+		expected []int64
+	}{
+		"disabled": {nil, []int64{0, 0, 0, 0, 0, 0}},
+		"window":   {[]Option{SetRetainWindow(window)}, []int64{0, 0, 0, 1, 2, 3}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, tc.opts...)
+
+			for i, expected := range tc.expected {
+				height := int64(i + 1)
+				_, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: height})
+				require.NoError(t, err)
+				res, err := app.Commit(goCtx)
+				require.NoError(t, err)
+				require.Equal(t, expected, res.RetainHeight, "height %d", height)
+			}
+		})
+	}
+}
+
+func TestRecoveryHandler(t *testing.T) {
+	var recovered []interface{}
+	handler := func(recoveryObj interface{}) error {
+		recovered = append(recovered, recoveryObj)
+		return sdkerrors.Wrapf(sdkerrors.ErrInvalidRequest, "recovered: %v", recoveryObj)
+	}
+	app := setupTestApp(t, nil, SetRecoveryHandler(handler))
+
+	resp := finalizeAndCommit(t, app, 1, []byte(PanicKey+"=boom"), []byte("key=value"))
+	require.Equal(t, []interface{}{"boom"}, recovered)
+
+	res := resp.TxResults[0]
+	require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), res.Code, res.Log)
+	require.Equal(t, sdkerrors.ErrInvalidRequest.Codespace(), res.Codespace)
+	require.Equal(t, uint32(0), resp.TxResults[1].Code, resp.TxResults[1].Log)
+
+	// the write of the panicking tx is discarded, the next tx still applies
+	require.Nil(t, queryStore(t, app, "main", PanicKey, 1).Value)
+	require.Equal(t, []byte("value"), queryStore(t, app, "main", "key", 1).Value)
+}
+
+func TestTwoPhaseCommit(t *testing.T) {
+	goCtx := context.Background()
+	txs := [][]byte{[]byte("a=1"), []byte("b=2")}
+
+	app := setupTestApp(t, nil, SetTwoPhaseCommit(true))
+	reference := setupTestApp(t, nil)
+
+	for height := int64(1); height <= 3; height++ {
+		info, err := app.Info(goCtx, &abci.RequestInfo{})
+		require.NoError(t, err)
+
+		resp, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: height, Txs: txs})
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.AppHash)
+		if height == 1 {
+			require.NotEqual(t, info.LastBlockAppHash, resp.AppHash)
+		}
+
+		// nothing is persisted until Commit
+		require.Equal(t, height-1, app.LastBlockHeight())
+
+		_, err = app.Commit(goCtx)
+		require.NoError(t, err)
+		info, err = app.Info(goCtx, &abci.RequestInfo{})
+		require.NoError(t, err)
+		require.Equal(t, resp.AppHash, info.LastBlockAppHash, "height %d", height)
+
+		// the tentative hash matches a regular commit of the same block
+		finalizeAndCommit(t, reference, height, txs...)
+		require.Equal(t, reference.LastCommitID().Hash, info.LastBlockAppHash, "height %d", height)
+	}
+}
+
+func TestCommitInterval(t *testing.T) {
+	stake := func(amount int64) []byte { return NewBankTx("x", sdk.NewCoins(sdk.NewInt64Coin("stake", amount))) }
+	app := setupTestApp(t, []KV{{"g", "genesis"}}, SetCommitInterval(3))
+	reference := setupTestApp(t, []KV{{"g", "genesis"}})
+
+	for height := int64(1); height <= 4; height++ {
+		resp := finalizeAndCommit(t, app, height, stake(1))
+		require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+		finalizeAndCommit(t, reference, height, stake(1))
+		require.Equal(t, height, app.LastBlockHeight())
+	}
+
+	// the heights before the boundary are committed without their state
+	for _, height := range []int64{1, 2} {
+		require.Nil(t, queryStore(t, app, "main", "g", height).Value, "height %d", height)
+		require.Nil(t, queryStore(t, app, "main", BalanceKeyPrefix+"x", height).Value, "height %d", height)
+	}
+
+	// the boundary persists the writes of every block since the last one,
+	// each block having built on the state left by the previous
+	require.Equal(t, []byte("genesis"), queryStore(t, app, "main", "g", 3).Value)
+	require.Equal(t, []byte("3stake"), queryStore(t, app, "main", BalanceKeyPrefix+"x", 3).Value)
+	require.Equal(t, queryStore(t, reference, "main", BalanceKeyPrefix+"x", 3).Value, queryStore(t, app, "main", BalanceKeyPrefix+"x", 3).Value)
+
+	// the block after it is pending again
+	require.Equal(t, []byte("3stake"), queryStore(t, app, "main", BalanceKeyPrefix+"x", 4).Value)
+
+	for height := int64(5); height <= 6; height++ {
+		finalizeAndCommit(t, app, height, stake(1))
+		finalizeAndCommit(t, reference, height, stake(1))
+	}
+	require.Equal(t, []byte("6stake"), queryStore(t, app, "main", BalanceKeyPrefix+"x", 6).Value)
+	require.NoError(t, AssertStateEqual(app, reference))
+}
+
+func TestCommitIntervalBookkeeping(t *testing.T) {
+	goCtx := context.Background()
+	app := setupTestApp(t, nil, SetCommitInterval(3), SetInFlightKeyTracking(true), SetRetainWindow(1))
+
+	require.Equal(t, uint32(0), checkTx(t, app, []byte("nonce=1")).Code)
+	for height, expected := range []int64{0, 0, 2, 3, 3} {
+		resp, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: int64(height + 1), Txs: [][]byte{[]byte("nonce=1")}})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+		_, retainHeight, err := CommitBlock(app)
+		require.NoError(t, err)
+
+		// a commit between boundaries completes the block all the same
+		require.Nil(t, app.PendingWrites())
+		res := checkTx(t, app, []byte("nonce=2"))
+		require.Equal(t, uint32(0), res.Code, res.Log)
+		res.ExpireTxHandler()
+
+		// but the retain height never goes past the last boundary
+		require.Equal(t, expected, retainHeight, "height %d", height+1)
+	}
+}
+
+func BenchmarkCommitInterval(b *testing.B) {
+	txs := GenWorkload(WorkloadSpec{Txs: 100, Keys: 1000, Seed: 1})
+	for _, interval := range []int64{1, 10} {
+		b.Run(fmt.Sprintf("interval=%d", interval), func(b *testing.B) {
+			benchmarkBlocks(b, txs, SetCommitInterval(interval))
+		})
+	}
+}
+
+func TestEmptyValue(t *testing.T) {
+	app := setupTestApp(t, []KV{{"g", ""}})
+	resp := finalizeAndCommit(t, app, 1, []byte("a="), NewReadTx("a", "g", "missing"))
+	for i, res := range resp.TxResults {
+		require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+	}
+
+	// an empty value is stored, not mistaken for a delete
+	store := app.CommitMultiStore().GetKVStore(app.capKeyMainStore)
+	for _, key := range []string{"a", "g"} {
+		require.True(t, store.Has([]byte(key)), key)
+		require.Equal(t, []byte{}, store.Get([]byte(key)), key)
+		require.Equal(t, []byte{}, queryStore(t, app, "main", key, 1).Value, key)
+	}
+	require.False(t, store.Has([]byte("missing")))
+	require.Nil(t, queryStore(t, app, "main", "missing", 1).Value)
+
+	// reads tell an empty value from an absent key
+	var found []string
+	for _, event := range resp.TxResults[1].Events {
+		if event.Type == EventTypeRead {
+			require.Empty(t, event.Attributes[1].Value)
+			require.Equal(t, AttributeKeyFound, string(event.Attributes[2].Key))
+			found = append(found, string(event.Attributes[2].Value))
+		}
+	}
+	require.Equal(t, []string{"true", "true", "false"}, found)
+
+	// an empty value wins over the default of a kv query
+	bz, err := json.Marshal(QueryKVParams{Key: []byte("a"), Default: []byte("fallback")})
+	require.NoError(t, err)
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/kv", Data: bz})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, []byte{}, qres.Value)
+	require.Empty(t, qres.Info)
+
+	qres, err = app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/size", Data: []byte("a")})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, []byte("0"), qres.Value)
+
+	// deleting it makes the key absent
+	finalizeAndCommit(t, app, 2, NewDeleteTx("a"))
+	require.False(t, app.CommitMultiStore().GetKVStore(app.capKeyMainStore).Has([]byte("a")))
+	require.Nil(t, queryStore(t, app, "main", "a", 2).Value)
+}
+
+func TestAppHashEvents(t *testing.T) {
+	app := setupTestApp(t, nil, SetAppHashEvents(true))
+
+	var lastHash []byte
+	for height := int64(1); height <= 4; height++ {
+		resp := finalizeAndCommit(t, app, height, []byte(fmt.Sprintf("a=%d", height)))
+		require.Len(t, resp.Events, 1)
+		event := resp.Events[0]
+		require.Equal(t, EventTypeLastAppHash, event.Type)
+		require.Equal(t, AttributeKeyHeight, string(event.Attributes[0].Key))
+		require.Equal(t, strconv.FormatInt(height-1, 10), string(event.Attributes[0].Value))
+		require.Equal(t, AttributeKeyAppHash, string(event.Attributes[1].Key))
+		if height > 1 {
+			require.Equal(t, fmt.Sprintf("%X", lastHash), string(event.Attributes[1].Value), "height %d", height)
+		}
+		lastHash = app.LastCommitID().Hash
+	}
+
+	resp := finalizeAndCommit(t, setupTestApp(t, nil), 1, []byte("a=1"))
+	require.Empty(t, resp.Events)
+}
+
+func TestInitChainDuringFinalizeBlock(t *testing.T) {
+	goCtx := context.Background()
+	// the lifecycle events in the order they happen, the cache events being
+	// emitted with the lifecycle lock held
+	events := make(chan string, 8)
+	started, release := make(chan struct{}), make(chan struct{})
+	app := setupTestApp(t, nil, SetOnCacheEvent(func(kind string, height int64) {
+		if kind == CacheEventWrap && height == 1 {
+			close(started)
+			<-release
+		}
+		events <- kind
+	}))
+
+	finalized := make(chan error, 1)
+	go func() {
+		_, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: 1, Txs: [][]byte{[]byte("a=1")}})
+		finalized <- err
+	}()
+	<-started
+
+	// InitChain waits for the block to be finalized, is rejected rather than
+	// resetting the finalized block, and goes through once it is committed
+	committed, initialized := make(chan struct{}), make(chan error, 1)
+	go func() {
+		_, err := app.InitChain(goCtx, &abci.RequestInitChain{AppStateBytes: []byte("{}")})
+		if sdkerrors.IsOf(err, ErrBlockPending) {
+			events <- "init rejected"
+			<-committed
+			appState, _ := json.Marshal(GenesisJSON{Values: []KV{{Key: "b", Value: "2"}}})
+			_, err = app.InitChain(goCtx, &abci.RequestInitChain{AppStateBytes: appState})
+		}
+		events <- "init"
+		initialized <- err
+	}()
+
+	close(release)
+	require.NoError(t, <-finalized)
+	require.Equal(t, CacheEventWrap, <-events)
+	require.Equal(t, "init rejected", <-events)
+
+	_, err := app.Commit(goCtx)
+	require.NoError(t, err)
+	close(committed)
+	require.NoError(t, <-initialized)
+	require.Equal(t, CacheEventWrite, <-events)
+	require.Equal(t, CacheEventCommit, <-events)
+	require.Equal(t, "init", <-events)
+
+	// the genesis of the later InitChain is committed along with the next block
+	finalizeAndCommit(t, app, 2, []byte("c=3"))
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 1).Value)
+	require.Nil(t, queryStore(t, app, "main", "b", 1).Value)
+	require.Equal(t, []byte("2"), queryStore(t, app, "main", "b", 2).Value)
+}
+
+func TestInitChainAfterFailedFinalizeBlock(t *testing.T) {
+	goCtx := context.Background()
+	app := setupTestApp(t, nil, SetSupplyInvariant(true))
+	_, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: 1, Txs: [][]byte{[]byte(BalanceKeyPrefix + "alice=100stake")}})
+	require.ErrorIs(t, err, ErrSupplyInvariant)
+
+	// no Commit follows the failed block, which leaves nothing pending
+	_, err = app.InitChain(goCtx, &abci.RequestInitChain{AppStateBytes: []byte("{}")})
+	require.NoError(t, err)
+
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 1).Value)
+	require.Nil(t, queryStore(t, app, "main", BalanceKeyPrefix+"alice", 1).Value)
+}
+
+func TestNewAppAtHeight(t *testing.T) {
+	dir := t.TempDir()
+	blocks := [][][]byte{{[]byte("a=1")}, {[]byte("b=2")}, {[]byte("a=3")}, {NewSwapTx("a", "b")}, {[]byte("c=5")}}
+
+	app, err := NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	appState, err := json.Marshal(GenesisJSON{})
+	require.NoError(t, err)
+	_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: appState})
+	require.NoError(t, err)
+	_, err = RunBlocks(app, blocks)
+	require.NoError(t, err)
+	require.NoError(t, app.(*App).Close())
+
+	reference := setupTestApp(t, nil)
+	_, err = RunBlocks(reference, blocks[:3])
+	require.NoError(t, err)
+
+	app, err = NewAppAtHeight(dir, 3, log.NewNopLogger())
+	require.NoError(t, err)
+	mockApp := app.(*App)
+	require.Equal(t, int64(3), mockApp.LastBlockHeight())
+	require.Equal(t, reference.LastCommitID(), mockApp.LastCommitID())
+	require.NoError(t, AssertStateEqual(reference, app))
+	require.Nil(t, queryStore(t, app, "main", "c", 3).Value)
+
+	// the chain goes on from the rolled back height
+	_, err = RunBlocks(app, [][][]byte{{[]byte("d=4")}})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), mockApp.LastBlockHeight())
+	require.Equal(t, []byte("4"), queryStore(t, app, "main", "d", 4).Value)
+	require.Equal(t, []byte("3"), queryStore(t, app, "main", "a", 4).Value)
+	// the db must be released before the app is reopened below
+	require.NoError(t, mockApp.Close())
+
+	for _, height := range []int64{0, 5} {
+		_, err = NewAppAtHeight(dir, height, log.NewNopLogger())
+		require.Error(t, err, "height %d", height)
+	}
+}
+
+func TestTxMeta(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	meta := []byte("client-ref=42#a")
+	resp := finalizeAndCommit(t, app, 1, NewTxWithMeta("key", "value", meta), []byte("other=value"))
+	require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+	require.Equal(t, string(meta), resp.TxResults[0].Info)
+	require.Empty(t, resp.TxResults[1].Info)
+
+	// the metadata is not part of the stored value
+	require.Equal(t, []byte("value"), queryStore(t, app, "main", "key", 1).Value)
+}
+
+func TestReservedKeys(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	resp := finalizeAndCommit(t, app, 1, []byte(GenesisKey+"=clobbered"), []byte(ReservedKeyPrefix+"other=x"), []byte("key=value"))
+	for _, res := range resp.TxResults[:2] {
+		require.Equal(t, ErrReservedKey.ABCICode(), res.Code, res.Log)
+		require.Equal(t, Codespace, res.Codespace)
+	}
+	require.Equal(t, uint32(0), resp.TxResults[2].Code, resp.TxResults[2].Log)
+
+	// the bookkeeping is left untouched
+	require.JSONEq(t, `{"values":null}`, string(queryStore(t, app, "main", GenesisKey, 1).Value))
+	require.Nil(t, queryStore(t, app, "main", ReservedKeyPrefix+"other", 1).Value)
+}
+
+func TestGenesisGasFree(t *testing.T) {
+	expensive := storetypes.GasConfig{WriteCostFlat: 1 << 40, WriteCostPerByte: 1 << 30}
+	value := strings.Repeat("x", 1<<10)
+
+	app := setupTestApp(t, []KV{{"expensive", value}},
+		SetKVGasConfig("main", expensive),
+		SetBlockGasLimit(1000),
+	)
+	finalizeAndCommit(t, app, 1)
+	require.Equal(t, []byte(value), queryStore(t, app, "main", "expensive", 1).Value)
+
+	// the same write as a tx does run out of gas
+	resp := finalizeAndCommit(t, app, 2, []byte("expensive="+value))
+	require.Equal(t, sdkerrors.ErrOutOfGas.ABCICode(), resp.TxResults[0].Code, resp.TxResults[0].Log)
+}
+
+func TestDedupTxs(t *testing.T) {
+	// the tx index key records which copy of the tx was applied last
+	tx := []byte(TxIndexKey)
+
+	for name, tc := range map[string]struct {
+		opts     []Option
+		codes    []uint32
+		expected string
+	}{
+		"disabled": {nil, []uint32{0, 0, 0}, "1"},
+		"enabled":  {[]Option{SetDedupTxs(true)}, []uint32{0, ErrDuplicateTx.ABCICode(), 0}, "0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, tc.opts...)
+			resp := finalizeAndCommit(t, app, 1, tx, tx, []byte("other=1"))
+			for i, res := range resp.TxResults {
+				require.Equal(t, tc.codes[i], res.Code, "tx %d: %s", i, res.Log)
+			}
+			require.Equal(t, []byte(tc.expected), queryStore(t, app, "main", TxIndexKey, 1).Value)
+		})
+	}
+}
+
+func TestTimeSource(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	app := setupTestApp(t, nil, SetTimeSource(func() time.Time { return now }))
+
+	finalizeAndCommit(t, app, 1, []byte(TimeKey))
+	require.Equal(t, now.Format(time.RFC3339Nano), string(queryStore(t, app, "main", TimeKey, 1).Value))
+
+	now = now.Add(time.Hour)
+	finalizeAndCommit(t, app, 2, []byte(TimeKey))
+	require.Equal(t, now.Format(time.RFC3339Nano), string(queryStore(t, app, "main", TimeKey, 2).Value))
+
+	// a block time set by the request takes precedence
+	explicit := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := app.FinalizeBlock(context.Background(), NewFinalizeRequest(3, [][]byte{[]byte(TimeKey)}, WithBlockTime(explicit)))
+	require.NoError(t, err)
+	_, err = app.Commit(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, explicit.Format(time.RFC3339Nano), string(queryStore(t, app, "main", TimeKey, 3).Value))
+}
+
+func TestFinalizeBlockInterrupted(t *testing.T) {
+	goCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the panicking tx cancels the block while it executes
+	app := setupTestApp(t, nil, SetRecoveryHandler(func(recoveryObj interface{}) error {
+		cancel()
+		return nil
+	}))
+	txs := [][]byte{[]byte("a=1"), []byte("bad=1=2"), []byte(PanicKey + "=boom"), []byte("b=2"), []byte("c=3")}
+	const executed = 3
+
+	resp, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: 1, Txs: txs})
+	require.NoError(t, err)
+	require.Len(t, resp.TxResults, executed)
+	require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+	require.NotEqual(t, uint32(0), resp.TxResults[2].Code)
+
+	require.Len(t, resp.Events, 1)
+	event := resp.Events[0]
+	require.Equal(t, EventTypeInterrupted, event.Type)
+	attrs := make(map[string]string)
+	for _, attr := range event.Attributes {
+		attrs[string(attr.Key)] = string(attr.Value)
+	}
+	require.Equal(t, map[string]string{AttributeKeyExecuted: "3", AttributeKeyTotal: "5"}, attrs)
+
+	// only the executed txs are committed
+	_, err = app.Commit(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 1).Value)
+	require.Nil(t, queryStore(t, app, "main", "b", 1).Value)
+
+	// a block left alone runs to completion
+	resp = finalizeAndCommit(t, app, 2, txs[3:]...)
+	require.Len(t, resp.TxResults, 2)
+	require.Empty(t, resp.Events)
+}
+
+func TestValidateInitChain(t *testing.T) {
+	for name, tc := range map[string]struct {
+		state  string
+		strict bool
+	}{
+		"malformed":     {state: `{"values":`},
+		"empty key":     {state: `{"values":[{"key":"","value":"v"}]}`},
+		"reserved key":  {state: `{"values":[{"key":"` + GenesisKey + `","value":"v"}]}`},
+		"unknown field": {state: `{"vals":[]}`, strict: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app, err := NewApp(t.TempDir(), log.NewNopLogger(), SetStrictGenesis(tc.strict))
+			require.NoError(t, err)
+			mockApp := app.(*App)
+			defer mockApp.Close()
+
+			req := abci.RequestInitChain{AppStateBytes: []byte(tc.state)}
+			err = mockApp.ValidateInitChain(req)
+			require.Error(t, err)
+
+			// nothing was written
+			require.Nil(t, mockApp.CommitMultiStore().GetKVStore(mockApp.capKeyMainStore).Get([]byte(GenesisKey)))
+			require.Zero(t, mockApp.LastBlockHeight())
+
+			require.PanicsWithError(t, err.Error(), func() {
+				app.InitChain(context.Background(), &req)
+			})
+		})
+	}
+
+	app, err := NewApp(t.TempDir(), log.NewNopLogger())
+	require.NoError(t, err)
+	defer app.(*App).Close()
+	// a repeated key is valid, the last value wins
+	req := abci.RequestInitChain{AppStateBytes: []byte(`{"values":[{"key":"a","value":"1"},{"key":"a","value":"2"}]}`)}
+	require.NoError(t, app.(*App).ValidateInitChain(req))
+	_, err = app.InitChain(context.Background(), &req)
+	require.NoError(t, err)
+	finalizeAndCommit(t, app, 1)
+	require.Equal(t, []byte("2"), queryStore(t, app, "main", "a", 1).Value)
+}
+
+func TestAppGenStateFromFile(t *testing.T) {
+	writeGenesis := func(content string) string {
+		path := filepath.Join(t.TempDir(), "genesis.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	for name, path := range map[string]string{
+		"genesis document": filepath.Join("testdata", "genesis.json"),
+		"app state":        writeGenesis(`{"values":[{"key":"hello","value":"world"},{"key":"foo","value":"bar"}]}`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			appState, err := AppGenStateFromFile(path)(nil, types.GenesisDoc{}, nil)
+			require.NoError(t, err)
+
+			app, err := NewApp(t.TempDir(), log.NewNopLogger())
+			require.NoError(t, err)
+			defer app.(*App).Close()
+			_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: appState})
+			require.NoError(t, err)
+			_, err = app.Commit(context.Background())
+			require.NoError(t, err)
+
+			for key, value := range map[string]string{"hello": "world", "foo": "bar"} {
+				qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/store/main/key", Data: []byte(key)})
+				require.NoError(t, err)
+				require.Equal(t, []byte(value), qres.Value, key)
+			}
+		})
+	}
+
+	for _, path := range []string{
+		filepath.Join(t.TempDir(), "missing.json"),
+		writeGenesis(`{"values":`),
+		writeGenesis(`{"app_state":{"values":[{"key":"` + GenesisKey + `","value":"1"}]}}`),
+	} {
+		_, err := AppGenStateFromFile(path)(nil, types.GenesisDoc{}, nil)
+		require.Error(t, err, path)
+	}
+}
+
+func TestInitChainReservedKey(t *testing.T) {
+	for _, key := range []string{GenesisKey, ValidatorKeyPrefix + "00", ReservedKeyPrefix} {
+		app, err := NewApp(t.TempDir(), log.NewNopLogger())
+		require.NoError(t, err)
+		mockApp := app.(*App)
+
+		req := abci.RequestInitChain{AppStateBytes: []byte(`{"values":[{"key":"a","value":"1"},{"key":"` + key + `","value":"v"}]}`)}
+		err = mockApp.ValidateInitChain(req)
+		require.True(t, ErrReservedKey.Is(err), "key %s: %v", key, err)
+		require.PanicsWithError(t, err.Error(), func() {
+			app.InitChain(context.Background(), &req)
+		})
+		require.False(t, mockApp.Initialized())
+		require.NoError(t, mockApp.Close())
+	}
+}
+
+func TestDuplicateKeysInTx(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"sequential": nil,
+		"concurrent": {SetConcurrentExecution(4)},
+		"merge":      {SetConflictMerge(sumMerge)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, opts...)
+			resp := finalizeAndCommit(t, app, 1, []byte("a=1&b=2&a=3"), []byte("c=4&c=5&c=6"))
+			for i, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+			}
+
+			// the last write of a key within a tx wins
+			require.Equal(t, []byte("3"), queryStore(t, app, "main", "a", 1).Value)
+			require.Equal(t, []byte("2"), queryStore(t, app, "main", "b", 1).Value)
+			require.Equal(t, []byte("6"), queryStore(t, app, "main", "c", 1).Value)
+		})
+	}
+}
+
+func TestInMemory(t *testing.T) {
+	dir := t.TempDir()
+	app, err := NewApp(dir, log.NewNopLogger(), SetInMemory(true))
+	require.NoError(t, err)
+	_, err = RunBlocks(app, [][][]byte{{[]byte("a=1")}})
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 1).Value)
+	require.NoError(t, app.(*App).Close())
+
+	// nothing was written under the root directory
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
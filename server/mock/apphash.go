@@ -0,0 +1,49 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// AppHashFunc derives a hash from the committed pairs of the main store,
+// which are passed in key order.
+type AppHashFunc func(pairs []KV) []byte
+
+// SortedConcatHash is an AppHashFunc hashing the length-prefixed keys and
+// values of the pairs in order. Unlike the IAVL root, it only depends on the
+// committed key-set, not on the history that led to it.
+func SortedConcatHash(pairs []KV) []byte {
+	hasher := sha256.New()
+	for _, pair := range pairs {
+		for _, bz := range []string{pair.Key, pair.Value} {
+			_ = binary.Write(hasher, binary.BigEndian, uint64(len(bz)))
+			hasher.Write([]byte(bz))
+		}
+	}
+	return hasher.Sum(nil)
+}
+
+// ExperimentalAppHash returns the hash computed by the function set with
+// SetAppHashFunc over the state committed last, or nil if none is set. It is
+// only computed for comparison and never replaces the store's app hash.
+func (app *App) ExperimentalAppHash() []byte {
+	return app.experimentalAppHash
+}
+
+// computeExperimentalAppHash runs the app hash function over the committed
+// state of the main store.
+func (app *App) computeExperimentalAppHash() {
+	if app.appHashFunc == nil {
+		return
+	}
+
+	store := app.CommitMultiStore().GetKVStore(app.capKeyMainStore)
+	iter := store.Iterator(nil, nil)
+	defer iter.Close()
+
+	var pairs []KV
+	for ; iter.Valid(); iter.Next() {
+		pairs = append(pairs, KV{Key: string(iter.Key()), Value: string(iter.Value())})
+	}
+	app.experimentalAppHash = app.appHashFunc(pairs)
+}
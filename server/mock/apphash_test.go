@@ -0,0 +1,42 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperimentalAppHash(t *testing.T) {
+	direct := setupTestApp(t, nil, SetAppHashFunc(SortedConcatHash))
+	require.Nil(t, direct.ExperimentalAppHash())
+	finalizeAndCommit(t, direct, 1, []byte("a=2"), []byte("b=1"))
+
+	// the same state reached through an extra block
+	indirect := setupTestApp(t, nil, SetAppHashFunc(SortedConcatHash))
+	finalizeAndCommit(t, indirect, 1, []byte("a=1"))
+	firstHash, firstRoot := indirect.ExperimentalAppHash(), indirect.LastCommitID().Hash
+	finalizeAndCommit(t, indirect, 2, []byte("a=2"), []byte("b=1"))
+
+	require.NotEqual(t, firstHash, indirect.ExperimentalAppHash())
+	require.NotEqual(t, firstRoot, indirect.LastCommitID().Hash)
+
+	// the IAVL root commits to the versions the keys were written at, the
+	// key-set hash only to the keys and values
+	require.NoError(t, AssertStateEqual(direct, indirect))
+	require.NotEqual(t, direct.LastCommitID().Hash, indirect.LastCommitID().Hash)
+	require.Equal(t, direct.ExperimentalAppHash(), indirect.ExperimentalAppHash())
+
+	var pairs []KV
+	iter := direct.CommitMultiStore().GetKVStore(direct.capKeyMainStore).Iterator(nil, nil)
+	for ; iter.Valid(); iter.Next() {
+		pairs = append(pairs, KV{Key: string(iter.Key()), Value: string(iter.Value())})
+	}
+	iter.Close()
+	require.Equal(t, SortedConcatHash(pairs), direct.ExperimentalAppHash())
+}
+
+func TestExperimentalAppHashUnset(t *testing.T) {
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+	require.Nil(t, app.ExperimentalAppHash())
+}
@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"strconv"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// AppVersionKey is the reserved key the app version is stored under, as a
+// decimal string.
+const AppVersionKey = ReservedKeyPrefix + "appversion"
+
+// UpgradeKey is a special key: a tx setting it to a decimal app version
+// greater than the current one upgrades the app to that version. The new
+// version is reported by Info once the block is committed.
+const UpgradeKey = "__upgrade__"
+
+// appVersion returns the app version stored in store, 0 if none is.
+func appVersion(store sdk.KVStore) (uint64, error) {
+	bz := store.Get([]byte(AppVersionKey))
+	if bz == nil {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(bz), 10, 64)
+}
+
+// validateUpgrade checks that value is a valid app version to upgrade to.
+func (app *App) validateUpgrade(ctx sdk.Context, value []byte) error {
+	version, err := strconv.ParseUint(string(value), 10, 64)
+	if err != nil {
+		return sdkerrors.Wrapf(ErrInvalidUpgrade, "app version %q: %s", value, err)
+	}
+	current, err := appVersion(ctx.KVStore(app.capKeyMainStore))
+	if err != nil {
+		return err
+	}
+	if version <= current {
+		return sdkerrors.Wrapf(ErrInvalidUpgrade, "app version %d is not greater than %d", version, current)
+	}
+	return nil
+}
+
+// loadAppVersion sets the protocol version reported by Info from the last
+// committed state.
+func (app *App) loadAppVersion() error {
+	version, err := appVersion(app.CommitMultiStore().GetKVStore(app.capKeyMainStore))
+	if err != nil {
+		return err
+	}
+	app.SetProtocolVersion(version)
+	return nil
+}
+
+// handleQueryAppVersion returns the app version as a decimal string.
+func (app *App) handleQueryAppVersion(req abci.RequestQuery) abci.ResponseQuery {
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	version, err := appVersion(ctx.KVStore(app.capKeyMainStore))
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Value:     []byte(strconv.FormatUint(version, 10)),
+	}
+}
@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestAppVersionUpgrade(t *testing.T) {
+	goCtx := context.Background()
+	queryAppVersion := func(app abci.Application) string {
+		qres, err := app.Query(goCtx, &abci.RequestQuery{Path: "/mock/appversion"})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		return string(qres.Value)
+	}
+	infoAppVersion := func(app abci.Application) uint64 {
+		info, err := app.Info(goCtx, &abci.RequestInfo{})
+		require.NoError(t, err)
+		return info.AppVersion
+	}
+
+	dir := t.TempDir()
+	app, err := NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	_, err = app.InitChain(goCtx, &abci.RequestInitChain{AppStateBytes: []byte(`{"values":[],"app_version":1}`)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), infoAppVersion(app))
+	finalizeAndCommit(t, app, 1)
+	require.Equal(t, "1", queryAppVersion(app))
+
+	// downgrades and garbage are rejected
+	resp := finalizeAndCommit(t, app, 2, []byte(UpgradeKey+"=1"), []byte(UpgradeKey+"=two"))
+	for _, res := range resp.TxResults {
+		require.Equal(t, ErrInvalidUpgrade.ABCICode(), res.Code, res.Log)
+	}
+
+	finalizeAndCommit(t, app, 3, []byte(UpgradeKey+"=2"))
+	require.Equal(t, "2", queryAppVersion(app))
+	require.Equal(t, uint64(2), infoAppVersion(app))
+
+	// the version survives a restart
+	require.NoError(t, app.(*App).Close())
+	app, err = NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), infoAppVersion(app))
+}
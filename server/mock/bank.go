@@ -0,0 +1,185 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// BankTxPrefix starts the bytes of a kvBankTx, i.e.
+// "bank:recipient=10stake,5atom".
+const BankTxPrefix = "bank:"
+
+// BalanceKeyPrefix prefixes the main store key holding the balance of every
+// kvBankTx recipient.
+const BalanceKeyPrefix = "balance/"
+
+// reCoin splits a coin into its amount and denom without validating either,
+// which is left to ValidateBasic.
+var reCoin = regexp.MustCompile(`^(-?[0-9]+)(.*)$`)
+
+// kvBankTx credits coins to the balance of a recipient. It is an sdk.Tx which
+// is its own sdk.Msg.
+type kvBankTx struct {
+	recipient string
+	coins     sdk.Coins
+	bytes     []byte
+}
+
+// dummy implementation of proto.Message
+func (msg kvBankTx) Reset()         {}
+func (msg kvBankTx) String() string { return "TODO" }
+func (msg kvBankTx) ProtoMessage()  {}
+
+var _ sdk.Tx = kvBankTx{}
+var _ sdk.Msg = kvBankTx{}
+
+// NewBankTx returns the bytes of a tx crediting coins to recipient.
+func NewBankTx(recipient string, coins sdk.Coins) []byte {
+	return []byte(fmt.Sprintf("%s%s=%s", BankTxPrefix, recipient, coins))
+}
+
+func (tx kvBankTx) Route() string {
+	return "bank"
+}
+
+func (tx kvBankTx) Type() string {
+	return "bank_tx"
+}
+
+func (tx kvBankTx) GetMsgs() []sdk.Msg {
+	return []sdk.Msg{tx}
+}
+
+func (tx kvBankTx) GetMemo() string {
+	return ""
+}
+
+func (tx kvBankTx) GetSignBytes() []byte {
+	return tx.bytes
+}
+
+// ValidateBasic rejects coins with an invalid denom or a non positive amount,
+// as well as denoms appearing more than once.
+func (tx kvBankTx) ValidateBasic() error {
+	if len(tx.coins) == 0 {
+		return sdkerrors.Wrap(sdkerrors.ErrInvalidCoins, "no coins")
+	}
+	seen := make(map[string]struct{}, len(tx.coins))
+	for _, coin := range tx.coins {
+		if err := sdk.ValidateDenom(coin.Denom); err != nil {
+			return sdkerrors.Wrap(sdkerrors.ErrInvalidCoins, err.Error())
+		}
+		if !coin.Amount.IsPositive() {
+			return sdkerrors.Wrapf(sdkerrors.ErrInvalidCoins, "non positive amount %s", coin)
+		}
+		if _, ok := seen[coin.Denom]; ok {
+			return sdkerrors.Wrapf(sdkerrors.ErrInvalidCoins, "duplicate denom %s", coin.Denom)
+		}
+		seen[coin.Denom] = struct{}{}
+	}
+	return nil
+}
+
+func (tx kvBankTx) GetSigners() []sdk.AccAddress {
+	return nil
+}
+
+func (tx kvBankTx) GetGasEstimate() uint64 {
+	return 0
+}
+
+// decodeBankTx decodes the bytes of a kvBankTx, BankTxPrefix included.
+func decodeBankTx(txBytes []byte) (sdk.Tx, error) {
+	split := bytes.Split(txBytes[len(BankTxPrefix):], []byte("="))
+	if len(split) != 2 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "expected recipient=coins")
+	}
+	if len(split[0]) == 0 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "empty recipient")
+	}
+
+	tx := kvBankTx{recipient: string(split[0]), bytes: txBytes}
+	for _, coin := range strings.Split(string(split[1]), ",") {
+		matches := reCoin.FindStringSubmatch(coin)
+		if matches == nil {
+			return nil, sdkerrors.Wrapf(sdkerrors.ErrTxDecode, "invalid coin %q", coin)
+		}
+		amount, ok := sdk.NewIntFromString(matches[1])
+		if !ok {
+			return nil, sdkerrors.Wrapf(sdkerrors.ErrTxDecode, "invalid amount %q", matches[1])
+		}
+		tx.coins = append(tx.coins, sdk.Coin{Denom: matches[2], Amount: amount})
+	}
+
+	return tx, nil
+}
+
+// bankHandler adds the coins of a kvBankTx to the balance of its recipient.
+func (app *App) bankHandler(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+	bTx, ok := msg.(kvBankTx)
+	if !ok {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrUnknownRequest, "unexpected bank msg %T", msg)
+	}
+
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	key := []byte(BalanceKeyPrefix + bTx.recipient)
+	if err := app.checkWriteOnce(store, key); err != nil {
+		return nil, err
+	}
+	balance, err := sdk.ParseCoinsNormalized(string(store.Get(key)))
+	if err != nil {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid stored balance: %s", err)
+	}
+	coins := append(sdk.Coins{}, bTx.coins...).Sort()
+	store.Set(key, []byte(balance.Add(coins...).String()))
+
+	return &sdk.Result{}, nil
+}
+
+// Balance is the balance of a kvBankTx recipient, as returned by the
+// /mock/balances query.
+type Balance struct {
+	Address string    `json:"address"`
+	Coins   sdk.Coins `json:"coins"`
+}
+
+// handleQueryBalances returns the JSON encoded balances of every kvBankTx
+// recipient at the requested height, sorted by address. The order follows
+// the keys of the main store, so it doesn't depend on the order the
+// balances were credited in.
+func (app *App) handleQueryBalances(req abci.RequestQuery) abci.ResponseQuery {
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	balances := []Balance{}
+	iter := sdk.KVStorePrefixIterator(ctx.KVStore(app.capKeyMainStore), []byte(BalanceKeyPrefix))
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		address := string(iter.Key()[len(BalanceKeyPrefix):])
+		coins, err := sdk.ParseCoinsNormalized(string(iter.Value()))
+		if err != nil {
+			return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid stored balance of %s: %s", address, err))
+		}
+		balances = append(balances, Balance{Address: address, Coins: coins})
+	}
+	bz, err := json.Marshal(balances)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode balances"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Value:     bz,
+	}
+}
@@ -0,0 +1,87 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestBankTx(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	coins := sdk.NewCoins(sdk.NewInt64Coin("atom", 5), sdk.NewInt64Coin("stake", 10))
+	res := finalizeAndCommit(t, app, 1, NewBankTx("alice", coins), NewBankTx("alice", coins[:1]))
+	for _, txRes := range res.TxResults {
+		require.Equal(t, uint32(0), txRes.Code, txRes.Log)
+	}
+
+	balance := queryStore(t, app, "main", BalanceKeyPrefix+"alice", 1).Value
+	require.Equal(t, "10atom,10stake", string(balance))
+}
+
+func TestBankTxValidateBasic(t *testing.T) {
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1)
+
+	for name, tc := range map[string]struct {
+		tx   string
+		code uint32
+	}{
+		"valid":           {"bank:alice=10stake,5atom", 0},
+		"invalid denom":   {"bank:alice=10s", sdkerrors.ErrInvalidCoins.ABCICode()},
+		"missing denom":   {"bank:alice=10", sdkerrors.ErrInvalidCoins.ABCICode()},
+		"zero amount":     {"bank:alice=0stake", sdkerrors.ErrInvalidCoins.ABCICode()},
+		"negative amount": {"bank:alice=-1stake", sdkerrors.ErrInvalidCoins.ABCICode()},
+		"duplicate denom": {"bank:alice=1stake,2stake", sdkerrors.ErrInvalidCoins.ABCICode()},
+		"no amount":       {"bank:alice=stake", sdkerrors.ErrTxDecode.ABCICode()},
+		"no recipient":    {"bank:=1stake", sdkerrors.ErrTxDecode.ABCICode()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res := checkTx(t, app, []byte(tc.tx))
+			require.Equal(t, tc.code, res.Code, res.Log)
+		})
+	}
+}
+
+func TestQueryBalances(t *testing.T) {
+	stake := func(amount int64) sdk.Coins { return sdk.NewCoins(sdk.NewInt64Coin("stake", amount)) }
+	queryBalances := func(t *testing.T, app abci.Application, height int64) []Balance {
+		t.Helper()
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/balances", Height: height})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		var balances []Balance
+		require.NoError(t, json.Unmarshal(qres.Value, &balances))
+		return balances
+	}
+
+	app := setupTestApp(t, nil, SetConcurrentExecution(4))
+
+	// credited out of address order, and next to keys of other txs
+	_, err := RunBlocks(app, [][][]byte{
+		{NewBankTx("carol", stake(3)), []byte("balance=1"), NewBankTx("alice", stake(1))},
+		{NewBankTx("bob", stake(2)), NewBankTx("alice", sdk.NewCoins(sdk.NewInt64Coin("atom", 4)))},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []Balance{
+		{Address: "alice", Coins: stake(1)},
+		{Address: "carol", Coins: stake(3)},
+	}, queryBalances(t, app, 1))
+	require.Equal(t, []Balance{
+		{Address: "alice", Coins: sdk.NewCoins(sdk.NewInt64Coin("atom", 4), sdk.NewInt64Coin("stake", 1))},
+		{Address: "bob", Coins: stake(2)},
+		{Address: "carol", Coins: stake(3)},
+	}, queryBalances(t, app, 2))
+
+	// without any balance the list is empty
+	empty := setupTestApp(t, nil)
+	finalizeAndCommit(t, empty, 1, []byte("a=1"))
+	require.Empty(t, queryBalances(t, empty, 1))
+}
@@ -0,0 +1,70 @@
+package mock
+
+import (
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// BlockGasKey is a special key: whatever value a tx sets for it, the handler
+// stores the block gas remaining when it runs instead, or "unlimited" if no
+// block gas limit is configured.
+const BlockGasKey = "__blockgas__"
+
+type blockGasMeterKey struct{}
+
+// BlockGasMeter returns the meter tracking the gas consumed by the txs of the
+// block being finalized, or nil outside of FinalizeBlock. The meter is
+// infinite unless a block gas limit is configured with SetBlockGasLimit.
+func BlockGasMeter(ctx sdk.Context) sdk.GasMeter {
+	meter, _ := ctx.Value(blockGasMeterKey{}).(sdk.GasMeter)
+	return meter
+}
+
+func (app *App) newBlockGasMeter() sdk.GasMeter {
+	if app.blockGasLimit == 0 {
+		return sdk.NewInfiniteGasMeter(1, 1)
+	}
+	return sdk.NewGasMeter(app.blockGasLimit, 1, 1)
+}
+
+// newTxGasMeter returns the gas meter of the next tx of the block, which is
+// capped by the block gas left so a tx running past it fails out of gas.
+func newTxGasMeter(blockGasMeter sdk.GasMeter) sdk.GasMeter {
+	if blockGasMeter.Limit() == 0 {
+		return sdk.NewInfiniteGasMeter(1, 1)
+	}
+	return sdk.NewGasMeter(blockGasMeter.Limit()-blockGasMeter.GasConsumedToLimit(), 1, 1)
+}
+
+// BlockGasInfo is the block gas accounting of a finalized block. Consumed is
+// the gas the block was left charged with once refunds were applied, so
+// Consumed+Refunded never exceeds a non-zero Limit. A zero Limit means block
+// gas is unlimited.
+type BlockGasInfo struct {
+	Limit    uint64
+	Consumed uint64
+	Refunded uint64
+}
+
+// LastBlockGas returns the block gas accounting of the last finalized block.
+func (app *App) LastBlockGas() BlockGasInfo {
+	return app.lastBlockGas
+}
+
+// chargeBlockGas charges the gas used by a tx to the block, never charging
+// more than the block has left. The charge is returned to the block when the
+// tx failed and failed tx gas refunds are enabled.
+func (app *App) chargeBlockGas(blockGasMeter sdk.GasMeter, res abci.ResponseDeliverTx) {
+	amount := uint64(res.GasUsed)
+	if limit := blockGasMeter.Limit(); limit > 0 {
+		if remaining := limit - blockGasMeter.GasConsumedToLimit(); amount > remaining {
+			amount = remaining
+		}
+	}
+	blockGasMeter.ConsumeGas(amount, "block gas")
+	if app.refundFailedTxGas && res.Code != 0 {
+		blockGasMeter.RefundGas(amount, "failed tx refund")
+		app.lastBlockGas.Refunded += amount
+	}
+}
@@ -0,0 +1,102 @@
+package mock
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// eventValue returns the value attribute of the kvstore event of a tx.
+func eventValue(t *testing.T, events []abci.Event) string {
+	t.Helper()
+
+	for _, event := range events {
+		if event.Type != EventTypeKVStore {
+			continue
+		}
+		for _, attr := range event.Attributes {
+			if string(attr.Key) == AttributeKeyValue {
+				return string(attr.Value)
+			}
+		}
+	}
+	require.FailNow(t, "no kvstore event emitted")
+	return ""
+}
+
+func TestBlockGasMeter(t *testing.T) {
+	const limit = 10000
+	app := setupTestApp(t, nil, SetBlockGasLimit(limit))
+
+	txs := make([][]byte, 6)
+	for i := range txs {
+		txs[i] = []byte(BlockGasKey)
+	}
+	resp := finalizeAndCommit(t, app, 1, txs...)
+
+	remaining := uint64(limit + 1)
+	var used int64
+	for i, res := range resp.TxResults {
+		if res.Code != 0 {
+			// the block ran out of gas, every following tx fails as well
+			require.Equal(t, sdkerrors.ErrOutOfGas.ABCICode(), res.Code, res.Log)
+			require.Greater(t, i, 1)
+			continue
+		}
+
+		seen, err := strconv.ParseUint(eventValue(t, res.Events), 10, 64)
+		require.NoError(t, err)
+		require.Less(t, seen, remaining, "tx %d should see less block gas than the previous one", i)
+		require.Equal(t, uint64(limit-used), seen)
+
+		remaining = seen
+		used += res.GasUsed
+	}
+	require.LessOrEqual(t, used, int64(limit))
+	require.NotEqual(t, uint32(0), resp.TxResults[len(txs)-1].Code)
+}
+
+func TestBlockGasMeterUnlimited(t *testing.T) {
+	app := setupTestApp(t, nil)
+	resp := finalizeAndCommit(t, app, 1, []byte(BlockGasKey))
+
+	require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+	require.Equal(t, "unlimited", eventValue(t, resp.TxResults[0].Events))
+}
+
+func TestLastBlockGas(t *testing.T) {
+	const limit = 100000
+	txs := [][]byte{[]byte(BlockGasKey), []byte(PanicKey + "=boom"), []byte(BlockGasKey)}
+
+	for name, refund := range map[string]bool{"charged": false, "refunded": true} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, SetBlockGasLimit(limit), SetRefundFailedTxGas(refund))
+			resp := finalizeAndCommit(t, app, 1, txs...)
+
+			failed := resp.TxResults[1]
+			require.NotEqual(t, uint32(0), failed.Code)
+			require.Greater(t, failed.GasUsed, int64(0))
+
+			var used uint64
+			for _, res := range resp.TxResults {
+				used += uint64(res.GasUsed)
+			}
+			info := app.LastBlockGas()
+			require.Equal(t, uint64(limit), info.Limit)
+			require.LessOrEqual(t, info.Consumed+info.Refunded, info.Limit)
+			require.Equal(t, used, info.Consumed+info.Refunded)
+			if refund {
+				require.Equal(t, uint64(failed.GasUsed), info.Refunded)
+				// the refunded gas is available to the following tx again
+				seen := eventValue(t, resp.TxResults[2].Events)
+				require.Equal(t, strconv.FormatInt(limit-resp.TxResults[0].GasUsed, 10), seen)
+			} else {
+				require.Zero(t, info.Refunded)
+			}
+		})
+	}
+}
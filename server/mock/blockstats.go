@@ -0,0 +1,45 @@
+package mock
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// blockStatsHeader is the header row of the block stats CSV.
+var blockStatsHeader = []string{"height", "num_txs", "gas_used", "duration_ns", "reexecutions"}
+
+// blockStatsWriter writes a CSV row of stats per finalized block, see
+// SetBlockStatsCSV.
+type blockStatsWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newBlockStatsWriter(w io.Writer) *blockStatsWriter {
+	return &blockStatsWriter{w: csv.NewWriter(w)}
+}
+
+// write writes the row of the block at height, preceded by the header row if
+// it is the first one. The row is flushed right away so the CSV can be
+// followed while the app runs.
+func (s *blockStatsWriter) write(height int64, numTxs int, gasUsed uint64, duration time.Duration, reexecutions int) error {
+	if !s.wroteHeader {
+		if err := s.w.Write(blockStatsHeader); err != nil {
+			return err
+		}
+		s.wroteHeader = true
+	}
+	if err := s.w.Write([]string{
+		strconv.FormatInt(height, 10),
+		strconv.Itoa(numTxs),
+		strconv.FormatUint(gasUsed, 10),
+		strconv.FormatInt(duration.Nanoseconds(), 10),
+		strconv.Itoa(reexecutions),
+	}); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
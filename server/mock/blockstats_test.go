@@ -0,0 +1,63 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var blockStatsCSV = flag.String("block-stats", "", "file BenchmarkBlockStatsCSV writes its block stats CSV to")
+
+func TestBlockStatsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	app := setupTestApp(t, nil, SetBlockStatsCSV(&buf))
+	responses, err := RunBlocks(app, [][][]byte{
+		{[]byte("a=1"), []byte("b=2"), []byte("c=3")},
+		{},
+		{[]byte("a=4")},
+	})
+	require.NoError(t, err)
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 1+len(responses))
+	require.Equal(t, []string{"height", "num_txs", "gas_used", "duration_ns", "reexecutions"}, rows[0])
+
+	for i, row := range rows[1:] {
+		require.Equal(t, strconv.Itoa(i+1), row[0])
+		require.Equal(t, strconv.Itoa(len(responses[i].TxResults)), row[1])
+
+		var gasUsed int64
+		for _, res := range responses[i].TxResults {
+			gasUsed += res.GasUsed
+		}
+		require.Equal(t, strconv.FormatInt(gasUsed, 10), row[2])
+
+		duration, err := strconv.ParseInt(row[3], 10, 64)
+		require.NoError(t, err)
+		require.Positive(t, duration)
+		require.Equal(t, "0", row[4])
+	}
+}
+
+// BenchmarkBlockStatsCSV runs a contended workload concurrently, writing the
+// stats of its blocks to the file given with -block-stats for plotting.
+func BenchmarkBlockStatsCSV(b *testing.B) {
+	out := io.Writer(ioutil.Discard)
+	if *blockStatsCSV != "" {
+		f, err := os.Create(*blockStatsCSV)
+		require.NoError(b, err)
+		defer f.Close()
+		out = f
+	}
+
+	txs := GenWorkload(WorkloadSpec{Txs: 100, Keys: 10, SetWeight: 2, DeleteWeight: 1, IncrementWeight: 1, ZipfS: 1.5, Seed: 1})
+	benchmarkBlocks(b, txs, SetBlockStatsCSV(out), SetConcurrentExecution(4))
+}
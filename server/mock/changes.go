@@ -0,0 +1,102 @@
+package mock
+
+import (
+	"sync"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// KVChange is a write or delete committed to a store of the app.
+type KVChange struct {
+	StoreKey string
+	Key      []byte
+	// Value is nil for deletes.
+	Value  []byte
+	Delete bool
+}
+
+// changeFeed is a write listener queueing the changes it is notified of and
+// forwarding them to a channel, so a slow or absent reader never blocks a
+// commit.
+type changeFeed struct {
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	pending []KVChange
+	closed  bool
+}
+
+var _ storetypes.WriteListener = (*changeFeed)(nil)
+
+func newChangeFeed() *changeFeed {
+	feed := &changeFeed{}
+	feed.cond = sync.NewCond(&feed.mtx)
+	return feed
+}
+
+// OnWrite implements storetypes.WriteListener.
+func (f *changeFeed) OnWrite(storeKey storetypes.StoreKey, key []byte, value []byte, delete bool) error {
+	change := KVChange{StoreKey: storeKey.Name(), Key: append([]byte{}, key...), Delete: delete}
+	if !delete {
+		change.Value = append([]byte{}, value...)
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if !f.closed {
+		f.pending = append(f.pending, change)
+		f.cond.Signal()
+	}
+	return nil
+}
+
+// forward sends the queued changes to ch in order until the feed is closed
+// and drained.
+func (f *changeFeed) forward(ch chan<- KVChange) {
+	defer close(ch)
+	for {
+		f.mtx.Lock()
+		for len(f.pending) == 0 && !f.closed {
+			f.cond.Wait()
+		}
+		pending := f.pending
+		f.pending = nil
+		closed := f.closed
+		f.mtx.Unlock()
+
+		for _, change := range pending {
+			ch <- change
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+func (f *changeFeed) close() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.closed = true
+	f.cond.Signal()
+}
+
+// SubscribeChanges returns a channel receiving every write and delete the app
+// commits to its stores, in the order they reach the stores: within a block,
+// the changes of a store come in key order. Blocks executing on a state
+// branched before the subscription, which includes the first block as it
+// executes on the state branched by InitChain, are not covered. The channel
+// is closed once the app is closed and every change has been received.
+func SubscribeChanges(app abci.Application) <-chan KVChange {
+	mockApp := app.(*App)
+
+	feed := newChangeFeed()
+	for _, key := range mockApp.storeKeys() {
+		mockApp.CommitMultiStore().AddListeners(key, []storetypes.WriteListener{feed})
+	}
+	mockApp.changeFeeds = append(mockApp.changeFeeds, feed)
+
+	ch := make(chan KVChange)
+	go feed.forward(ch)
+	return ch
+}
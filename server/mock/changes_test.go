@@ -0,0 +1,30 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeChanges(t *testing.T) {
+	app := setupTestApp(t, nil)
+	// the first block still executes on the state branched by InitChain
+	finalizeAndCommit(t, app, 1, []byte("a=0"))
+	changes := SubscribeChanges(app)
+
+	finalizeAndCommit(t, app, 2, []byte("b=2"), []byte("a=1"))
+	// neither mempool txs nor failed txs commit anything
+	checkTx(t, app, []byte("c=1"))
+	finalizeAndCommit(t, app, 3, []byte("a=3"), []byte(ReservedKeyPrefix+"x=1"))
+	require.NoError(t, app.Close())
+
+	var received []KVChange
+	for change := range changes {
+		received = append(received, change)
+	}
+	require.Equal(t, []KVChange{
+		{StoreKey: "main", Key: []byte("a"), Value: []byte("1")},
+		{StoreKey: "main", Key: []byte("b"), Value: []byte("2")},
+		{StoreKey: "main", Key: []byte("a"), Value: []byte("3")},
+	}, received)
+}
@@ -0,0 +1,139 @@
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+)
+
+// checkpointHeader is the first line of a checkpoint file, JSON encoded. The
+// snapshot stream of the committed state follows it up to the end of the
+// file.
+type checkpointHeader struct {
+	Height      uint64   `json:"height"`
+	Format      uint32   `json:"format"`
+	ExtraStores []string `json:"extra_stores,omitempty"`
+	Hash        []byte   `json:"hash"`
+}
+
+// Checkpoint writes the latest committed state of app to a single file at
+// path, which ResumeApp turns back into an app. Unlike WriteSnapshot, the
+// file is meant to be reused as a test fixture rather than served over state
+// sync, so it isn't chunked and records which stores the app mounts. The
+// content of simple stores is not part of a checkpoint.
+func Checkpoint(app abci.Application, path string) error {
+	mockApp, ok := app.(*App)
+	if !ok {
+		return fmt.Errorf("unexpected app type %T", app)
+	}
+	snapshotter, err := appSnapshotter(app)
+	if err != nil {
+		return err
+	}
+	height := mockApp.LastBlockHeight()
+	if height == 0 {
+		return fmt.Errorf("no committed state to checkpoint")
+	}
+
+	var payload bytes.Buffer
+	err = streamSnapshot(snapshotter, uint64(height), func(body []byte) error {
+		payload.Write(body)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate checkpoint at height %d: %w", height, err)
+	}
+
+	hash := sha256.Sum256(payload.Bytes())
+	header, err := json.Marshal(checkpointHeader{
+		Height:      uint64(height),
+		Format:      snapshottypes.CurrentFormat,
+		ExtraStores: mockApp.extraStoreNames,
+		Hash:        hash[:],
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint header: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file %q: %w", path, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	w.Write(header)
+	w.WriteByte('\n')
+	w.Write(payload.Bytes())
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write checkpoint file %q: %w", path, err)
+	}
+	return f.Close()
+}
+
+// ResumeApp returns a fresh app holding the state checkpointed to path by
+// Checkpoint, at the same height and with the same app hash. The app lives
+// in a temporary directory removed when it is closed. The extra stores of
+// the checkpointed app are mounted from the checkpoint, opts must not mount
+// them again.
+func ResumeApp(path string, logger log.Logger, opts ...Option) (abci.Application, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file %q: %w", path, err)
+	}
+	i := bytes.IndexByte(bz, '\n')
+	if i < 0 {
+		return nil, fmt.Errorf("checkpoint file %q has no header", path)
+	}
+	var header checkpointHeader
+	if err := json.Unmarshal(bz[:i], &header); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint header: %w", err)
+	}
+	if header.Format != snapshottypes.CurrentFormat {
+		return nil, fmt.Errorf("unsupported checkpoint format %d", header.Format)
+	}
+	payload := bz[i+1:]
+	if hash := sha256.Sum256(payload); !bytes.Equal(hash[:], header.Hash) {
+		return nil, fmt.Errorf("checkpoint hash %X != %X", hash, header.Hash)
+	}
+
+	dir, err := ioutil.TempDir("", "mock-checkpoint")
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]Option{SetExtraStores(header.ExtraStores...)}, opts...)
+	app, err := NewApp(dir, logger, opts...)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	mockApp := app.(*App)
+	mockApp.tempDir = dir
+
+	if err := restoreCheckpoint(mockApp, header, payload); err != nil {
+		mockApp.Close()
+		return nil, err
+	}
+	return app, nil
+}
+
+func restoreCheckpoint(app *App, header checkpointHeader, payload []byte) error {
+	snapshotter, err := appSnapshotter(app)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreSnapshot(snapshotter, header.Height, header.Format, [][]byte{payload}); err != nil {
+		return fmt.Errorf("failed to restore checkpoint at height %d: %w", header.Height, err)
+	}
+	app.setCommittedEvents(nil)
+	return app.loadAppVersion()
+}
@@ -0,0 +1,56 @@
+package mock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestCheckpoint(t *testing.T) {
+	app := setupTestApp(t, []KV{{Key: "genesis", Value: "value"}}, SetExtraStores("extra"))
+	finalizeAndCommit(t, app, 1, []byte("a=1"), []byte("b=2"))
+	finalizeAndCommit(t, app, 2, []byte("a=3"), []byte(UpgradeKey+"=2"))
+
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	require.NoError(t, Checkpoint(app, path))
+
+	// the checkpoint can be resumed from any number of times
+	for i := 0; i < 2; i++ {
+		resumed, err := ResumeApp(path, log.NewNopLogger())
+		require.NoError(t, err)
+		mockApp := resumed.(*App)
+
+		require.NoError(t, AssertStateEqual(app, mockApp))
+		require.Equal(t, app.LastCommitID(), mockApp.LastCommitID())
+		require.Equal(t, uint64(2), mockApp.AppVersion())
+
+		// and goes on from where the checkpointed app stopped
+		finalizeAndCommit(t, mockApp, 3, []byte("c=4"))
+		require.Equal(t, []byte("3"), queryStore(t, mockApp, "main", "a", 3).Value)
+		require.Equal(t, []byte("4"), queryStore(t, mockApp, "main", "c", 3).Value)
+
+		dir := mockApp.tempDir
+		require.NoError(t, mockApp.Close())
+		_, err = os.Stat(dir)
+		require.True(t, os.IsNotExist(err), "the resumed app directory must be removed on close")
+	}
+}
+
+func TestCheckpointInvalid(t *testing.T) {
+	require.Error(t, Checkpoint(setupTestApp(t, nil), filepath.Join(t.TempDir(), "empty")))
+
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	require.NoError(t, Checkpoint(app, path))
+
+	bz := readFile(t, path)
+	bz[len(bz)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, bz, 0644))
+	_, err := ResumeApp(path, log.NewNopLogger())
+	require.Error(t, err)
+}
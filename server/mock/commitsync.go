@@ -0,0 +1,57 @@
+package mock
+
+import (
+	dbm "github.com/tendermint/tm-db"
+)
+
+// syncDB wraps the db backing the app and forces the sync flag of every
+// write, batched or not, to the commit sync setting, whichever flag the
+// stores write with.
+type syncDB struct {
+	dbm.DB
+
+	sync bool
+}
+
+func (db *syncDB) Set(key, value []byte) error {
+	if db.sync {
+		return db.DB.SetSync(key, value)
+	}
+	return db.DB.Set(key, value)
+}
+
+func (db *syncDB) SetSync(key, value []byte) error {
+	return db.Set(key, value)
+}
+
+func (db *syncDB) Delete(key []byte) error {
+	if db.sync {
+		return db.DB.DeleteSync(key)
+	}
+	return db.DB.Delete(key)
+}
+
+func (db *syncDB) DeleteSync(key []byte) error {
+	return db.Delete(key)
+}
+
+func (db *syncDB) NewBatch() dbm.Batch {
+	return &syncBatch{Batch: db.DB.NewBatch(), sync: db.sync}
+}
+
+type syncBatch struct {
+	dbm.Batch
+
+	sync bool
+}
+
+func (b *syncBatch) Write() error {
+	if b.sync {
+		return b.Batch.WriteSync()
+	}
+	return b.Batch.Write()
+}
+
+func (b *syncBatch) WriteSync() error {
+	return b.Write()
+}
@@ -0,0 +1,94 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestCommitSyncCrash(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts   []Option
+		height int64
+	}{
+		"default": {nil, 2},
+		"synced":  {[]Option{SetCommitSync(true)}, 2},
+		// nothing ever reached the disk
+		"unsynced": {[]Option{SetCommitSync(false)}, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var cdb *CrashDB
+			opts := append(tc.opts, SetDBWrapper(func(db dbm.DB) dbm.DB {
+				cdb = NewCrashDB(db)
+				return cdb
+			}))
+			app, err := NewApp(dir, log.NewNopLogger(), opts...)
+			require.NoError(t, err)
+			_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: []byte(`{"values":[]}`)})
+			require.NoError(t, err)
+			finalizeAndCommit(t, app, 1, []byte("a=1"))
+			finalizeAndCommit(t, app, 2, []byte("a=2"))
+
+			require.NoError(t, cdb.Crash())
+			require.NoError(t, app.(*App).Close())
+
+			app, err = NewApp(dir, log.NewNopLogger())
+			require.NoError(t, err)
+			defer app.(*App).Close()
+			require.Equal(t, tc.height, app.(*App).LastBlockHeight())
+			if tc.height > 0 {
+				require.Equal(t, []byte("2"), queryStore(t, app, "main", "a", tc.height).Value)
+			}
+		})
+	}
+}
+
+func TestCrashDB(t *testing.T) {
+	db := NewCrashDB(dbm.NewMemDB())
+	require.NoError(t, db.SetSync([]byte("synced"), []byte("1")))
+	require.NoError(t, db.Set([]byte("synced"), []byte("2")))
+	require.NoError(t, db.Set([]byte("unsynced"), []byte("1")))
+	batch := db.NewBatch()
+	require.NoError(t, batch.Delete([]byte("synced")))
+	require.NoError(t, batch.Set([]byte("batched"), []byte("1")))
+	require.NoError(t, batch.Write())
+	require.Equal(t, 4, db.Unsynced())
+
+	require.NoError(t, db.Crash())
+	require.Zero(t, db.Unsynced())
+	for key, value := range map[string][]byte{"synced": []byte("1"), "unsynced": nil, "batched": nil} {
+		got, err := db.Get([]byte(key))
+		require.NoError(t, err)
+		require.Equal(t, value, got, key)
+	}
+
+	// a synced write makes the writes before it durable as well
+	require.NoError(t, db.Set([]byte("a"), []byte("1")))
+	batch = db.NewBatch()
+	require.NoError(t, batch.Set([]byte("b"), []byte("1")))
+	require.NoError(t, batch.WriteSync())
+	require.NoError(t, db.Crash())
+	got, err := db.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), got)
+}
+
+func BenchmarkCommitSync(b *testing.B) {
+	txs := make([][]byte, 100)
+	for i := range txs {
+		txs[i] = []byte(fmt.Sprintf("key%03d=value", i))
+	}
+
+	for name, sync := range map[string]bool{"synced": true, "unsynced": false} {
+		b.Run(name, func(b *testing.B) {
+			benchmarkBlocks(b, txs, SetCommitSync(sync))
+		})
+	}
+}
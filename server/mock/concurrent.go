@@ -0,0 +1,211 @@
+package mock
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/tasks"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// deliverTxs executes the entries of a block and returns their responses in
+// entry order. Entries run one after the other unless concurrent execution
+// is enabled, in which case they go through the OCC scheduler and the
+// result must not depend on how their executions interleave. With the
+// determinism check enabled, the block is first executed sequentially on a
+// branch of the state, and an error is returned if the concurrent execution
+// yields different results or a different state. A scheduler failure is
+// returned as an error as well, failing the block.
+func (app *App) deliverTxs(ctx sdk.Context, blockGasMeter sdk.GasMeter, entries []*sdk.DeliverTxEntry) ([]abci.ResponseDeliverTx, error) {
+	if app.concurrencyWorkers == 0 || len(entries) == 0 {
+		return app.deliverTxsSequential(ctx, blockGasMeter, entries, false), nil
+	}
+	if !app.determinismCheck {
+		return app.deliverTxsConcurrent(ctx, blockGasMeter, entries)
+	}
+
+	// the branch must be fully executed before the concurrent execution
+	// writes to the state it reads through to
+	refCtx, _ := ctx.CacheContext()
+	refGasMeter := app.newBlockGasMeter()
+	refunded := app.lastBlockGas.Refunded
+	expected := app.deliverTxsSequential(refCtx.WithValue(blockGasMeterKey{}, refGasMeter), refGasMeter, entries, true)
+	app.lastBlockGas.Refunded = refunded
+
+	responses, err := app.deliverTxsConcurrent(ctx, blockGasMeter, entries)
+	if err != nil {
+		return nil, err
+	}
+	diffs := DiffFinalizeResults(finalizeResponse(expected), finalizeResponse(responses))
+	for _, key := range app.storeKeys() {
+		if err := assertKVStoreEqual(refCtx.MultiStore().GetKVStore(key), ctx.MultiStore().GetKVStore(key)); err != nil {
+			diffs = append(diffs, fmt.Sprintf("store %s: %s", key.Name(), err))
+		}
+	}
+	if len(diffs) > 0 {
+		return nil, fmt.Errorf("concurrent execution diverged from sequential execution: %s", strings.Join(diffs, "; "))
+	}
+	return responses, nil
+}
+
+// deliverTxsSequential executes entries one after the other. Every tx is
+// flushed as it completes when the flush policy asks for it, and no more txs
+// are executed once the FinalizeBlock call is cancelled, in which case fewer
+// responses than entries are returned. A reference execution on a branch of
+// the deliver state is neither flushed nor interrupted.
+func (app *App) deliverTxsSequential(ctx sdk.Context, blockGasMeter sdk.GasMeter, entries []*sdk.DeliverTxEntry, reference bool) []abci.ResponseDeliverTx {
+	responses := make([]abci.ResponseDeliverTx, 0, len(entries))
+	for _, entry := range entries {
+		if !reference && app.finalizeCtx != nil && app.finalizeCtx.Err() != nil {
+			break
+		}
+		if app.txDelay > 0 {
+			time.Sleep(app.txDelay)
+		}
+		// meter every tx on its own so GasUsed only reflects that tx
+		txCtx := withTxLogger(ctx, entry.Checksum, entry.AbsoluteIndex).
+			WithGasMeter(newTxGasMeter(blockGasMeter)).
+			WithTxIndex(entry.AbsoluteIndex)
+		endTxSpan := func(abci.ResponseDeliverTx) {}
+		if !reference {
+			txCtx, endTxSpan = app.startTxSpan(txCtx, entry.Checksum)
+		}
+		res := app.DeliverTx(txCtx, entry.Request, entry.SdkTx, entry.Checksum)
+		endTxSpan(res)
+		app.chargeBlockGas(blockGasMeter, res)
+		if !reference {
+			app.flushTx(ctx)
+		}
+		responses = append(responses, res)
+	}
+	return responses
+}
+
+func (app *App) deliverTxsConcurrent(ctx sdk.Context, blockGasMeter sdk.GasMeter, entries []*sdk.DeliverTxEntry) ([]abci.ResponseDeliverTx, error) {
+	// the remaining block gas would depend on the order txs complete in, so
+	// every tx is capped at what remains when the block starts and the block
+	// is charged in tx order afterwards
+	//
+	// with scheduler hints honored, a hinted tx first waits for the txs it
+	// depends on to have executed once. They come before it in the block, so
+	// the scheduler has handed them to a worker already and this can't stall.
+	var deps map[int][]int
+	executed := make(map[int]chan struct{}, len(entries))
+	once := make(map[int]*sync.Once, len(entries))
+	if app.schedulerHints {
+		deps = hintDependencies(entries)
+		for _, entry := range entries {
+			executed[entry.AbsoluteIndex] = make(chan struct{})
+			once[entry.AbsoluteIndex] = &sync.Once{}
+		}
+	}
+
+	// executions are counted per tx; past the budget an execution is skipped,
+	// yielding an empty result which touches no key and so validates
+	executions := make(map[int]*int32, len(entries))
+	for _, entry := range entries {
+		executions[entry.AbsoluteIndex] = new(int32)
+	}
+	var exceeded int32
+	budget := int32(app.reexecutionBudget)
+
+	blockSpanCtx := ctx.TraceSpanContext()
+	deliverTx := func(ctx sdk.Context, req abci.RequestDeliverTx, tx sdk.Tx, checksum [32]byte) (res abci.ResponseDeliverTx) {
+		txIndex := ctx.TxIndex()
+		if n := atomic.AddInt32(executions[txIndex], 1); budget >= 0 && n > budget+1 {
+			atomic.StoreInt32(&exceeded, 1)
+			return res
+		}
+		for _, dep := range deps[txIndex] {
+			<-executed[dep]
+		}
+		if done, ok := once[txIndex]; ok {
+			defer done.Do(func() { close(executed[txIndex]) })
+		}
+
+		run := func() {
+			if app.txDelay > 0 {
+				time.Sleep(app.txDelay)
+			}
+			// every execution, re-executions included, is metered from
+			// scratch, so the GasUsed kept for the tx is the one of its
+			// validated execution and doesn't depend on the aborted ones
+			txCtx := withTxLogger(ctx, checksum, ctx.TxIndex()).WithGasMeter(newTxGasMeter(blockGasMeter))
+			// the scheduler replaces the span of ctx with spans of its own
+			// tracer, the tx span is a child of the block span regardless
+			txCtx, endTxSpan := app.startTxSpan(txCtx.WithTraceSpanContext(blockSpanCtx), checksum)
+			res = app.DeliverTx(txCtx, req, tx, checksum)
+			endTxSpan(res)
+		}
+		if app.scheduleHook != nil {
+			app.scheduleHook(ctx.TxIndex(), run)
+		} else {
+			run()
+		}
+		return res
+	}
+
+	// with a budget, the block is executed on a branch discarded if a tx runs
+	// out of it
+	schedCtx, write := ctx, func() {}
+	if budget >= 0 {
+		schedCtx, write = ctx.CacheContext()
+	}
+	scheduler := tasks.NewScheduler(app.concurrencyWorkers, app.TracingInfo, deliverTx)
+	responses, err := scheduler.ProcessAll(schedCtx, entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule the txs of the block: %w", err)
+	}
+
+	info := &app.lastReexecutions
+	for _, n := range executions {
+		reexecutions := int(atomic.LoadInt32(n)) - 1
+		if budget >= 0 && reexecutions > int(budget) {
+			reexecutions = int(budget)
+		}
+		info.Total += reexecutions
+		if reexecutions > info.MaxPerTx {
+			info.MaxPerTx = reexecutions
+		}
+	}
+	if atomic.LoadInt32(&exceeded) != 0 {
+		info.Exceeded = true
+		return app.deliverTxsSequential(ctx, blockGasMeter, entries, false), nil
+	}
+	write()
+
+	for _, res := range responses {
+		app.chargeBlockGas(blockGasMeter, res)
+	}
+	return responses, nil
+}
+
+// finalizeResponse wraps tx responses into a FinalizeBlock response, so they
+// can be compared with DiffFinalizeResults.
+func finalizeResponse(responses []abci.ResponseDeliverTx) *abci.ResponseFinalizeBlock {
+	res := &abci.ResponseFinalizeBlock{TxResults: make([]*abci.ExecTxResult, len(responses))}
+	for i, r := range responses {
+		res.TxResults[i] = &abci.ExecTxResult{
+			Code:      r.Code,
+			Data:      r.Data,
+			Log:       r.Log,
+			Info:      r.Info,
+			GasWanted: r.GasWanted,
+			GasUsed:   r.GasUsed,
+			Events:    r.Events,
+			Codespace: r.Codespace,
+		}
+	}
+	return res
+}
+
+// withTxLogger scopes the logger of ctx to a single tx, so the lines logged
+// while it executes can be attributed to it.
+func withTxLogger(ctx sdk.Context, checksum [32]byte, txIndex int) sdk.Context {
+	return ctx.WithLogger(ctx.Logger().With("tx_hash", fmt.Sprintf("%X", checksum), "tx_index", txIndex))
+}
@@ -0,0 +1,450 @@
+package mock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// orderedSchedule returns a schedule hook holding back the execution of
+// every tx until the txs listed before it in order have executed once.
+func orderedSchedule(order ...int) func(txIndex int, run func()) {
+	done := make(map[int]chan struct{}, len(order))
+	once := make(map[int]*sync.Once, len(order))
+	prev := make(map[int]int, len(order))
+	for i, txIndex := range order {
+		done[txIndex] = make(chan struct{})
+		once[txIndex] = &sync.Once{}
+		prev[txIndex] = -1
+		if i > 0 {
+			prev[txIndex] = order[i-1]
+		}
+	}
+
+	return func(txIndex int, run func()) {
+		if p := prev[txIndex]; p >= 0 {
+			<-done[p]
+		}
+		run()
+		once[txIndex].Do(func() { close(done[txIndex]) })
+	}
+}
+
+func TestConcurrentExecutionSchedules(t *testing.T) {
+	blocks := [][][]byte{
+		{[]byte("a=1"), []byte("a=2"), []byte("b=1"), []byte("a=3&b=2"), []byte("c=1")},
+		{[]byte("b=3"), []byte("c=2&a=4"), []byte("c=3"), []byte("d")},
+	}
+
+	reference := setupTestApp(t, nil)
+	for i, txs := range blocks {
+		finalizeAndCommit(t, reference, int64(i+1), txs...)
+	}
+
+	for name, order := range map[string][]int{
+		"in order":          {0, 1, 2, 3, 4},
+		"reverse":           {4, 3, 2, 1, 0},
+		"tx 2 before tx 1":  {0, 2, 1, 3, 4},
+		"conflicts last":    {2, 4, 3, 1, 0},
+		"interleaved pairs": {1, 0, 3, 2, 4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, SetConcurrentExecution(len(order)))
+			for i, txs := range blocks {
+				// install a fresh schedule for every block, dropping the
+				// indexes the block doesn't have
+				var blockOrder []int
+				for _, txIndex := range order {
+					if txIndex < len(txs) {
+						blockOrder = append(blockOrder, txIndex)
+					}
+				}
+				app.scheduleHook = orderedSchedule(blockOrder...)
+
+				resp := finalizeAndCommit(t, app, int64(i+1), txs...)
+				for j, res := range resp.TxResults {
+					require.Equal(t, uint32(0), res.Code, "block %d tx %d: %s", i+1, j, res.Log)
+				}
+			}
+
+			require.Equal(t, reference.LastCommitID().Hash, app.LastCommitID().Hash)
+			require.NoError(t, AssertStateEqual(reference, app))
+		})
+	}
+}
+
+func TestTxIndex(t *testing.T) {
+	txs := [][]byte{[]byte(TxIndexKey), []byte("a=b=c"), []byte(TxIndexKey), []byte(TxIndexKey)}
+
+	for name, opts := range map[string][]Option{
+		"sequential": nil,
+		"concurrent": {SetConcurrentExecution(len(txs))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, opts...)
+			resp := finalizeAndCommit(t, app, 1, txs...)
+
+			// the undecodable tx keeps its position
+			for _, i := range []int{0, 2, 3} {
+				res := resp.TxResults[i]
+				require.Equal(t, uint32(0), res.Code, res.Log)
+				require.Equal(t, strconv.Itoa(i), eventValue(t, res.Events))
+			}
+			require.Equal(t, []byte("3"), queryStore(t, app, "main", TxIndexKey, 1).Value)
+		})
+	}
+}
+
+// captureLogger records every line logged through it or a logger derived
+// from it, along with the key-values it was scoped with.
+type captureLogger struct {
+	mtx     *sync.Mutex
+	lines   *[][]interface{}
+	keyVals []interface{}
+}
+
+func newCaptureLogger() captureLogger {
+	return captureLogger{mtx: &sync.Mutex{}, lines: &[][]interface{}{}}
+}
+
+func (l captureLogger) log(msg string, keyVals ...interface{}) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	line := append([]interface{}{msg}, l.keyVals...)
+	*l.lines = append(*l.lines, append(line, keyVals...))
+}
+
+func (l captureLogger) Debug(msg string, keyVals ...interface{}) { l.log(msg, keyVals...) }
+func (l captureLogger) Info(msg string, keyVals ...interface{})  { l.log(msg, keyVals...) }
+func (l captureLogger) Error(msg string, keyVals ...interface{}) { l.log(msg, keyVals...) }
+
+func (l captureLogger) With(keyVals ...interface{}) log.Logger {
+	scoped := append(append([]interface{}{}, l.keyVals...), keyVals...)
+	return captureLogger{mtx: l.mtx, lines: l.lines, keyVals: scoped}
+}
+
+func TestTxLogger(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"sequential": nil,
+		"concurrent": {SetConcurrentExecution(2)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger := newCaptureLogger()
+			app, err := NewApp(t.TempDir(), logger, opts...)
+			require.NoError(t, err)
+			appState, err := json.Marshal(GenesisJSON{})
+			require.NoError(t, err)
+			_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: appState})
+			require.NoError(t, err)
+
+			txs := [][]byte{[]byte("a=1"), []byte("b=2")}
+			finalizeAndCommit(t, app, 1, txs...)
+
+			for i, tx := range txs {
+				hash := fmt.Sprintf("%X", sha256.Sum256(tx))
+				var found bool
+				for _, line := range *logger.lines {
+					if line[0] == "executing kvstore tx" && containsKeyVal(line, "tx_hash", hash) {
+						require.True(t, containsKeyVal(line, "tx_index", i))
+						found = true
+					}
+				}
+				require.True(t, found, "no handler log line for tx %d", i)
+			}
+		})
+	}
+}
+
+func containsKeyVal(line []interface{}, key string, value interface{}) bool {
+	for i := 1; i+1 < len(line); i += 2 {
+		if line[i] == key && line[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConcurrentSwaps(t *testing.T) {
+	genesis := []KV{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}, {Key: "c", Value: "3"}}
+	// both swaps touch b, the second one must see the value the first wrote
+	txs := [][]byte{NewSwapTx("a", "b"), NewSwapTx("b", "c"), NewSwapTx("d", "a")}
+
+	reference := setupTestApp(t, genesis)
+	resp := finalizeAndCommit(t, reference, 1, txs...)
+	for i, res := range resp.TxResults {
+		require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+	}
+	for key, value := range map[string]string{"a": "", "b": "3", "c": "1", "d": "2"} {
+		require.Equal(t, value, string(queryStore(t, reference, "main", key, 1).Value), "key %s", key)
+	}
+
+	for name, order := range map[string][]int{
+		"in order": {0, 1, 2},
+		"reverse":  {2, 1, 0},
+		"middle":   {1, 0, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, genesis, SetConcurrentExecution(len(txs)))
+
+			var mtx sync.Mutex
+			runs := make(map[int]int)
+			schedule := orderedSchedule(order...)
+			app.scheduleHook = func(txIndex int, run func()) {
+				mtx.Lock()
+				runs[txIndex]++
+				mtx.Unlock()
+				schedule(txIndex, run)
+			}
+
+			resp := finalizeAndCommit(t, app, 1, txs...)
+			for i, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+			}
+			require.Equal(t, reference.LastCommitID().Hash, app.LastCommitID().Hash)
+			require.NoError(t, AssertStateEqual(reference, app))
+
+			if order[0] != 0 {
+				// a swap running ahead of the one it depends on read a stale
+				// value and must have been executed again
+				require.Greater(t, runs[order[0]], 1)
+			}
+		})
+	}
+}
+
+func TestConcurrentReadOnlyTxs(t *testing.T) {
+	genesis := []KV{{Key: "a", Value: "1"}, {Key: "b", Value: "1"}}
+
+	for name, tc := range map[string]struct {
+		read     string
+		conflict bool
+		expected string
+	}{
+		"disjoint key": {read: "b", conflict: false, expected: "1"},
+		"written key":  {read: "a", conflict: true, expected: "2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, genesis, SetConcurrentExecution(2))
+
+			var mtx sync.Mutex
+			runs := make(map[int]int)
+			// the read runs ahead of the write it follows in the block
+			schedule := orderedSchedule(1, 0)
+			app.scheduleHook = func(txIndex int, run func()) {
+				mtx.Lock()
+				runs[txIndex]++
+				mtx.Unlock()
+				schedule(txIndex, run)
+			}
+
+			resp := finalizeAndCommit(t, app, 1, []byte("a=2"), NewReadTx(tc.read))
+			for i, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+			}
+
+			var read string
+			for _, event := range resp.TxResults[1].Events {
+				if event.Type == EventTypeRead {
+					require.Equal(t, tc.read, string(event.Attributes[0].Value))
+					read = string(event.Attributes[1].Value)
+				}
+			}
+			require.Equal(t, tc.expected, read)
+			if tc.conflict {
+				require.Greater(t, runs[1], 1, "a read of a key written earlier in the block must be executed again")
+			} else {
+				require.Equal(t, 1, runs[1], "a read of a key nothing writes must not conflict")
+			}
+
+			// nothing but the write changed the state
+			require.Equal(t, []byte("2"), queryStore(t, app, "main", "a", 1).Value)
+			require.Equal(t, []byte("1"), queryStore(t, app, "main", "b", 1).Value)
+		})
+	}
+}
+
+func TestDeterminismCheck(t *testing.T) {
+	txs := [][]byte{[]byte("a=1"), []byte("b=2"), NewSwapTx("a", "b"), []byte(TxIndexKey)}
+
+	app := setupTestApp(t, nil, SetConcurrentExecution(len(txs)), SetDeterminismCheck(true))
+	app.scheduleHook = orderedSchedule(3, 2, 1, 0)
+	resp := finalizeAndCommit(t, app, 1, txs...)
+	for i, res := range resp.TxResults {
+		require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+	}
+
+	reference := setupTestApp(t, nil)
+	finalizeAndCommit(t, reference, 1, txs...)
+	require.NoError(t, AssertStateEqual(reference, app))
+	require.Equal(t, reference.LastBlockGas(), app.LastBlockGas())
+
+	// the handler meters writes differently once the concurrent execution
+	// starts, as if it depended on how txs get scheduled
+	var once sync.Once
+	app.scheduleHook = func(_ int, run func()) {
+		once.Do(func() {
+			app.kvGasConfigs = map[string]storetypes.GasConfig{
+				"main": {WriteCostFlat: 1000, WriteCostPerByte: 1},
+			}
+		})
+		run()
+	}
+	_, err := app.FinalizeBlock(context.Background(), &abci.RequestFinalizeBlock{Height: 2, Txs: txs})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "gas used")
+}
+
+func TestConcurrentGasUsed(t *testing.T) {
+	genesis := []KV{{Key: "a", Value: "1"}, {Key: "b", Value: "22"}}
+	// every tx reads or overwrites values of a different length than the ones
+	// it would see running ahead of the txs before it, so its gas depends on
+	// which version of them it got
+	txs := [][]byte{
+		[]byte("a=333333"),
+		NewSwapTx("a", "b"),
+		NewReadTx("a", "b"),
+		[]byte("b=4&c=55555"),
+		NewSwapTx("b", "c"),
+		NewBankTx("x", sdk.NewCoins(sdk.NewInt64Coin("stake", 10))),
+		NewBankTx("x", sdk.NewCoins(sdk.NewInt64Coin("stake", 1000000))),
+	}
+
+	// the limit caps txs at a different gas in each mode, which shows in their
+	// GasWanted but must not change the gas they used
+	const limit = 1 << 20
+	reference := setupTestApp(t, genesis, SetBlockGasLimit(limit))
+	expected := finalizeAndCommit(t, reference, 1, txs...)
+	for i, res := range expected.TxResults {
+		require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+		require.Positive(t, res.GasUsed, "tx %d", i)
+	}
+
+	for name, order := range map[string][]int{
+		"in order": {0, 1, 2, 3, 4, 5, 6},
+		"reverse":  {6, 5, 4, 3, 2, 1, 0},
+		"shuffled": {4, 2, 6, 0, 5, 3, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, genesis, SetConcurrentExecution(len(txs)), SetBlockGasLimit(limit))
+			app.scheduleHook = orderedSchedule(order...)
+
+			resp := finalizeAndCommit(t, app, 1, txs...)
+			for i, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+				require.Equal(t, expected.TxResults[i].GasUsed, res.GasUsed, "tx %d", i)
+			}
+			require.Equal(t, reference.LastBlockGas(), app.LastBlockGas())
+		})
+	}
+}
+
+func TestSchedulerHints(t *testing.T) {
+	genesis := []KV{{Key: "a", Value: "1"}}
+	txs := [][]byte{[]byte("a=2"), NewHintedTx(NewReadTx("a"), "a")}
+
+	for name, tc := range map[string]struct {
+		opts []Option
+		runs int
+	}{
+		"ignored": {nil, 2},
+		"honored": {[]Option{SetSchedulerHints(true)}, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, genesis, append(tc.opts, SetConcurrentExecution(len(txs)))...)
+
+			var mtx sync.Mutex
+			runs := make(map[int]int)
+			readDone := make(chan struct{})
+			var readOnce sync.Once
+			app.scheduleHook = func(txIndex int, run func()) {
+				mtx.Lock()
+				runs[txIndex]++
+				mtx.Unlock()
+				if txIndex == 0 {
+					// the write lags behind the read unless the read waits
+					select {
+					case <-readDone:
+					case <-time.After(100 * time.Millisecond):
+					}
+				}
+				run()
+				if txIndex == 1 {
+					readOnce.Do(func() { close(readDone) })
+				}
+			}
+
+			resp := finalizeAndCommit(t, app, 1, txs...)
+			for i, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+			}
+			for _, event := range resp.TxResults[1].Events {
+				if event.Type == EventTypeRead {
+					require.Equal(t, "2", string(event.Attributes[1].Value))
+				}
+			}
+			require.Equal(t, tc.runs, runs[1])
+		})
+	}
+}
+
+func TestReexecutionBudget(t *testing.T) {
+	increment := sdk.NewCoins(sdk.NewInt64Coin("stake", 1))
+	hot := make([][]byte, 6)
+	disjoint := make([][]byte, 6)
+	order := make([]int, len(hot))
+	for i := range hot {
+		hot[i] = NewBankTx("hot", increment)
+		disjoint[i] = NewBankTx(fmt.Sprintf("key%d", i), increment)
+		// every credit runs ahead of the ones it must read the balance of
+		order[i] = len(hot) - 1 - i
+	}
+
+	reference := setupTestApp(t, nil)
+	finalizeAndCommit(t, reference, 1, hot...)
+	require.Equal(t, ReexecutionInfo{Budget: -1}, reference.LastReexecutions())
+
+	for _, budget := range []int{-1, 0, 1} {
+		t.Run(fmt.Sprintf("budget %d", budget), func(t *testing.T) {
+			app := setupTestApp(t, nil, SetConcurrentExecution(len(hot)), SetReexecutionBudget(budget))
+			app.scheduleHook = orderedSchedule(order...)
+
+			resp := finalizeAndCommit(t, app, 1, hot...)
+			for i, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+			}
+			require.Equal(t, reference.LastCommitID().Hash, app.LastCommitID().Hash)
+			require.NoError(t, AssertStateEqual(reference, app))
+
+			info := app.LastReexecutions()
+			require.Equal(t, budget, info.Budget)
+			if budget >= 0 {
+				require.LessOrEqual(t, info.MaxPerTx, budget)
+			}
+			// all but the first credit need to be executed again, which a zero
+			// budget doesn't allow
+			if budget == 0 {
+				require.True(t, info.Exceeded)
+				require.Zero(t, info.Total)
+			} else {
+				require.False(t, info.Exceeded)
+				require.Equal(t, len(hot)-1, info.Total)
+			}
+
+			// txs touching disjoint keys never conflict
+			finalizeAndCommit(t, app, 2, disjoint...)
+			require.Equal(t, ReexecutionInfo{Budget: budget}, app.LastReexecutions())
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package mock
+
+import (
+	"sync"
+
+	dbm "github.com/tendermint/tm-db"
+)
+
+var _ dbm.DB = (*CrashDB)(nil)
+
+// CrashDB wraps a dbm.DB and simulates a machine crash losing the writes that
+// were never synced to disk. Writes are applied right away, but Crash reverts
+// every write issued since the last synced one. As with a write-ahead log, a
+// synced write makes every write before it durable too.
+type CrashDB struct {
+	dbm.DB
+
+	mtx  sync.Mutex
+	undo []crashUndo
+}
+
+// crashUndo restores the value a key held before an unsynced write.
+type crashUndo struct {
+	key     []byte
+	value   []byte
+	existed bool
+}
+
+// NewCrashDB returns a CrashDB wrapping db.
+func NewCrashDB(db dbm.DB) *CrashDB {
+	return &CrashDB{DB: db}
+}
+
+// Unsynced returns the number of writes a crash would currently lose.
+func (db *CrashDB) Unsynced() int {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	return len(db.undo)
+}
+
+// Crash reverts every write issued since the last synced one.
+func (db *CrashDB) Crash() error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	for i := len(db.undo) - 1; i >= 0; i-- {
+		u := db.undo[i]
+		var err error
+		if u.existed {
+			err = db.DB.SetSync(u.key, u.value)
+		} else {
+			err = db.DB.DeleteSync(u.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	db.undo = nil
+	return nil
+}
+
+// record saves the current value of key so an unsynced write to it can be
+// reverted. The caller must hold the lock.
+func (db *CrashDB) record(key []byte) error {
+	value, err := db.DB.Get(key)
+	if err != nil {
+		return err
+	}
+	db.undo = append(db.undo, crashUndo{key: append([]byte{}, key...), value: value, existed: value != nil})
+	return nil
+}
+
+// Set implements dbm.DB.
+func (db *CrashDB) Set(key, value []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if err := db.record(key); err != nil {
+		return err
+	}
+	return db.DB.Set(key, value)
+}
+
+// SetSync implements dbm.DB.
+func (db *CrashDB) SetSync(key, value []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if err := db.DB.SetSync(key, value); err != nil {
+		return err
+	}
+	db.undo = nil
+	return nil
+}
+
+// Delete implements dbm.DB.
+func (db *CrashDB) Delete(key []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if err := db.record(key); err != nil {
+		return err
+	}
+	return db.DB.Delete(key)
+}
+
+// DeleteSync implements dbm.DB.
+func (db *CrashDB) DeleteSync(key []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if err := db.DB.DeleteSync(key); err != nil {
+		return err
+	}
+	db.undo = nil
+	return nil
+}
+
+// NewBatch implements dbm.DB.
+func (db *CrashDB) NewBatch() dbm.Batch {
+	return &crashBatch{Batch: db.DB.NewBatch(), db: db}
+}
+
+// crashBatch records the keys of a batch, so its writes can be reverted once
+// it is written unsynced.
+type crashBatch struct {
+	dbm.Batch
+
+	db   *CrashDB
+	keys [][]byte
+}
+
+func (b *crashBatch) Set(key, value []byte) error {
+	b.keys = append(b.keys, append([]byte{}, key...))
+	return b.Batch.Set(key, value)
+}
+
+func (b *crashBatch) Delete(key []byte) error {
+	b.keys = append(b.keys, append([]byte{}, key...))
+	return b.Batch.Delete(key)
+}
+
+func (b *crashBatch) Write() error {
+	b.db.mtx.Lock()
+	defer b.db.mtx.Unlock()
+
+	for _, key := range b.keys {
+		if err := b.db.record(key); err != nil {
+			return err
+		}
+	}
+	return b.Batch.Write()
+}
+
+func (b *crashBatch) WriteSync() error {
+	b.db.mtx.Lock()
+	defer b.db.mtx.Unlock()
+
+	if err := b.Batch.WriteSync(); err != nil {
+		return err
+	}
+	b.db.undo = nil
+	return nil
+}
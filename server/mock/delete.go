@@ -0,0 +1,101 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// DeleteTxPrefix starts the bytes of a kvDeleteTx, i.e. "delete:a,b".
+const DeleteTxPrefix = "delete:"
+
+// kvDeleteTx deletes keys of the main store, absent keys included. It is an
+// sdk.Tx which is its own sdk.Msg.
+type kvDeleteTx struct {
+	keys  [][]byte
+	bytes []byte
+}
+
+// dummy implementation of proto.Message
+func (msg kvDeleteTx) Reset()         {}
+func (msg kvDeleteTx) String() string { return "TODO" }
+func (msg kvDeleteTx) ProtoMessage()  {}
+
+var _ sdk.Tx = kvDeleteTx{}
+var _ sdk.Msg = kvDeleteTx{}
+
+// NewDeleteTx returns the bytes of a tx deleting keys.
+func NewDeleteTx(keys ...string) []byte {
+	return []byte(DeleteTxPrefix + strings.Join(keys, ","))
+}
+
+func (tx kvDeleteTx) Route() string {
+	return "delete"
+}
+
+func (tx kvDeleteTx) Type() string {
+	return "delete_tx"
+}
+
+func (tx kvDeleteTx) GetMsgs() []sdk.Msg {
+	return []sdk.Msg{tx}
+}
+
+func (tx kvDeleteTx) GetMemo() string {
+	return ""
+}
+
+func (tx kvDeleteTx) GetSignBytes() []byte {
+	return tx.bytes
+}
+
+// ValidateBasic rejects deletes of the keys reserved for the app.
+func (tx kvDeleteTx) ValidateBasic() error {
+	for _, key := range tx.keys {
+		if bytes.HasPrefix(key, []byte(ReservedKeyPrefix)) {
+			return sdkerrors.Wrapf(ErrReservedKey, "key %s", key)
+		}
+	}
+	return nil
+}
+
+func (tx kvDeleteTx) GetSigners() []sdk.AccAddress {
+	return nil
+}
+
+func (tx kvDeleteTx) GetGasEstimate() uint64 {
+	return 0
+}
+
+// decodeDeleteTx decodes the bytes of a kvDeleteTx, DeleteTxPrefix included.
+func decodeDeleteTx(txBytes []byte) (sdk.Tx, error) {
+	tx := kvDeleteTx{bytes: txBytes}
+	for _, key := range strings.Split(string(txBytes[len(DeleteTxPrefix):]), ",") {
+		if len(key) == 0 {
+			return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "empty key")
+		}
+		tx.keys = append(tx.keys, []byte(key))
+	}
+	return tx, nil
+}
+
+// deleteHandler deletes the keys of a kvDeleteTx.
+func (app *App) deleteHandler(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+	dTx, ok := msg.(kvDeleteTx)
+	if !ok {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrUnknownRequest, "unexpected delete msg %T", msg)
+	}
+
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	if err := app.checkWriteOnce(store, dTx.keys...); err != nil {
+		return nil, err
+	}
+	for _, key := range dTx.keys {
+		store.Delete(key)
+	}
+
+	return &sdk.Result{Log: fmt.Sprintf("deleted %d keys", len(dTx.keys))}, nil
+}
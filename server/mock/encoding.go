@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Encoding is how a key or value is represented as text in the params and
+// responses of the "/mock/kv" query. The byte fields of the other "/mock"
+// queries are always base64 encoded, as JSON encodes byte slices.
+type Encoding string
+
+const (
+	// EncodingRaw takes the text as the bytes themselves.
+	EncodingRaw Encoding = "raw"
+	// EncodingHex hex encodes the bytes.
+	EncodingHex Encoding = "hex"
+	// EncodingBase64 base64 encodes the bytes with the standard padded
+	// alphabet, as JSON encodes byte slices.
+	EncodingBase64 Encoding = "base64"
+)
+
+// Encode returns the text representation of bz.
+func (e Encoding) Encode(bz []byte) (string, error) {
+	switch e {
+	case EncodingRaw:
+		return string(bz), nil
+	case EncodingHex:
+		return hex.EncodeToString(bz), nil
+	case "", EncodingBase64:
+		return base64.StdEncoding.EncodeToString(bz), nil
+	default:
+		return "", fmt.Errorf("unknown encoding %q", e)
+	}
+}
+
+// Decode returns the bytes represented by s.
+func (e Encoding) Decode(s string) ([]byte, error) {
+	switch e {
+	case EncodingRaw:
+		return []byte(s), nil
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case "", EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", e)
+	}
+}
@@ -0,0 +1,26 @@
+package mock
+
+import (
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// Codespace is the codespace of the errors returned by the mock app.
+const Codespace = "mock"
+
+// mock app sentinel errors
+var (
+	ErrKeyInFlight      = sdkerrors.Register(Codespace, 2, "key is targeted by a tx already in the mempool")
+	ErrRateLimited      = sdkerrors.Register(Codespace, 3, "tx execution rate limit exceeded")
+	ErrReservedKey      = sdkerrors.Register(Codespace, 4, "key is reserved for the app")
+	ErrMsgOutOfGas      = sdkerrors.Register(Codespace, 5, "msg ran out of gas")
+	ErrNoopTx           = sdkerrors.Register(Codespace, 6, "tx does not change the committed state")
+	ErrInvalidUpgrade   = sdkerrors.Register(Codespace, 7, "invalid app version upgrade")
+	ErrDuplicateTx      = sdkerrors.Register(Codespace, 8, "tx is a duplicate of an earlier tx of the block")
+	ErrBlockTxLimit     = sdkerrors.Register(Codespace, 9, "block tx limit exceeded")
+	ErrInvalidValUpdate = sdkerrors.Register(Codespace, 10, "invalid validator update")
+	ErrValueTooLarge    = sdkerrors.Register(Codespace, 11, "value exceeds the maximum size")
+	ErrImmutableKey     = sdkerrors.Register(Codespace, 12, "key is write-once and already set")
+	ErrBlockPending     = sdkerrors.Register(Codespace, 13, "a block is finalized but not committed")
+	ErrUnsetKey         = sdkerrors.Register(Codespace, 14, "key has not been set")
+	ErrSupplyInvariant  = sdkerrors.Register(Codespace, 15, "total balance does not match the supply")
+)
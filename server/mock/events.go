@@ -0,0 +1,184 @@
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	// DefaultTxEventIndexSize is the number of txs whose events are retained
+	// by the app when no explicit size is configured.
+	DefaultTxEventIndexSize = 1000
+
+	EventTypeKVStore   = "kvstore"
+	EventTypeInitChain = "init_chain"
+	EventTypeRead      = "read"
+	AttributeKeyKey    = "key"
+	AttributeKeyValue  = "value"
+
+	// AttributeKeyFound is set on read events to whether the key is present,
+	// the value attribute being empty for both an absent key and one holding
+	// an empty value.
+	AttributeKeyFound = "found"
+
+	// EventTypeInterrupted is emitted as a block event when FinalizeBlock is
+	// cancelled before executing every tx, its attributes counting the txs
+	// the block has results for and the txs it was proposed with.
+	EventTypeInterrupted = "block_interrupted"
+	AttributeKeyExecuted = "executed"
+	AttributeKeyTotal    = "total"
+
+	// EventTypeLastAppHash is emitted as a block event when enabled with
+	// SetAppHashEvents, carrying the app hash committed by the previous
+	// block and its height.
+	EventTypeLastAppHash = "last_app_hash"
+	AttributeKeyHeight   = "height"
+	AttributeKeyAppHash  = "app_hash"
+)
+
+// Cache multistore boundaries reported to the observer set with SetOnCacheEvent.
+const (
+	// CacheEventWrap is reported when a block starts executing on the deliver
+	// state, which is a cache branched off the commit multistore.
+	CacheEventWrap = "wrap"
+	// CacheEventWrite is reported right before the deliver state cache is
+	// written back to the commit multistore.
+	CacheEventWrite = "write"
+	// CacheEventCommit is reported once the commit multistore is committed.
+	CacheEventCommit = "commit"
+)
+
+// TxEvents returns the events emitted by the i-th tx of a finalized block, or
+// nil if the block has no such tx.
+func TxEvents(resp *abci.ResponseFinalizeBlock, i int) []abci.Event {
+	if resp == nil || i < 0 || i >= len(resp.TxResults) || resp.TxResults[i] == nil {
+		return nil
+	}
+	return resp.TxResults[i].Events
+}
+
+// AssertEvents compares the events emitted by a finalized block, its block
+// events and the events of every tx result, with the expected ones and
+// returns an error listing the events missing and the unexpected ones. Events
+// are compared as a multiset, ignoring their order, the order of their
+// attributes and whether attributes are indexed.
+func AssertEvents(resp *abci.ResponseFinalizeBlock, expected []abci.Event) error {
+	var emitted []abci.Event
+	if resp != nil {
+		emitted = append(emitted, resp.Events...)
+		for _, res := range resp.TxResults {
+			if res != nil {
+				emitted = append(emitted, res.Events...)
+			}
+		}
+	}
+
+	pending := make(map[string]int, len(expected))
+	for _, event := range expected {
+		pending[formatEvent(event)]++
+	}
+	var unexpected []string
+	for _, event := range emitted {
+		key := formatEvent(event)
+		if pending[key] == 0 {
+			unexpected = append(unexpected, key)
+			continue
+		}
+		pending[key]--
+	}
+	var missing []string
+	for key, n := range pending {
+		for ; n > 0; n-- {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	var sb strings.Builder
+	sb.WriteString("events mismatch")
+	for _, key := range missing {
+		fmt.Fprintf(&sb, "\n- %s", key)
+	}
+	for _, key := range unexpected {
+		fmt.Fprintf(&sb, "\n+ %s", key)
+	}
+	return errors.New(sb.String())
+}
+
+// formatEvent returns the canonical form of event AssertEvents compares,
+// i.e. "type{k1=v1, k2=v2}" with the attributes sorted.
+func formatEvent(event abci.Event) string {
+	attrs := make([]string, len(event.Attributes))
+	for i, attr := range event.Attributes {
+		attrs[i] = fmt.Sprintf("%s=%s", attr.Key, attr.Value)
+	}
+	sort.Strings(attrs)
+	return fmt.Sprintf("%s{%s}", event.Type, strings.Join(attrs, ", "))
+}
+
+// BlockEvent is an event emitted by the tx at TxIndex in its block.
+type BlockEvent struct {
+	TxIndex int        `json:"tx_index"`
+	Event   abci.Event `json:"event"`
+}
+
+// txEventIndex maps tx hashes to the events they emitted. It is a fixed-size
+// ring buffer so that long-running tests don't grow it unbounded.
+type txEventIndex struct {
+	mtx sync.RWMutex
+
+	events map[[32]byte][]abci.Event
+	ring   [][32]byte
+	next   int
+	full   bool
+}
+
+func newTxEventIndex(size int) *txEventIndex {
+	if size <= 0 {
+		size = DefaultTxEventIndexSize
+	}
+	return &txEventIndex{
+		events: make(map[[32]byte][]abci.Event, size),
+		ring:   make([][32]byte, size),
+	}
+}
+
+func (idx *txEventIndex) add(hash [32]byte, events []abci.Event) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	// re-indexing a known hash only refreshes its events, it doesn't take a
+	// new slot in the ring
+	if _, ok := idx.events[hash]; ok {
+		idx.events[hash] = events
+		return
+	}
+
+	if idx.full {
+		delete(idx.events, idx.ring[idx.next])
+	}
+	idx.ring[idx.next] = hash
+	idx.events[hash] = events
+
+	idx.next = (idx.next + 1) % len(idx.ring)
+	if idx.next == 0 {
+		idx.full = true
+	}
+}
+
+func (idx *txEventIndex) get(hash [32]byte) ([]abci.Event, bool) {
+	idx.mtx.RLock()
+	defer idx.mtx.RUnlock()
+
+	events, ok := idx.events[hash]
+	return events, ok
+}
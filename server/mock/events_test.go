@@ -0,0 +1,123 @@
+package mock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestTxEventIndex(t *testing.T) {
+	app := setupTestApp(t, nil)
+	goCtx := context.Background()
+
+	txs := [][]byte{[]byte("a=1"), []byte("b=2")}
+	resp := finalizeAndCommit(t, app, 1, txs...)
+
+	require.Nil(t, TxEvents(resp, -1))
+	require.Nil(t, TxEvents(resp, len(txs)))
+
+	for i, tx := range txs {
+		events := TxEvents(resp, i)
+		require.NotEmpty(t, events)
+
+		hash := sha256.Sum256(tx)
+		qres, err := app.Query(goCtx, &abci.RequestQuery{Path: "/mock/txevents", Data: hash[:]})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+
+		var indexed []abci.Event
+		require.NoError(t, json.Unmarshal(qres.Value, &indexed))
+		require.Equal(t, events, indexed)
+	}
+
+	// unknown hashes are reported as not found
+	hash := sha256.Sum256([]byte("c=3"))
+	qres, err := app.Query(goCtx, &abci.RequestQuery{Path: "/mock/txevents", Data: hash[:]})
+	require.NoError(t, err)
+	require.NotEqual(t, uint32(0), qres.Code)
+}
+
+func TestTxEventIndexEviction(t *testing.T) {
+	idx := newTxEventIndex(2)
+
+	a, b, c := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b")), sha256.Sum256([]byte("c"))
+	events := []abci.Event{{Type: EventTypeKVStore}}
+
+	idx.add(a, events)
+	idx.add(b, events)
+	idx.add(c, events)
+
+	_, ok := idx.get(a)
+	require.False(t, ok, "oldest entry should have been evicted")
+	_, ok = idx.get(b)
+	require.True(t, ok)
+	_, ok = idx.get(c)
+	require.True(t, ok)
+	require.Len(t, idx.events, 2)
+}
+
+func TestCacheEvents(t *testing.T) {
+	var seen []string
+	app := setupTestApp(t, nil, SetOnCacheEvent(func(kind string, height int64) {
+		seen = append(seen, fmt.Sprintf("%s@%d", kind, height))
+	}))
+
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+	finalizeAndCommit(t, app, 2, []byte("b=2"))
+
+	require.Equal(t, []string{
+		"wrap@1", "write@1", "commit@1",
+		"wrap@2", "write@2", "commit@2",
+	}, seen)
+}
+
+func TestInitChainEvents(t *testing.T) {
+	app := setupTestApp(t, []KV{{"a", "1"}, {"b", "2"}})
+
+	events := app.InitChainEvents()
+	require.Len(t, events, 2)
+	for i, kv := range []KV{{"a", "1"}, {"b", "2"}} {
+		require.Equal(t, EventTypeInitChain, events[i].Type)
+		require.Equal(t, []abci.EventAttribute{
+			{Key: []byte(AttributeKeyKey), Value: []byte(kv.Key)},
+			{Key: []byte(AttributeKeyValue), Value: []byte(kv.Value)},
+		}, events[i].Attributes)
+	}
+}
+
+func TestAssertEvents(t *testing.T) {
+	app := setupTestApp(t, nil)
+	resp := finalizeAndCommit(t, app, 1, []byte("a=1"), NewReadTx("a"))
+
+	kvEvent := func(typ, key, value string) abci.Event {
+		return abci.Event{Type: typ, Attributes: []abci.EventAttribute{
+			{Key: []byte(AttributeKeyKey), Value: []byte(key)},
+			{Key: []byte(AttributeKeyValue), Value: []byte(value)},
+		}}
+	}
+	action := func(name string) abci.Event {
+		return abci.Event{Type: "message", Attributes: []abci.EventAttribute{{Key: []byte("action"), Value: []byte(name)}}}
+	}
+	read := kvEvent(EventTypeRead, "a", "1")
+	read.Attributes = append(read.Attributes, abci.EventAttribute{Key: []byte(AttributeKeyFound), Value: []byte("true")})
+	// attributes are listed in any order
+	read.Attributes[0], read.Attributes[2] = read.Attributes[2], read.Attributes[0]
+
+	// so are events
+	expected := []abci.Event{read, action("read_tx"), kvEvent(EventTypeKVStore, "a", "1"), action("kvstore_tx")}
+	require.NoError(t, AssertEvents(resp, expected))
+
+	err := AssertEvents(resp, append(expected[1:], kvEvent(EventTypeKVStore, "b", "2")))
+	require.EqualError(t, err, "events mismatch\n"+
+		"- kvstore{key=b, value=2}\n"+
+		"+ read{found=true, key=a, value=1}")
+
+	// duplicates count
+	require.Error(t, AssertEvents(resp, append(expected, read)))
+	require.NoError(t, AssertEvents(nil, nil))
+}
@@ -0,0 +1,130 @@
+package mock
+
+import (
+	"errors"
+	"sync"
+
+	dbm "github.com/tendermint/tm-db"
+)
+
+// ErrInjectedFault is returned by a FaultDB once its write budget is spent.
+var ErrInjectedFault = errors.New("mock: injected db write fault")
+
+var _ dbm.DB = (*FaultDB)(nil)
+
+// FaultDB wraps a dbm.DB and fails every write once a configured number of
+// writes went through, simulating e.g. a full disk. Writes issued through
+// batches are counted per operation when the batch is written. A negative
+// budget disables fault injection.
+type FaultDB struct {
+	dbm.DB
+
+	mtx       sync.Mutex
+	writes    int
+	failAfter int
+}
+
+// NewFaultDB returns a FaultDB allowing failAfter writes to db before failing.
+func NewFaultDB(db dbm.DB, failAfter int) *FaultDB {
+	return &FaultDB{DB: db, failAfter: failAfter}
+}
+
+// SetFailAfter resets the write budget of the db, counting from the writes
+// already performed.
+func (db *FaultDB) SetFailAfter(failAfter int) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.failAfter = db.writes + failAfter
+}
+
+// Writes returns the number of writes that have gone through the db.
+func (db *FaultDB) Writes() int {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	return db.writes
+}
+
+// consume reserves n writes, returning ErrInjectedFault if that would exceed
+// the write budget.
+func (db *FaultDB) consume(n int) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if db.failAfter >= 0 && db.writes+n > db.failAfter {
+		return ErrInjectedFault
+	}
+	db.writes += n
+	return nil
+}
+
+// Set implements dbm.DB.
+func (db *FaultDB) Set(key, value []byte) error {
+	if err := db.consume(1); err != nil {
+		return err
+	}
+	return db.DB.Set(key, value)
+}
+
+// SetSync implements dbm.DB.
+func (db *FaultDB) SetSync(key, value []byte) error {
+	if err := db.consume(1); err != nil {
+		return err
+	}
+	return db.DB.SetSync(key, value)
+}
+
+// Delete implements dbm.DB.
+func (db *FaultDB) Delete(key []byte) error {
+	if err := db.consume(1); err != nil {
+		return err
+	}
+	return db.DB.Delete(key)
+}
+
+// DeleteSync implements dbm.DB.
+func (db *FaultDB) DeleteSync(key []byte) error {
+	if err := db.consume(1); err != nil {
+		return err
+	}
+	return db.DB.DeleteSync(key)
+}
+
+// NewBatch implements dbm.DB.
+func (db *FaultDB) NewBatch() dbm.Batch {
+	return &faultBatch{Batch: db.DB.NewBatch(), db: db}
+}
+
+// faultBatch counts the operations of a batch against the FaultDB budget when
+// the batch is written, so a failing batch is never partially applied.
+type faultBatch struct {
+	dbm.Batch
+
+	db  *FaultDB
+	ops int
+}
+
+func (b *faultBatch) Set(key, value []byte) error {
+	b.ops++
+	return b.Batch.Set(key, value)
+}
+
+func (b *faultBatch) Delete(key []byte) error {
+	b.ops++
+	return b.Batch.Delete(key)
+}
+
+func (b *faultBatch) Write() error {
+	if err := b.db.consume(b.ops); err != nil {
+		return err
+	}
+	return b.Batch.Write()
+}
+
+func (b *faultBatch) WriteSync() error {
+	if err := b.db.consume(b.ops); err != nil {
+		return err
+	}
+	return b.Batch.WriteSync()
+}
@@ -0,0 +1,71 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestFaultDBCommit(t *testing.T) {
+	dir := t.TempDir()
+	goCtx := context.Background()
+
+	var fdb *FaultDB
+	app, err := NewApp(dir, log.NewNopLogger(), SetDBWrapper(func(db dbm.DB) dbm.DB {
+		fdb = NewFaultDB(db, -1)
+		return fdb
+	}))
+	require.NoError(t, err)
+	_, err = app.InitChain(goCtx, &abci.RequestInitChain{AppStateBytes: []byte(`{"values":[]}`)})
+	require.NoError(t, err)
+
+	finalizeAndCommit(t, app, 1, []byte("good=1"))
+	require.Positive(t, fdb.Writes())
+
+	// let a single write through before the db starts failing
+	fdb.SetFailAfter(1)
+	_, err = app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: 2, Txs: [][]byte{[]byte("bad=2")}})
+	require.NoError(t, err)
+	_, err = app.Commit(goCtx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrInjectedFault.Error())
+	require.Equal(t, int64(1), app.(*App).LastBlockHeight())
+
+	// the state on disk is still the one of the last good height
+	require.NoError(t, app.(*App).Close())
+	app, err = NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), app.(*App).LastBlockHeight())
+
+	qres, err := app.Query(goCtx, &abci.RequestQuery{Path: "/store/main/key", Data: []byte("good")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), qres.Value)
+	qres, err = app.Query(goCtx, &abci.RequestQuery{Path: "/store/main/key", Data: []byte("bad")})
+	require.NoError(t, err)
+	require.Nil(t, qres.Value)
+}
+
+func TestFaultDBBatch(t *testing.T) {
+	fdb := NewFaultDB(dbm.NewMemDB(), 2)
+
+	batch := fdb.NewBatch()
+	require.NoError(t, batch.Set([]byte("a"), []byte("1")))
+	require.NoError(t, batch.Set([]byte("b"), []byte("2")))
+	require.NoError(t, batch.Set([]byte("c"), []byte("3")))
+	require.ErrorIs(t, batch.Write(), ErrInjectedFault)
+	require.NoError(t, batch.Close())
+
+	// a failed batch is not applied at all and does not consume the budget
+	ok, err := fdb.Has([]byte("a"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, 0, fdb.Writes())
+
+	require.NoError(t, fdb.Set([]byte("a"), []byte("1")))
+	require.NoError(t, fdb.Delete([]byte("a")))
+	require.ErrorIs(t, fdb.Set([]byte("b"), []byte("2")), ErrInjectedFault)
+}
@@ -0,0 +1,128 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// FeeTxPrefix starts the bytes of a tx naming the account paying its fee
+// ahead of the tx itself, i.e. "fee:alice;key=value". With SetFlatFee, the
+// fee is moved from the balance of that account to the one of FeeCollector.
+const FeeTxPrefix = "fee:"
+
+// feePayerSeparator ends the fee payer of a tx.
+const feePayerSeparator = ';'
+
+// FeeCollector is the account the fees paid by txs are credited to, its
+// balance being stored under BalanceKeyPrefix like any other.
+const FeeCollector = "fee_collector"
+
+// feeTx is a tx along with the account paying its fee. Its msgs are the ones
+// of the wrapped tx.
+type feeTx struct {
+	sdk.Tx
+	payer string
+}
+
+// NewTxWithFeePayer returns the bytes of tx with its fee paid by payer.
+func NewTxWithFeePayer(payer string, tx []byte) []byte {
+	return []byte(fmt.Sprintf("%s%s%c%s", FeeTxPrefix, payer, feePayerSeparator, tx))
+}
+
+// decodeFeeTx decodes the bytes of a feeTx, FeeTxPrefix included. The hints
+// of a tx go ahead of its fee payer, so the wrapped tx can't be hinted.
+func decodeFeeTx(txBytes []byte) (sdk.Tx, error) {
+	rest := txBytes[len(FeeTxPrefix):]
+	i := bytes.IndexByte(rest, feePayerSeparator)
+	if i < 0 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "expected payer;tx")
+	}
+	if i == 0 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "empty fee payer")
+	}
+	inner := rest[i+1:]
+	if bytes.HasPrefix(inner, []byte(FeeTxPrefix)) || bytes.HasPrefix(inner, []byte(HintsTxPrefix)) {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrTxDecode, "fee payer ahead of %q", inner)
+	}
+
+	tx := feeTx{payer: string(rest[:i])}
+	var err error
+	if tx.Tx, err = decodeTx(inner); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// feePayer returns the account paying the fee of tx, empty if it names none.
+func feePayer(tx sdk.Tx) string {
+	if hTx, ok := tx.(hintedTx); ok {
+		tx = hTx.Tx
+	}
+	if fTx, ok := tx.(feeTx); ok {
+		return fTx.payer
+	}
+	return ""
+}
+
+// deductFee moves the flat fee from the balance of the fee payer of tx to the
+// one of FeeCollector. A tx naming no payer, or one whose balance doesn't
+// cover the fee, fails with ErrInsufficientFee and ErrInsufficientFunds
+// respectively. It runs in the ante handler, so the fee is paid whether the
+// msgs of the tx succeed or not. As every tx updates the collector, txs
+// executed concurrently all conflict with each other.
+func (app *App) deductFee(ctx sdk.Context, tx sdk.Tx) error {
+	payer := feePayer(tx)
+	if payer == "" {
+		return sdkerrors.Wrapf(sdkerrors.ErrInsufficientFee, "no fee payer for a fee of %s", app.flatFee)
+	}
+
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	payerKey := []byte(BalanceKeyPrefix + payer)
+	balance, err := sdk.ParseCoinsNormalized(string(store.Get(payerKey)))
+	if err != nil {
+		return sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid stored balance of %s: %s", payer, err)
+	}
+	remaining, negative := balance.SafeSub(app.flatFee)
+	if negative {
+		return sdkerrors.Wrapf(sdkerrors.ErrInsufficientFunds, "balance %s of %s doesn't cover a fee of %s", balance, payer, app.flatFee)
+	}
+	store.Set(payerKey, []byte(remaining.String()))
+
+	collectorKey := []byte(BalanceKeyPrefix + FeeCollector)
+	collected, err := sdk.ParseCoinsNormalized(string(store.Get(collectorKey)))
+	if err != nil {
+		return sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid stored balance of %s: %s", FeeCollector, err)
+	}
+	store.Set(collectorKey, []byte(collected.Add(app.flatFee...).String()))
+	return nil
+}
+
+// handleQueryFees returns the JSON encoded balance of FeeCollector, the fees
+// collected up to the requested height.
+func (app *App) handleQueryFees(req abci.RequestQuery) abci.ResponseQuery {
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	collected, err := sdk.ParseCoinsNormalized(string(ctx.KVStore(app.capKeyMainStore).Get([]byte(BalanceKeyPrefix + FeeCollector))))
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid stored balance of %s: %s", FeeCollector, err))
+	}
+	bz, err := json.Marshal(collected)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode collected fees"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Value:     bz,
+	}
+}
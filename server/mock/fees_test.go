@@ -0,0 +1,108 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func queryFees(t *testing.T, app abci.Application, height int64) sdk.Coins {
+	t.Helper()
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/fees", Height: height})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	var fees sdk.Coins
+	require.NoError(t, json.Unmarshal(qres.Value, &fees))
+	return fees
+}
+
+func TestFlatFee(t *testing.T) {
+	fee := sdk.NewCoins(sdk.NewInt64Coin("stake", 3))
+	genesis := []KV{{BalanceKeyPrefix + "alice", "10stake"}}
+	blocks := [][][]byte{
+		{
+			NewTxWithFeePayer("alice", []byte("a=1")),
+			NewHintedTx(NewTxWithFeePayer("alice", []byte("b=2")), "b"),
+			// bob has nothing to pay with, and the tx after it names no payer
+			NewTxWithFeePayer("bob", []byte("c=3")),
+			[]byte("d=4"),
+			// a tx whose msg fails still pays the fee
+			NewTxWithFeePayer("alice", []byte(ReservedKeyPrefix+"x=1")),
+		},
+		// alice is left with less than the fee
+		{NewTxWithFeePayer("alice", []byte("e=5"))},
+	}
+
+	for name, opts := range map[string][]Option{
+		"sequential": {SetFlatFee(fee)},
+		"concurrent": {SetFlatFee(fee), SetConcurrentExecution(4)},
+		// fees move coins between balances, the supply is left as is
+		"supply invariant": {SetFlatFee(fee), SetSupplyInvariant(true)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, genesis, opts...)
+			require.True(t, queryFees(t, app, 0).IsZero())
+
+			responses, err := RunBlocks(app, blocks)
+			require.NoError(t, err)
+			codes := make([]uint32, len(responses[0].TxResults))
+			for i, res := range responses[0].TxResults {
+				codes[i] = res.Code
+			}
+			require.Equal(t, []uint32{
+				0, 0,
+				sdkerrors.ErrInsufficientFunds.ABCICode(),
+				sdkerrors.ErrInsufficientFee.ABCICode(),
+				ErrReservedKey.ABCICode(),
+			}, codes)
+			require.Equal(t, sdkerrors.ErrInsufficientFunds.ABCICode(), responses[1].TxResults[0].Code)
+
+			// a tx failing in the ante handler neither pays nor executes
+			require.Nil(t, queryStore(t, app, "main", "c", 1).Value)
+			require.Nil(t, queryStore(t, app, "main", "d", 1).Value)
+			require.Equal(t, []byte("2"), queryStore(t, app, "main", "b", 1).Value)
+
+			for _, height := range []int64{1, 2} {
+				require.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("stake", 3*3)), queryFees(t, app, height))
+				require.Equal(t, "9stake", string(queryStore(t, app, "main", BalanceKeyPrefix+FeeCollector, height).Value))
+				require.Equal(t, "1stake", string(queryStore(t, app, "main", BalanceKeyPrefix+"alice", height).Value))
+				require.Nil(t, queryStore(t, app, "main", BalanceKeyPrefix+"bob", height).Value)
+			}
+
+			// CheckTx rejects a tx that can't pay as well
+			res := checkTx(t, app, NewTxWithFeePayer("alice", []byte("f=6")))
+			require.Equal(t, sdkerrors.ErrInsufficientFunds.ABCICode(), res.Code, res.Log)
+		})
+	}
+
+	// without a flat fee nothing is collected, and txs need no payer
+	app := setupTestApp(t, genesis)
+	responses, err := RunBlocks(app, blocks)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), responses[0].TxResults[3].Code)
+	require.True(t, queryFees(t, app, 0).IsZero())
+	require.Equal(t, "10stake", string(queryStore(t, app, "main", BalanceKeyPrefix+"alice", 2).Value))
+}
+
+func TestDecodeFeeTx(t *testing.T) {
+	tx, err := decodeTx(NewTxWithFeePayer("alice", []byte("a=1")))
+	require.NoError(t, err)
+	require.Equal(t, "alice", feePayer(tx))
+	require.Equal(t, []byte("a"), unwrapTx(tx).(kvstoreTx).key)
+
+	tx, err = decodeTx(NewHintedTx(NewTxWithFeePayer("alice", []byte("a=1")), "a"))
+	require.NoError(t, err)
+	require.Equal(t, "alice", feePayer(tx))
+	require.Equal(t, []byte("a"), unwrapTx(tx).(kvstoreTx).key)
+
+	for _, txBytes := range []string{"fee:", "fee:alice", "fee:;a=1", "fee:a;fee:b;c=1", "fee:a;hints:b;b=1", "fee:a;"} {
+		_, err := decodeTx([]byte(txBytes))
+		require.Error(t, err, "tx %q", txBytes)
+	}
+}
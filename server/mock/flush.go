@@ -0,0 +1,53 @@
+package mock
+
+import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// FlushPolicy sets when the writes of a block are flushed from the deliver
+// state cache to the commit multistore.
+type FlushPolicy int
+
+const (
+	// FlushPerBlock buffers the writes of a block in the deliver state cache
+	// and flushes them once, when the block is committed. It is the default.
+	FlushPerBlock FlushPolicy = iota
+	// FlushPerTx flushes the writes of every tx as soon as it has executed,
+	// so the cache never holds more than one tx. It only applies to
+	// sequential execution, concurrent blocks are always flushed per block.
+	//
+	// Flushed writes bypass the deliver state cache and can't be discarded
+	// with it: they stay in the commit multistore even if the block is never
+	// committed, e.g. when it is finalized again or FinalizeBlock fails after
+	// its txs have executed. NewApp therefore rejects FlushPerTx together with
+	// SetSupplyInvariant and SetTxCounting, which can fail a block that way.
+	FlushPerTx
+)
+
+// checkFlushPolicy returns an error when the flush policy can't be combined
+// with the other options of the app.
+func (app *App) checkFlushPolicy() error {
+	if app.flushPolicy != FlushPerTx {
+		return nil
+	}
+	if app.supplyInvariant {
+		return fmt.Errorf("FlushPerTx cannot be combined with the supply invariant")
+	}
+	if app.txCounting {
+		return fmt.Errorf("FlushPerTx cannot be combined with tx counting")
+	}
+	return nil
+}
+
+// flushTx writes the deliver state cache of ctx through to the commit
+// multistore when txs are flushed one by one.
+func (app *App) flushTx(ctx sdk.Context) {
+	if app.flushPolicy != FlushPerTx {
+		return
+	}
+	if cms, ok := ctx.MultiStore().(sdk.CacheMultiStore); ok {
+		cms.Write()
+	}
+}
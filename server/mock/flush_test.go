@@ -0,0 +1,85 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestFlushPolicy(t *testing.T) {
+	blocks := [][][]byte{
+		{[]byte("a=1"), []byte("b=2"), []byte(PanicKey + "=boom")},
+		{[]byte("a=3"), NewSwapTx("a", "b"), []byte("c=4")},
+	}
+
+	reference := setupTestApp(t, nil)
+	_, err := RunBlocks(reference, blocks)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		policy  FlushPolicy
+		flushed bool
+	}{
+		"per block": {FlushPerBlock, false},
+		"per tx":    {FlushPerTx, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, SetFlushPolicy(tc.policy))
+			_, err := RunBlocks(app, blocks)
+			require.NoError(t, err)
+			require.Equal(t, reference.LastCommitID(), app.LastCommitID())
+			require.NoError(t, AssertStateEqual(reference, app))
+
+			// the writes of a flushed tx reach the commit multistore before
+			// the block is committed
+			_, err = app.FinalizeBlock(context.Background(), &abci.RequestFinalizeBlock{Height: 3, Txs: [][]byte{[]byte("d=5")}})
+			require.NoError(t, err)
+			value := app.CommitMultiStore().GetKVStore(app.capKeyMainStore).Get([]byte("d"))
+			if tc.flushed {
+				require.Equal(t, []byte("5"), value)
+			} else {
+				require.Nil(t, value)
+			}
+			_, err = app.Commit(context.Background())
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFlushPerTxRejectedOptions(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"supply invariant": SetSupplyInvariant(true),
+		"tx counting":      SetTxCounting(true),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// a block failing its invariant would leave its flushed writes
+			// behind in the commit multistore
+			_, err := NewApp(t.TempDir(), log.NewNopLogger(), SetFlushPolicy(FlushPerTx), opt)
+			require.Error(t, err)
+
+			app, err := NewApp(t.TempDir(), log.NewNopLogger(), SetFlushPolicy(FlushPerBlock), opt)
+			require.NoError(t, err)
+			require.NoError(t, app.(*App).Close())
+		})
+	}
+}
+
+func BenchmarkFlushPolicy(b *testing.B) {
+	txs := make([][]byte, 100)
+	for i := range txs {
+		txs[i] = []byte(fmt.Sprintf("key%03d=value", i))
+	}
+
+	for name, policy := range map[string]FlushPolicy{
+		"per block": FlushPerBlock,
+		"per tx":    FlushPerTx,
+	} {
+		b.Run(name, func(b *testing.B) {
+			benchmarkBlocks(b, txs, SetFlushPolicy(policy))
+		})
+	}
+}
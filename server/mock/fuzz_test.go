@@ -0,0 +1,135 @@
+package mock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{
+		"", "=", "k", "k=v", "=v", "k=", "a=b=c", "k=v#meta", "#", "a=1&b=2", "a&&b",
+		TimeKey, BlockGasKey + "=x",
+		"bank:", "bank:a=1x", "bank:=1x", "bank:a=x,-1y",
+		"swap:", "swap:a,b", "swap:a,", "swap:a,b,c",
+		"read:", "read:a", "read:a,,b",
+		"hints:", "hints:a;", "hints:a;b=1", "hints:,a;read:a", "hints:a;hints:b;c",
+		"delete:", "delete:a", "delete:a,,b",
+		"gas:", "gas:x;a=1", "gas:0;a=1", "gas:10;a=1", "gas:10;gas:10;a=1", "gas:10;read:a",
+		"fee:", "fee:a;b=1", "fee:;b=1", "fee:a;fee:b;c=1", "fee:a;hints:b;b=1", "hints:a;fee:b;a=1", "fee:a;gas:10;b=1",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkDecodeTx(t, data)
+	})
+}
+
+// checkDecodeTx feeds data into the tx decoder and fails t if the decoder
+// breaks its contract. The decoder returns either a tx decode error or one of:
+//
+//   - a kvstoreTx with a non-empty key, with a gas limit if and only if the
+//     input starts with GasTxPrefix
+//   - a kvBankTx with a non-empty recipient
+//   - a kvSwapTx with two non-empty keys
+//   - a kvReadTx or kvDeleteTx with at least one key, none of them empty
+//   - a feeTx with a non-empty payer, wrapping a tx that is neither a feeTx
+//     nor a hintedTx
+//   - a hintedTx with at least one hinted key, none of them empty, wrapping
+//     a tx that isn't hinted itself
+//
+// Every tx but a feeTx or hintedTx carries the input bytes. It returns whether
+// data decoded into a tx.
+func checkDecodeTx(t *testing.T, data []byte) bool {
+	t.Helper()
+
+	tx, err := decodeTx(data)
+	if err != nil {
+		if tx != nil {
+			t.Fatalf("decodeTx returned both a tx and an error: %v", err)
+		}
+		if !sdkerrors.ErrTxDecode.Is(err) {
+			t.Fatalf("decodeTx returned an unexpected error: %v", err)
+		}
+		return false
+	}
+
+	switch tx := tx.(type) {
+	case kvstoreTx:
+		if len(tx.key) == 0 {
+			t.Fatal("decodeTx returned a tx with an empty key")
+		}
+		if !bytes.Equal(tx.bytes, data) {
+			t.Fatal("decodeTx returned a tx not carrying the input bytes")
+		}
+		if (tx.gasLimit > 0) != bytes.HasPrefix(data, []byte(GasTxPrefix)) {
+			t.Fatalf("decodeTx returned a tx with gas limit %d", tx.gasLimit)
+		}
+	case kvBankTx:
+		if len(tx.recipient) == 0 {
+			t.Fatal("decodeTx returned a bank tx with an empty recipient")
+		}
+		if !bytes.Equal(tx.bytes, data) {
+			t.Fatal("decodeTx returned a bank tx not carrying the input bytes")
+		}
+	case kvSwapTx:
+		if len(tx.keyA) == 0 || len(tx.keyB) == 0 {
+			t.Fatal("decodeTx returned a swap tx with an empty key")
+		}
+		if !bytes.Equal(tx.bytes, data) {
+			t.Fatal("decodeTx returned a swap tx not carrying the input bytes")
+		}
+	case kvReadTx:
+		for _, key := range tx.keys {
+			if len(key) == 0 {
+				t.Fatal("decodeTx returned a read tx with an empty key")
+			}
+		}
+		if len(tx.keys) == 0 || !bytes.Equal(tx.bytes, data) {
+			t.Fatal("decodeTx returned a read tx without keys or not carrying the input bytes")
+		}
+	case kvDeleteTx:
+		for _, key := range tx.keys {
+			if len(key) == 0 {
+				t.Fatal("decodeTx returned a delete tx with an empty key")
+			}
+		}
+		if len(tx.keys) == 0 || !bytes.Equal(tx.bytes, data) {
+			t.Fatal("decodeTx returned a delete tx without keys or not carrying the input bytes")
+		}
+	case feeTx:
+		if tx.payer == "" || tx.Tx == nil {
+			t.Fatal("decodeTx returned a fee tx without payer or wrapped tx")
+		}
+		switch tx.Tx.(type) {
+		case feeTx, hintedTx:
+			t.Fatal("decodeTx returned a fee tx wrapping a fee or hinted tx")
+		}
+	case hintedTx:
+		for _, key := range tx.hints {
+			if len(key) == 0 {
+				t.Fatal("decodeTx returned a hinted tx with an empty hinted key")
+			}
+		}
+		if len(tx.hints) == 0 || tx.Tx == nil {
+			t.Fatal("decodeTx returned a hinted tx without hints or wrapped tx")
+		}
+		if _, ok := tx.Tx.(hintedTx); ok {
+			t.Fatal("decodeTx returned nested hinted txs")
+		}
+	default:
+		t.Fatalf("decodeTx returned an unexpected tx type %T", tx)
+	}
+	return true
+}
+
+func TestDecodeTxEmptyKey(t *testing.T) {
+	for _, txBytes := range []string{"", "=", "=v"} {
+		_, err := decodeTx([]byte(txBytes))
+		require.Error(t, err, "tx %q", txBytes)
+	}
+	require.True(t, checkDecodeTx(t, []byte("k=")))
+}
@@ -0,0 +1,62 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// GasTxPrefix starts the bytes of a kvstore tx declaring its own gas limit
+// ahead of the tx itself, i.e. "gas:5000;key=value". The tx runs against a
+// gas meter capped at that limit, so an expensive tx runs out of gas at its
+// own limit instead of the block's.
+const GasTxPrefix = "gas:"
+
+// gasLimitSeparator ends the gas limit of a tx.
+const gasLimitSeparator = ';'
+
+// NewTxWithGasLimit returns the bytes of a tx setting key to value within
+// gasLimit.
+func NewTxWithGasLimit(key, value string, gasLimit uint64) []byte {
+	return []byte(fmt.Sprintf("%s%d%c%s=%s", GasTxPrefix, gasLimit, gasLimitSeparator, key, value))
+}
+
+// decodeGasLimitedTx decodes the bytes of a kvstoreTx declaring its gas
+// limit, GasTxPrefix included.
+func decodeGasLimitedTx(txBytes []byte) (sdk.Tx, error) {
+	rest := txBytes[len(GasTxPrefix):]
+	i := bytes.IndexByte(rest, gasLimitSeparator)
+	if i < 0 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "expected gas;tx")
+	}
+	gasLimit, err := strconv.ParseUint(string(rest[:i]), 10, 64)
+	if err != nil || gasLimit == 0 {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrTxDecode, "invalid gas limit %q", rest[:i])
+	}
+
+	inner, err := decodeTx(rest[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	tx, ok := inner.(kvstoreTx)
+	if !ok {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrTxDecode, "gas limit on a %T", inner)
+	}
+	if tx.gasLimit > 0 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "nested gas limits")
+	}
+	tx.gasLimit, tx.bytes = gasLimit, txBytes
+	return tx, nil
+}
+
+// withTxGasLimit caps the gas meter of ctx at the gas limit tx declares, if
+// any.
+func withTxGasLimit(ctx sdk.Context, tx sdk.Tx) sdk.Context {
+	if kvTx, ok := unwrapTx(tx).(kvstoreTx); ok && kvTx.gasLimit > 0 {
+		return ctx.WithGasMeter(sdk.NewGasMeterWithMultiplier(ctx, kvTx.gasLimit))
+	}
+	return ctx
+}
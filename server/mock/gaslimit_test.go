@@ -0,0 +1,46 @@
+package mock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestTxGasLimit(t *testing.T) {
+	app := setupTestApp(t, nil)
+	large := strings.Repeat("x", 1000)
+
+	resp := finalizeAndCommit(t, app, 1,
+		NewTxWithGasLimit("big", large, 500),
+		NewTxWithGasLimit("fits", large, 1000000),
+		[]byte("unlimited="+large),
+	)
+
+	// the large write runs out of the gas the tx declared
+	res := resp.TxResults[0]
+	require.Equal(t, sdkerrors.ErrOutOfGas.ABCICode(), res.Code, res.Log)
+	require.Equal(t, int64(500), res.GasWanted)
+	require.Nil(t, queryStore(t, app, "main", "big", 1).Value)
+
+	// within its limit it goes through
+	res = resp.TxResults[1]
+	require.Equal(t, uint32(0), res.Code, res.Log)
+	require.Equal(t, int64(1000000), res.GasWanted)
+	require.Less(t, res.GasUsed, res.GasWanted)
+	require.Equal(t, []byte(large), queryStore(t, app, "main", "fits", 1).Value)
+
+	// and a tx declaring no limit is not capped
+	require.Equal(t, uint32(0), resp.TxResults[2].Code, resp.TxResults[2].Log)
+
+	// hinted txs keep the limit of the tx they wrap
+	resp = finalizeAndCommit(t, app, 2, NewHintedTx(NewTxWithGasLimit("big", large, 500), "big"))
+	require.Equal(t, sdkerrors.ErrOutOfGas.ABCICode(), resp.TxResults[0].Code, resp.TxResults[0].Log)
+
+	for _, bz := range []string{"gas:5;", "gas:x;a=1", "gas:0;a=1", "gas:10;gas:10;a=1", "gas:10;read:a"} {
+		_, err := decodeTx([]byte(bz))
+		require.True(t, sdkerrors.ErrTxDecode.Is(err), bz)
+	}
+}
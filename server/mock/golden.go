@@ -0,0 +1,147 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// MarshalResponseDeterministic encodes resp as indented JSON stable enough
+// to be committed as a golden fixture: the events of the block and of every
+// tx result are sorted by type and attributes, and attributes by key and
+// value. resp itself is left untouched.
+func MarshalResponseDeterministic(resp *abci.ResponseFinalizeBlock) ([]byte, error) {
+	resp = proto.Clone(resp).(*abci.ResponseFinalizeBlock)
+
+	sortEvents(resp.Events)
+	for _, res := range resp.TxResults {
+		if res != nil {
+			sortEvents(res.Events)
+		}
+	}
+
+	bz, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(bz, '\n'), nil
+}
+
+// MarshalGenesisDeterministic encodes g as indented JSON stable enough to be
+// committed as a golden fixture: the values are sorted by key. The sort is
+// stable, so values repeating a key keep their relative order and initialize
+// the chain to the same state. Strings are escaped as encoding/json does by
+// default. g itself is left untouched.
+func MarshalGenesisDeterministic(g GenesisJSON) ([]byte, error) {
+	g.Values = append([]KV{}, g.Values...)
+	sort.SliceStable(g.Values, func(i, j int) bool { return g.Values[i].Key < g.Values[j].Key })
+
+	bz, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(bz, '\n'), nil
+}
+
+func sortEvents(events []abci.Event) {
+	for _, event := range events {
+		sort.SliceStable(event.Attributes, func(i, j int) bool {
+			return compareAttributes(event.Attributes[i], event.Attributes[j]) < 0
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Type != events[j].Type {
+			return events[i].Type < events[j].Type
+		}
+		a, b := events[i].Attributes, events[j].Attributes
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if cmp := compareAttributes(a[k], b[k]); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+func compareAttributes(a, b abci.EventAttribute) int {
+	if cmp := bytes.Compare(a.Key, b.Key); cmp != 0 {
+		return cmp
+	}
+	if cmp := bytes.Compare(a.Value, b.Value); cmp != 0 {
+		return cmp
+	}
+	switch {
+	case a.Index == b.Index:
+		return 0
+	case !a.Index:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// DiffFinalizeResults compares two FinalizeBlock responses and returns a
+// description of every difference: in the app hash and number of txs, and
+// per tx in code, data, gas and events. Events are compared regardless of
+// their order, as MarshalResponseDeterministic encodes them. An empty result
+// means both responses executed the txs the same way.
+func DiffFinalizeResults(a, b *abci.ResponseFinalizeBlock) []string {
+	a = proto.Clone(a).(*abci.ResponseFinalizeBlock)
+	b = proto.Clone(b).(*abci.ResponseFinalizeBlock)
+
+	var diffs []string
+	if !bytes.Equal(a.AppHash, b.AppHash) {
+		diffs = append(diffs, fmt.Sprintf("app hash: %X != %X", a.AppHash, b.AppHash))
+	}
+	if len(a.TxResults) != len(b.TxResults) {
+		diffs = append(diffs, fmt.Sprintf("tx count: %d != %d", len(a.TxResults), len(b.TxResults)))
+	}
+	for i := 0; i < len(a.TxResults) && i < len(b.TxResults); i++ {
+		resA, resB := a.TxResults[i], b.TxResults[i]
+		if resA == nil {
+			resA = &abci.ExecTxResult{}
+		}
+		if resB == nil {
+			resB = &abci.ExecTxResult{}
+		}
+
+		if resA.Codespace != resB.Codespace || resA.Code != resB.Code {
+			diffs = append(diffs, fmt.Sprintf("tx %d: code %s/%d != %s/%d", i, resA.Codespace, resA.Code, resB.Codespace, resB.Code))
+		}
+		if !bytes.Equal(resA.Data, resB.Data) {
+			diffs = append(diffs, fmt.Sprintf("tx %d: data %X != %X", i, resA.Data, resB.Data))
+		}
+		if resA.GasWanted != resB.GasWanted {
+			diffs = append(diffs, fmt.Sprintf("tx %d: gas wanted %d != %d", i, resA.GasWanted, resB.GasWanted))
+		}
+		if resA.GasUsed != resB.GasUsed {
+			diffs = append(diffs, fmt.Sprintf("tx %d: gas used %d != %d", i, resA.GasUsed, resB.GasUsed))
+		}
+
+		sortEvents(resA.Events)
+		sortEvents(resB.Events)
+		if eventsA, eventsB := formatEvents(resA.Events), formatEvents(resB.Events); eventsA != eventsB {
+			diffs = append(diffs, fmt.Sprintf("tx %d: events %s != %s", i, eventsA, eventsB))
+		}
+	}
+
+	return diffs
+}
+
+// formatEvents renders events compactly, e.g. "[kvstore{key=a,value=1}]".
+func formatEvents(events []abci.Event) string {
+	rendered := make([]string, len(events))
+	for i, event := range events {
+		attrs := make([]string, len(event.Attributes))
+		for j, attr := range event.Attributes {
+			attrs[j] = fmt.Sprintf("%s=%s", attr.Key, attr.Value)
+		}
+		rendered[i] = fmt.Sprintf("%s{%s}", event.Type, strings.Join(attrs, ","))
+	}
+	return "[" + strings.Join(rendered, " ") + "]"
+}
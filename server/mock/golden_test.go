@@ -0,0 +1,147 @@
+package mock
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the mock app tests")
+
+func TestFinalizeBlockGolden(t *testing.T) {
+	app := setupTestApp(t, nil)
+	resp := finalizeAndCommit(t, app, 1,
+		[]byte("b=2"),
+		NewTxWithMeta("a", "1", []byte("ref")),
+		[]byte("a=b=c"),
+		[]byte(ReservedKeyPrefix+"x=y"),
+	)
+
+	bz, err := MarshalResponseDeterministic(resp)
+	require.NoError(t, err)
+
+	golden := filepath.Join("testdata", "finalize_block.golden.json")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+		require.NoError(t, os.WriteFile(golden, bz, 0o644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(bz))
+}
+
+func TestMarshalResponseDeterministic(t *testing.T) {
+	attr := func(k, v string) abci.EventAttribute {
+		return abci.EventAttribute{Key: []byte(k), Value: []byte(v)}
+	}
+	resp := &abci.ResponseFinalizeBlock{
+		Events: []abci.Event{
+			{Type: "b", Attributes: []abci.EventAttribute{attr("y", "2"), attr("x", "1")}},
+			{Type: "a", Attributes: []abci.EventAttribute{attr("k", "2")}},
+			{Type: "a", Attributes: []abci.EventAttribute{attr("k", "1")}},
+		},
+	}
+	sorted := &abci.ResponseFinalizeBlock{
+		Events: []abci.Event{
+			{Type: "a", Attributes: []abci.EventAttribute{attr("k", "1")}},
+			{Type: "a", Attributes: []abci.EventAttribute{attr("k", "2")}},
+			{Type: "b", Attributes: []abci.EventAttribute{attr("x", "1"), attr("y", "2")}},
+		},
+	}
+
+	bz, err := MarshalResponseDeterministic(resp)
+	require.NoError(t, err)
+	expected, err := MarshalResponseDeterministic(sorted)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(bz))
+	require.Equal(t, "b", resp.Events[0].Type, "the response is not sorted in place")
+}
+
+func TestDiffFinalizeResults(t *testing.T) {
+	txs := [][]byte{[]byte("a=1"), []byte("b=2")}
+	first := finalizeAndCommit(t, setupTestApp(t, nil), 1, txs...)
+	second := finalizeAndCommit(t, setupTestApp(t, nil), 1, txs...)
+	require.Empty(t, DiffFinalizeResults(first, second))
+
+	// the same events in another order are no difference
+	reordered := proto.Clone(second).(*abci.ResponseFinalizeBlock)
+	events := reordered.TxResults[0].Events
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	require.Empty(t, DiffFinalizeResults(first, reordered))
+
+	diverged := finalizeAndCommit(t, setupTestApp(t, nil), 1, []byte("a=1"), []byte("b=22"), []byte("c=3"))
+	require.Equal(t, []string{
+		"tx count: 2 != 3",
+		fmt.Sprintf("tx 1: gas used %d != %d", first.TxResults[1].GasUsed, diverged.TxResults[1].GasUsed),
+		"tx 1: events " + formatEvents(sortedEvents(first.TxResults[1].Events)) +
+			" != " + formatEvents(sortedEvents(diverged.TxResults[1].Events)),
+	}, DiffFinalizeResults(first, diverged))
+
+	failed := proto.Clone(first).(*abci.ResponseFinalizeBlock)
+	failed.TxResults[0].Codespace, failed.TxResults[0].Code = Codespace, ErrReservedKey.ABCICode()
+	failed.TxResults[0].Data = []byte{1}
+	require.Equal(t, []string{
+		"tx 0: code /0 != mock/4",
+		fmt.Sprintf("tx 0: data %X != 01", first.TxResults[0].Data),
+	}, DiffFinalizeResults(first, failed))
+}
+
+func sortedEvents(events []abci.Event) []abci.Event {
+	events = append([]abci.Event{}, events...)
+	sortEvents(events)
+	return events
+}
+
+func TestMarshalGenesisDeterministic(t *testing.T) {
+	genesis := GenesisJSON{
+		Values:     []KV{{Key: "b", Value: "<2>"}, {Key: "a", Value: "1"}, {Key: "b", Value: "3"}},
+		AppVersion: 2,
+	}
+	reordered := GenesisJSON{
+		Values:     []KV{{Key: "a", Value: "1"}, {Key: "b", Value: "<2>"}, {Key: "b", Value: "3"}},
+		AppVersion: 2,
+	}
+
+	bz, err := MarshalGenesisDeterministic(genesis)
+	require.NoError(t, err)
+	again, err := MarshalGenesisDeterministic(genesis)
+	require.NoError(t, err)
+	require.Equal(t, bz, again)
+	other, err := MarshalGenesisDeterministic(reordered)
+	require.NoError(t, err)
+	require.Equal(t, string(bz), string(other))
+	require.Equal(t, "b", genesis.Values[0].Key, "the genesis itself is not sorted")
+
+	// HTML characters are escaped as encoding/json does by default
+	require.Equal(t, `{
+  "values": [
+    {
+      "key": "a",
+      "value": "1"
+    },
+    {
+      "key": "b",
+      "value": "\u003c2\u003e"
+    },
+    {
+      "key": "b",
+      "value": "3"
+    }
+  ],
+  "app_version": 2
+}
+`, string(bz))
+
+	// the encoding initializes the chain to the same state
+	decoded, err := decodeGenesis(bz, true)
+	require.NoError(t, err)
+	require.Equal(t, &reordered, decoded)
+}
@@ -1,12 +1,23 @@
 package mock
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
+	"time"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
 // SetupApp returns an application as well as a clean-up function
@@ -34,3 +45,367 @@ func SetupApp() (abci.Application, func(), error) {
 	app, err := NewApp(rootDir, logger)
 	return app, cleanup, err
 }
+
+// FinalizeOpt configures the request built by NewFinalizeRequest.
+type FinalizeOpt func(*abci.RequestFinalizeBlock)
+
+// WithBlockTime sets the block time of the request.
+func WithBlockTime(blockTime time.Time) FinalizeOpt {
+	return func(req *abci.RequestFinalizeBlock) { req.Time = blockTime }
+}
+
+// WithProposer sets the proposer address of the request.
+func WithProposer(proposer []byte) FinalizeOpt {
+	return func(req *abci.RequestFinalizeBlock) { req.ProposerAddress = proposer }
+}
+
+// NewFinalizeRequest builds the FinalizeBlock request executing txs at
+// height. The block hash is derived from the height and txs, so the same
+// block always gets the same hash and different blocks different ones.
+func NewFinalizeRequest(height int64, txs [][]byte, opts ...FinalizeOpt) *abci.RequestFinalizeBlock {
+	hasher := sha256.New()
+	_ = binary.Write(hasher, binary.BigEndian, height)
+	for _, tx := range txs {
+		txHash := sha256.Sum256(tx)
+		hasher.Write(txHash[:])
+	}
+
+	req := &abci.RequestFinalizeBlock{
+		Height: height,
+		Hash:   hasher.Sum(nil),
+		Txs:    txs,
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	return req
+}
+
+// RunBlocksOption configures the blocks built by RunBlocks.
+type RunBlocksOption func(*runBlocksConfig)
+
+type runBlocksConfig struct {
+	genesisTime    time.Time
+	blockTimeDelta time.Duration
+}
+
+// WithGenesisTime sets the time the block clock starts from, i.e. the time
+// of height 0.
+func WithGenesisTime(genesisTime time.Time) RunBlocksOption {
+	return func(cfg *runBlocksConfig) { cfg.genesisTime = genesisTime }
+}
+
+// WithBlockTimeDelta sets how much the block clock advances per height.
+func WithBlockTimeDelta(delta time.Duration) RunBlocksOption {
+	return func(cfg *runBlocksConfig) { cfg.blockTimeDelta = delta }
+}
+
+// RunBlocks executes and commits each block of txs in order, on top of the
+// latest committed height of the app. The block at height h is stamped with
+// genesisTime + h*blockTimeDelta, so consecutive calls keep a monotonic
+// clock. The FinalizeBlock responses are returned in execution order.
+func RunBlocks(app abci.Application, blocks [][][]byte, opts ...RunBlocksOption) ([]*abci.ResponseFinalizeBlock, error) {
+	var cfg runBlocksConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	goCtx := context.Background()
+	info, err := app.Info(goCtx, &abci.RequestInfo{})
+	if err != nil {
+		return nil, err
+	}
+
+	height := info.LastBlockHeight
+	responses := make([]*abci.ResponseFinalizeBlock, 0, len(blocks))
+	for _, txs := range blocks {
+		height++
+		blockTime := cfg.genesisTime.Add(time.Duration(height) * cfg.blockTimeDelta)
+		resp, err := app.FinalizeBlock(goCtx, NewFinalizeRequest(height, txs, WithBlockTime(blockTime)))
+		if err != nil {
+			return responses, fmt.Errorf("failed to finalize block %d: %w", height, err)
+		}
+		if _, err := app.Commit(goCtx); err != nil {
+			return responses, fmt.Errorf("failed to commit block %d: %w", height, err)
+		}
+		responses = append(responses, resp)
+	}
+
+	return responses, nil
+}
+
+// CommitBlock commits the finalized block and returns the app hash of the
+// committed height along with the retain height reported for it.
+func CommitBlock(app abci.Application) (appHash []byte, retainHeight int64, err error) {
+	goCtx := context.Background()
+	res, err := app.Commit(goCtx)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := app.Info(goCtx, &abci.RequestInfo{})
+	if err != nil {
+		return nil, 0, err
+	}
+	return info.LastBlockAppHash, res.RetainHeight, nil
+}
+
+// AssertRepeatGas executes tx in two consecutive blocks committed on top of
+// the latest height of the app, and returns an error unless both executions
+// succeed and the second one uses delta more gas than the first. A zero
+// delta asserts that repeating the tx costs the same, e.g. when it
+// overwrites a value with one of the same size; a non zero one pins how the
+// state left by the first execution changes the cost of the second.
+func AssertRepeatGas(app abci.Application, tx []byte, delta int64) error {
+	responses, err := RunBlocks(app, [][][]byte{{tx}, {tx}})
+	if err != nil {
+		return err
+	}
+	var gasUsed [2]int64
+	for i, resp := range responses {
+		res := resp.TxResults[0]
+		if res.Code != 0 {
+			return fmt.Errorf("execution %d of tx failed with code %d: %s", i+1, res.Code, res.Log)
+		}
+		gasUsed[i] = res.GasUsed
+	}
+	if got := gasUsed[1] - gasUsed[0]; got != delta {
+		return fmt.Errorf("repeated tx used %d gas then %d, a delta of %d instead of %d", gasUsed[0], gasUsed[1], got, delta)
+	}
+	return nil
+}
+
+// RestartAndVerify runs txBlocks on the app stored in dir, initializing the
+// chain with an empty genesis if it has no committed height yet, then closes
+// and reopens it. It returns an error unless the reopened app loads the same
+// height, app hash and state as the app had before closing.
+func RestartAndVerify(dir string, txBlocks [][][]byte) error {
+	goCtx := context.Background()
+	app, err := NewApp(dir, log.NewNopLogger())
+	if err != nil {
+		return err
+	}
+	mockApp := app.(*App)
+
+	if mockApp.LastBlockHeight() == 0 {
+		appState, err := json.Marshal(GenesisJSON{})
+		if err != nil {
+			return err
+		}
+		if _, err := app.InitChain(goCtx, &abci.RequestInitChain{AppStateBytes: appState}); err != nil {
+			return err
+		}
+	}
+	if _, err := RunBlocks(app, txBlocks); err != nil {
+		return err
+	}
+
+	before, err := app.Info(goCtx, &abci.RequestInfo{})
+	if err != nil {
+		return err
+	}
+	// copy the committed state aside, the db can't be opened twice
+	stores := make(map[string]sdk.KVStore)
+	for _, key := range mockApp.storeKeys() {
+		stores[key.Name()] = copyKVStore(mockApp.CommitMultiStore().GetKVStore(key))
+	}
+	if err := mockApp.Close(); err != nil {
+		return err
+	}
+
+	app, err = NewApp(dir, log.NewNopLogger())
+	if err != nil {
+		return fmt.Errorf("failed to reopen app: %w", err)
+	}
+	mockApp = app.(*App)
+	defer mockApp.Close()
+
+	after, err := app.Info(goCtx, &abci.RequestInfo{})
+	if err != nil {
+		return err
+	}
+	if after.LastBlockHeight != before.LastBlockHeight {
+		return fmt.Errorf("height %d after restart != %d", after.LastBlockHeight, before.LastBlockHeight)
+	}
+	if !bytes.Equal(after.LastBlockAppHash, before.LastBlockAppHash) {
+		return fmt.Errorf("app hash %X after restart != %X", after.LastBlockAppHash, before.LastBlockAppHash)
+	}
+	for _, key := range mockApp.storeKeys() {
+		if err := assertKVStoreEqual(stores[key.Name()], mockApp.CommitMultiStore().GetKVStore(key)); err != nil {
+			return fmt.Errorf("store %s after restart: %w", key.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// RunParallelApps runs workload on n fresh apps at the same time, each backed
+// by its own in-memory db, and returns the app hash each of them ends up
+// with. It returns an error unless every app ran the
+// workload and they all agree on the app hash, so a state shared between
+// app instances shows up as a divergence or a data race.
+func RunParallelApps(n int, workload [][][]byte) ([][]byte, error) {
+	appState, err := json.Marshal(GenesisJSON{})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			app, err := NewApp("", log.NewNopLogger(), SetInMemory(true))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			mockApp := app.(*App)
+			defer mockApp.Close()
+			if _, err := app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: appState}); err != nil {
+				errs[i] = fmt.Errorf("failed to init chain: %w", err)
+				return
+			}
+			if _, err := RunBlocks(app, workload); err != nil {
+				errs[i] = err
+				return
+			}
+			hashes[i] = mockApp.LastCommitID().Hash
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("app %d: %w", i, err)
+		}
+	}
+	for i := 1; i < n; i++ {
+		if !bytes.Equal(hashes[i], hashes[0]) {
+			return hashes, fmt.Errorf("app %d: app hash %X != %X of app 0", i, hashes[i], hashes[0])
+		}
+	}
+	return hashes, nil
+}
+
+// ReconcileApps brings two apps partitioned from each other back in sync.
+// blocks is the chain both apps follow from height 1, of which each has
+// committed a prefix, e.g. with RunBlocks. The blocks the lagging app misses
+// are replayed on it, with the opts the leading app ran them with, up to the
+// height of the leading app. It returns an error unless both apps then agree
+// on the app hash and on the state of every store.
+func ReconcileApps(a, b abci.Application, blocks [][][]byte, opts ...RunBlocksOption) error {
+	goCtx := context.Background()
+	infoA, err := a.Info(goCtx, &abci.RequestInfo{})
+	if err != nil {
+		return err
+	}
+	infoB, err := b.Info(goCtx, &abci.RequestInfo{})
+	if err != nil {
+		return err
+	}
+
+	lagging, from, to := b, infoB.LastBlockHeight, infoA.LastBlockHeight
+	if from > to {
+		lagging, from, to = a, to, from
+	}
+	if to > int64(len(blocks)) {
+		return fmt.Errorf("app at height %d is past the %d blocks to reconcile", to, len(blocks))
+	}
+	if _, err := RunBlocks(lagging, blocks[from:to], opts...); err != nil {
+		return fmt.Errorf("failed to replay blocks %d to %d: %w", from+1, to, err)
+	}
+
+	if infoA, err = a.Info(goCtx, &abci.RequestInfo{}); err != nil {
+		return err
+	}
+	if infoB, err = b.Info(goCtx, &abci.RequestInfo{}); err != nil {
+		return err
+	}
+	if !bytes.Equal(infoA.LastBlockAppHash, infoB.LastBlockAppHash) {
+		return fmt.Errorf("app hash %X != %X at height %d", infoA.LastBlockAppHash, infoB.LastBlockAppHash, to)
+	}
+	return AssertStateEqual(a, b)
+}
+
+// copyKVStore returns an in-memory copy of store.
+func copyKVStore(store sdk.KVStore) sdk.KVStore {
+	cp := dbadapter.Store{DB: dbm.NewMemDB()}
+	iter := store.Iterator(nil, nil)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		cp.Set(iter.Key(), iter.Value())
+	}
+	return cp
+}
+
+// AssertStateEqual compares the committed state of every store mounted on
+// two mock apps and returns an error describing the first key whose value
+// differs, or that is only present in one of them. Stores are compared by
+// name, in the order they are mounted on a.
+func AssertStateEqual(a, b abci.Application) error {
+	appA, ok := a.(*App)
+	if !ok {
+		return fmt.Errorf("unexpected app type %T", a)
+	}
+	appB, ok := b.(*App)
+	if !ok {
+		return fmt.Errorf("unexpected app type %T", b)
+	}
+
+	keysA, keysB := appA.storeKeys(), appB.storeKeys()
+	if len(keysA) != len(keysB) {
+		return fmt.Errorf("mounted %d stores != %d stores", len(keysA), len(keysB))
+	}
+	keysByName := make(map[string]sdk.StoreKey, len(keysB))
+	for _, key := range keysB {
+		keysByName[key.Name()] = key
+	}
+
+	for _, keyA := range keysA {
+		keyB, ok := keysByName[keyA.Name()]
+		if !ok {
+			return fmt.Errorf("store %s is only mounted on the first app", keyA.Name())
+		}
+		storeA := appA.CommitMultiStore().GetKVStore(keyA)
+		storeB := appB.CommitMultiStore().GetKVStore(keyB)
+		if err := assertKVStoreEqual(storeA, storeB); err != nil {
+			return fmt.Errorf("store %s: %w", keyA.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// assertKVStoreEqual walks both stores in key order and reports the first
+// difference.
+func assertKVStoreEqual(a, b sdk.KVStore) error {
+	iterA, iterB := a.Iterator(nil, nil), b.Iterator(nil, nil)
+	defer iterA.Close()
+	defer iterB.Close()
+
+	for iterA.Valid() || iterB.Valid() {
+		switch {
+		case !iterB.Valid():
+			return fmt.Errorf("key %q is only present in the first app", iterA.Key())
+		case !iterA.Valid():
+			return fmt.Errorf("key %q is only present in the second app", iterB.Key())
+		}
+
+		switch cmp := bytes.Compare(iterA.Key(), iterB.Key()); {
+		case cmp < 0:
+			return fmt.Errorf("key %q is only present in the first app", iterA.Key())
+		case cmp > 0:
+			return fmt.Errorf("key %q is only present in the second app", iterB.Key())
+		}
+		if !bytes.Equal(iterA.Value(), iterB.Value()) {
+			return fmt.Errorf("key %q: value %q != %q", iterA.Key(), iterA.Value(), iterB.Value())
+		}
+
+		iterA.Next()
+		iterB.Next()
+	}
+
+	return nil
+}
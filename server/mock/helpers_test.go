@@ -0,0 +1,200 @@
+package mock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestRunBlocksTime(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	genesisTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	delta := 5 * time.Second
+	blocks := [][][]byte{{[]byte(TimeKey)}, {[]byte(TimeKey)}, {[]byte(TimeKey)}}
+
+	responses, err := RunBlocks(app, blocks, WithGenesisTime(genesisTime), WithBlockTimeDelta(delta))
+	require.NoError(t, err)
+	require.Len(t, responses, len(blocks))
+
+	var last time.Time
+	for height := int64(1); height <= int64(len(blocks)); height++ {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{
+			Path:   "/store/main/key",
+			Data:   []byte(TimeKey),
+			Height: height,
+		})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+
+		blockTime, err := time.Parse(time.RFC3339Nano, string(qres.Value))
+		require.NoError(t, err)
+		require.Equal(t, genesisTime.Add(time.Duration(height)*delta), blockTime)
+		require.True(t, blockTime.After(last))
+		last = blockTime
+	}
+
+	// a second call continues from the latest committed height
+	_, err = RunBlocks(app, blocks[:1], WithGenesisTime(genesisTime), WithBlockTimeDelta(delta))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(blocks)+1), app.LastBlockHeight())
+}
+
+func TestAssertStateEqual(t *testing.T) {
+	blocks := [][][]byte{{[]byte("a=1"), []byte("b=2")}, {[]byte("c=3")}}
+
+	a := setupTestApp(t, nil)
+	b := setupTestApp(t, nil)
+	_, err := RunBlocks(a, blocks)
+	require.NoError(t, err)
+	_, err = RunBlocks(b, blocks)
+	require.NoError(t, err)
+	require.NoError(t, AssertStateEqual(a, b))
+
+	// a differing value is reported with its key
+	_, err = RunBlocks(a, [][][]byte{{[]byte("b=x")}})
+	require.NoError(t, err)
+	_, err = RunBlocks(b, [][][]byte{{[]byte("b=y")}})
+	require.NoError(t, err)
+	require.EqualError(t, AssertStateEqual(a, b), `store main: key "b": value "x" != "y"`)
+
+	// so is a key missing from one side
+	_, err = RunBlocks(b, [][][]byte{{[]byte("b=x"), []byte("d=4")}})
+	require.NoError(t, err)
+	require.EqualError(t, AssertStateEqual(a, b), `store main: key "d" is only present in the second app`)
+
+	c := setupTestApp(t, nil, SetExtraStores("other"))
+	require.Error(t, AssertStateEqual(a, c))
+}
+
+func TestCommitBlock(t *testing.T) {
+	goCtx := context.Background()
+	app := setupTestApp(t, nil, SetRetainWindow(1), SetTwoPhaseCommit(true))
+
+	for height := int64(1); height <= 2; height++ {
+		resp, err := app.FinalizeBlock(goCtx, &abci.RequestFinalizeBlock{Height: height, Txs: [][]byte{[]byte("a=1")}})
+		require.NoError(t, err)
+
+		appHash, retainHeight, err := CommitBlock(app)
+		require.NoError(t, err)
+		require.Equal(t, resp.AppHash, appHash)
+		require.Equal(t, app.LastCommitID().Hash, appHash)
+		require.Equal(t, height-1, retainHeight)
+	}
+}
+
+func TestNewFinalizeRequest(t *testing.T) {
+	blockTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	txs := [][]byte{[]byte("a=1"), []byte("b=2")}
+
+	req := NewFinalizeRequest(3, txs, WithBlockTime(blockTime), WithProposer([]byte("proposer")))
+	require.Equal(t, int64(3), req.Height)
+	require.Equal(t, txs, req.Txs)
+	require.Equal(t, blockTime, req.Time)
+	require.Equal(t, []byte("proposer"), req.ProposerAddress)
+	require.Len(t, req.Hash, 32)
+
+	// the hash identifies the block
+	require.Equal(t, req.Hash, NewFinalizeRequest(3, txs).Hash)
+	require.NotEqual(t, req.Hash, NewFinalizeRequest(4, txs).Hash)
+	require.NotEqual(t, req.Hash, NewFinalizeRequest(3, txs[:1]).Hash)
+
+	app := setupTestApp(t, nil)
+	resp, err := app.FinalizeBlock(context.Background(), NewFinalizeRequest(1, [][]byte{[]byte(TimeKey)}, WithBlockTime(blockTime)))
+	require.NoError(t, err)
+	require.Equal(t, blockTime.Format(time.RFC3339Nano), eventValue(t, resp.TxResults[0].Events))
+}
+
+func TestRestartAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, RestartAndVerify(dir, [][][]byte{
+		{[]byte("a=1"), []byte("b=2")},
+		{[]byte("a=3"), NewSwapTx("a", "b")},
+	}))
+
+	// picks up from the height committed by the previous run
+	require.NoError(t, RestartAndVerify(dir, [][][]byte{{[]byte("c=4")}}))
+
+	app, err := NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	defer app.(*App).Close()
+	require.Equal(t, int64(3), app.(*App).LastBlockHeight())
+	require.Equal(t, []byte("3"), queryStore(t, app, "main", "b", 3).Value)
+}
+
+func TestAssertRepeatGas(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	// an overwrite with a value of the same size costs the same
+	require.NoError(t, AssertRepeatGas(app, []byte("a=1"), 0))
+
+	// the second credit reads the balance left by the first, and rewrites it
+	// with a value of the same size
+	balance := sdk.NewCoins(sdk.NewInt64Coin("stake", 1))
+	readCost := int64(storetypes.KVGasConfig().ReadCostPerByte) * int64(len(balance.String()))
+	require.NoError(t, AssertRepeatGas(app, NewBankTx("x", balance), readCost))
+
+	require.Error(t, AssertRepeatGas(app, []byte("b=1"), 1))
+	require.Error(t, AssertRepeatGas(app, []byte(PanicKey+"=boom"), 0))
+}
+
+func TestRunParallelApps(t *testing.T) {
+	workload := [][][]byte{
+		GenWorkload(WorkloadSpec{Txs: 50, Keys: 10, SetWeight: 2, DeleteWeight: 1, IncrementWeight: 1, ZipfS: 1.5, Seed: 1}),
+		{NewSwapTx("key0", "key1"), []byte(TxIndexKey)},
+	}
+
+	hashes, err := RunParallelApps(4, workload)
+	require.NoError(t, err)
+	require.Len(t, hashes, 4)
+	require.NotEmpty(t, hashes[0])
+
+	app := setupTestApp(t, nil)
+	_, err = RunBlocks(app, workload)
+	require.NoError(t, err)
+	require.Equal(t, app.LastCommitID().Hash, hashes[0])
+}
+
+func TestReconcileApps(t *testing.T) {
+	blocks := [][][]byte{
+		{[]byte("a=1"), []byte("b=2")},
+		{NewSwapTx("a", "b"), []byte(TimeKey)},
+		{[]byte("c=3")},
+		{NewDeleteTx("a"), []byte("d=4")},
+	}
+	opts := []RunBlocksOption{WithBlockTimeDelta(time.Second)}
+
+	// the partition leaves the apps with different prefixes of the chain
+	a := setupTestApp(t, nil)
+	b := setupTestApp(t, nil)
+	_, err := RunBlocks(a, blocks[:1], opts...)
+	require.NoError(t, err)
+	_, err = RunBlocks(b, blocks[:3], opts...)
+	require.NoError(t, err)
+	require.NotEqual(t, a.LastCommitID().Hash, b.LastCommitID().Hash)
+
+	require.NoError(t, ReconcileApps(a, b, blocks, opts...))
+	require.Equal(t, int64(3), a.LastBlockHeight())
+	require.Equal(t, b.LastCommitID(), a.LastCommitID())
+
+	// apps already in sync are left as they are
+	require.NoError(t, ReconcileApps(a, b, blocks, opts...))
+	require.Equal(t, int64(3), b.LastBlockHeight())
+
+	// a block the other app never saw cannot be reconciled
+	_, err = RunBlocks(a, [][][]byte{{[]byte("e=5")}}, opts...)
+	require.NoError(t, err)
+	_, err = RunBlocks(b, [][][]byte{{[]byte("e=6")}}, opts...)
+	require.NoError(t, err)
+	require.Error(t, ReconcileApps(a, b, blocks, opts...))
+
+	// neither can an app past the blocks given
+	require.Error(t, ReconcileApps(a, b, blocks[:3], opts...))
+}
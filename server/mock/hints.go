@@ -0,0 +1,119 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// HintsTxPrefix starts the bytes of a tx declaring the keys it depends on
+// ahead of the tx itself, i.e. "hints:a,b;swap:a,b". Hints model access
+// lists: with SetSchedulerHints, the concurrent scheduler holds a hinted tx
+// back until the txs before it in the block writing one of its hinted keys
+// have executed, instead of letting it read a value about to be overwritten
+// and executing it again.
+const HintsTxPrefix = "hints:"
+
+// hintsSeparator ends the hinted keys of a tx.
+const hintsSeparator = ';'
+
+// hintedTx is a tx along with the keys it declared depending on. Its msgs
+// are the ones of the wrapped tx.
+type hintedTx struct {
+	sdk.Tx
+	hints [][]byte
+}
+
+// NewHintedTx returns the bytes of tx declaring a dependency on keys.
+func NewHintedTx(tx []byte, keys ...string) []byte {
+	return []byte(fmt.Sprintf("%s%s%c%s", HintsTxPrefix, strings.Join(keys, ","), hintsSeparator, tx))
+}
+
+// decodeHintedTx decodes the bytes of a hintedTx, HintsTxPrefix included.
+func decodeHintedTx(txBytes []byte) (sdk.Tx, error) {
+	rest := txBytes[len(HintsTxPrefix):]
+	i := bytes.IndexByte(rest, hintsSeparator)
+	if i < 0 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "expected hints;tx")
+	}
+	inner := rest[i+1:]
+	if bytes.HasPrefix(inner, []byte(HintsTxPrefix)) {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "nested hints")
+	}
+
+	tx := hintedTx{}
+	for _, key := range bytes.Split(rest[:i], []byte(",")) {
+		if len(key) == 0 {
+			return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "empty hinted key")
+		}
+		tx.hints = append(tx.hints, key)
+	}
+	var err error
+	if tx.Tx, err = decodeTx(inner); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// unwrapTx returns the tx wrapped by a hintedTx and a feeTx, or tx itself.
+func unwrapTx(tx sdk.Tx) sdk.Tx {
+	if hTx, ok := tx.(hintedTx); ok {
+		tx = hTx.Tx
+	}
+	if fTx, ok := tx.(feeTx); ok {
+		tx = fTx.Tx
+	}
+	return tx
+}
+
+// writtenKeys returns the main store keys the msgs of tx write, as far as
+// they are known before it executes.
+func writtenKeys(tx sdk.Tx) [][]byte {
+	var keys [][]byte
+	for _, msg := range tx.GetMsgs() {
+		switch msg := msg.(type) {
+		case kvstoreTx:
+			keys = append(keys, msg.key)
+			for _, pair := range msg.msgs {
+				keys = append(keys, pair.key)
+			}
+		case kvBankTx:
+			keys = append(keys, []byte(BalanceKeyPrefix+msg.recipient))
+		case kvSwapTx:
+			keys = append(keys, msg.keyA, msg.keyB)
+		case kvDeleteTx:
+			keys = append(keys, msg.keys...)
+		}
+	}
+	return keys
+}
+
+// hintDependencies returns, for the absolute index of every hinted entry,
+// the absolute indexes of the entries before it writing one of its hints.
+func hintDependencies(entries []*sdk.DeliverTxEntry) map[int][]int {
+	deps := make(map[int][]int)
+	written := make([]map[string]struct{}, len(entries))
+	for i, entry := range entries {
+		written[i] = make(map[string]struct{})
+		for _, key := range writtenKeys(entry.SdkTx) {
+			written[i][string(key)] = struct{}{}
+		}
+
+		hTx, ok := entry.SdkTx.(hintedTx)
+		if !ok {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			for _, key := range hTx.hints {
+				if _, ok := written[j][string(key)]; ok {
+					deps[entry.AbsoluteIndex] = append(deps[entry.AbsoluteIndex], entries[j].AbsoluteIndex)
+					break
+				}
+			}
+		}
+	}
+	return deps
+}
@@ -0,0 +1,121 @@
+package mock
+
+import (
+	"bytes"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// inFlightKeys tracks the keys targeted by txs accepted into the mempool but
+// not committed yet, which models a minimal nonce/anti-replay scheme.
+type inFlightKeys struct {
+	mtx  sync.Mutex
+	keys map[string]struct{}
+}
+
+func newInFlightKeys() *inFlightKeys {
+	return &inFlightKeys{keys: make(map[string]struct{})}
+}
+
+// reserve marks key as in flight, failing if it already is.
+func (k *inFlightKeys) reserve(key []byte) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if _, ok := k.keys[string(key)]; ok {
+		return sdkerrors.Wrapf(ErrKeyInFlight, "key %s", key)
+	}
+	k.keys[string(key)] = struct{}{}
+	return nil
+}
+
+func (k *inFlightKeys) release(keys ...[]byte) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	for _, key := range keys {
+		delete(k.keys, string(key))
+	}
+}
+
+// anteHandler runs ahead of the messages of every tx. It caps the gas meter
+// of a tx declaring a gas limit at that limit. When a flat fee is set
+// it deducts it from the fee payer of every tx. When no-op rejection is enabled it
+// rejects CheckTx txs that would not change the committed state. When
+// in-flight key tracking is enabled it rejects new CheckTx txs targeting a
+// key already targeted by a mempool tx. The key is released once a block
+// including it is committed, or when the mempool evicts the tx.
+func (app *App) anteHandler(ctx sdk.Context, tx sdk.Tx, _ bool) (sdk.Context, error) {
+	ctx = withTxGasLimit(ctx, tx)
+	if !app.flatFee.IsZero() {
+		if err := app.deductFee(ctx, tx); err != nil {
+			return ctx, err
+		}
+	}
+	if !ctx.IsCheckTx() {
+		return ctx, nil
+	}
+	if app.rejectNoopTxs {
+		if err := app.rejectNoop(ctx, tx); err != nil {
+			return ctx, err
+		}
+	}
+	if app.inFlight == nil || ctx.IsReCheckTx() {
+		return ctx, nil
+	}
+
+	// a tx writing a key several times reserves it once
+	var keys [][]byte
+	seen := make(map[string]struct{})
+	for _, msg := range tx.GetMsgs() {
+		if dTx, ok := msg.(kvstoreTx); ok {
+			if _, ok := seen[string(dTx.key)]; ok {
+				continue
+			}
+			seen[string(dTx.key)] = struct{}{}
+			keys = append(keys, dTx.key)
+		}
+	}
+
+	for i, key := range keys {
+		if err := app.inFlight.reserve(key); err != nil {
+			app.inFlight.release(keys[:i]...)
+			return ctx, err
+		}
+	}
+
+	return ctx.WithExpireTxHandler(func() { app.inFlight.release(keys...) }), nil
+}
+
+// rejectNoop fails if every msg of tx sets a key to the value it already
+// holds. The check state CheckTx runs against is reset to the committed state
+// on every Commit, so this compares against the last committed values. Keys
+// whose stored value the handler computes are never no-ops.
+func (app *App) rejectNoop(ctx sdk.Context, tx sdk.Tx) error {
+	store := ctx.KVStore(app.capKeyMainStore)
+	// the last write of a key written several times is the one that sticks
+	var keys []string
+	last := make(map[string][]byte)
+	for _, msg := range tx.GetMsgs() {
+		dTx, ok := msg.(kvstoreTx)
+		if !ok {
+			return nil
+		}
+		switch string(dTx.key) {
+		case TimeKey, TxIndexKey, BlockGasKey:
+			return nil
+		}
+		if _, ok := last[string(dTx.key)]; !ok {
+			keys = append(keys, string(dTx.key))
+		}
+		last[string(dTx.key)] = dTx.value
+	}
+	for _, key := range keys {
+		if value := store.Get([]byte(key)); value == nil || !bytes.Equal(value, last[key]) {
+			return nil
+		}
+	}
+	return sdkerrors.Wrap(ErrNoopTx, "every key already holds the value set")
+}
@@ -0,0 +1,86 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func checkTx(t *testing.T, app abci.Application, tx []byte) *abci.ResponseCheckTxV2 {
+	t.Helper()
+
+	res, _ := app.CheckTx(context.Background(), &abci.RequestCheckTx{Tx: tx, Type: abci.CheckTxType_New})
+	require.NotNil(t, res)
+	return res
+}
+
+func TestInFlightKeyTracking(t *testing.T) {
+	app := setupTestApp(t, nil, SetInFlightKeyTracking(true))
+	finalizeAndCommit(t, app, 1)
+
+	first := checkTx(t, app, []byte("nonce=1"))
+	require.Equal(t, uint32(0), first.Code, first.Log)
+
+	second := checkTx(t, app, []byte("nonce=2"))
+	require.Equal(t, ErrKeyInFlight.ABCICode(), second.Code)
+	require.Equal(t, Codespace, second.Codespace)
+
+	// other keys are not affected
+	other := checkTx(t, app, []byte("other=1"))
+	require.Equal(t, uint32(0), other.Code, other.Log)
+
+	// a tx writing a key twice only reserves it once
+	twice := checkTx(t, app, []byte("twice=1&twice=2"))
+	require.Equal(t, uint32(0), twice.Code, twice.Log)
+
+	// committing the first tx releases its key
+	finalizeAndCommit(t, app, 2, []byte("nonce=1"))
+	second = checkTx(t, app, []byte("nonce=2"))
+	require.Equal(t, uint32(0), second.Code, second.Log)
+
+	// so does evicting it from the mempool
+	require.NotNil(t, second.ExpireTxHandler)
+	second.ExpireTxHandler()
+	third := checkTx(t, app, []byte("nonce=3"))
+	require.Equal(t, uint32(0), third.Code, third.Log)
+}
+
+func TestInFlightKeyTrackingDisabled(t *testing.T) {
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1)
+
+	for _, tx := range []string{"nonce=1", "nonce=2"} {
+		res := checkTx(t, app, []byte(tx))
+		require.Equal(t, uint32(0), res.Code, res.Log)
+	}
+}
+
+func TestRejectNoopTxs(t *testing.T) {
+	app := setupTestApp(t, nil, SetRejectNoopTxs(true))
+	finalizeAndCommit(t, app, 1, []byte("key=value"))
+
+	res := checkTx(t, app, []byte("key=value"))
+	require.Equal(t, ErrNoopTx.ABCICode(), res.Code)
+	require.Equal(t, Codespace, res.Codespace)
+
+	// a tx changing at least one key goes through
+	for _, tx := range []string{"key=other", "missing=value", "key=value&other=1"} {
+		res = checkTx(t, app, []byte(tx))
+		require.Equal(t, uint32(0), res.Code, "%s: %s", tx, res.Log)
+	}
+
+	// only the last write of a key written twice counts
+	res = checkTx(t, app, []byte("key=other&key=value"))
+	require.Equal(t, ErrNoopTx.ABCICode(), res.Code)
+	res = checkTx(t, app, []byte("key=value&key=other"))
+	require.Equal(t, uint32(0), res.Code, res.Log)
+
+	// CheckTx compares against the committed state only
+	finalizeAndCommit(t, app, 2, []byte("key=other"))
+	res = checkTx(t, app, []byte("key=value"))
+	require.Equal(t, uint32(0), res.Code, res.Log)
+	res = checkTx(t, app, []byte("key=other"))
+	require.Equal(t, ErrNoopTx.ABCICode(), res.Code)
+}
@@ -0,0 +1,66 @@
+package mock
+
+import (
+	"encoding/binary"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// MergeKeyPrefix prefixes the reserved keys the writes of a block are staged
+// under when a merge function is set, followed by the big endian index of
+// the writing tx and the written key.
+const MergeKeyPrefix = ReservedKeyPrefix + "merge/"
+
+// MergeFunc merges update, a value written to key by one of the txs of a
+// block writing it, into the current value of key, nil if it is absent, and
+// returns the merged value. It must be deterministic.
+type MergeFunc func(key, current, update []byte) []byte
+
+// mergeKey returns the reserved key staging a write of key by the tx at
+// txIndex. Staged writes iterate in block order.
+func mergeKey(txIndex int, key []byte) []byte {
+	bz := make([]byte, len(MergeKeyPrefix)+8+len(key))
+	copy(bz, MergeKeyPrefix)
+	binary.BigEndian.PutUint64(bz[len(MergeKeyPrefix):], uint64(txIndex))
+	copy(bz[len(MergeKeyPrefix)+8:], key)
+	return bz
+}
+
+// mergeWrites folds the writes staged by the txs of the block into the keys
+// they target, in block order, and removes them. A key written by a single
+// tx is set to its value as usual, merge only resolves the keys written by
+// several txs. As every tx stages its writes under keys of its own, txs
+// writing the same key never conflict. A
+// merged value longer than the max value size fails the block with
+// ErrValueTooLarge, there being no tx left to fail.
+func (app *App) mergeWrites(ctx sdk.Context) error {
+	if app.mergeFunc == nil {
+		return nil
+	}
+
+	store := app.withShadow(ctx, ctx.MultiStore().GetKVStore(app.capKeyMainStore))
+	var staged [][2][]byte
+	it := sdk.KVStorePrefixIterator(store, []byte(MergeKeyPrefix))
+	for ; it.Valid(); it.Next() {
+		staged = append(staged, [2][]byte{copyBytes(it.Key()), copyBytes(it.Value())})
+	}
+	it.Close()
+
+	writers := make(map[string]int, len(staged))
+	for _, kv := range staged {
+		writers[string(kv[0][len(MergeKeyPrefix)+8:])]++
+	}
+	for _, kv := range staged {
+		key := kv[0][len(MergeKeyPrefix)+8:]
+		merged := kv[1]
+		if writers[string(key)] > 1 {
+			merged = app.mergeFunc(key, store.Get(key), kv[1])
+		}
+		if err := checkValueSize(app.maxValueSize, key, merged); err != nil {
+			return err
+		}
+		store.Set(key, merged)
+		store.Delete(kv[0])
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package mock
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sumMerge(_, current, update []byte) []byte {
+	a, _ := strconv.Atoi(string(current))
+	b, _ := strconv.Atoi(string(update))
+	return []byte(strconv.Itoa(a + b))
+}
+
+func concatMerge(_, current, update []byte) []byte {
+	return append(append([]byte{}, current...), update...)
+}
+
+func TestConflictMerge(t *testing.T) {
+	genesis := []KV{{Key: "counter", Value: "10"}}
+	txs := [][]byte{[]byte("counter=1"), []byte("counter=2"), []byte("other=5"), []byte("counter=3"), []byte(TxIndexKey)}
+
+	// without a merge function the last write wins
+	app := setupTestApp(t, genesis)
+	finalizeAndCommit(t, app, 1, txs...)
+	require.Equal(t, []byte("3"), queryStore(t, app, "main", "counter", 1).Value)
+
+	for name, opts := range map[string][]Option{
+		"sequential": {SetConflictMerge(sumMerge)},
+		"concurrent": {SetConflictMerge(sumMerge), SetConcurrentExecution(len(txs)), SetDeterminismCheck(true)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, genesis, opts...)
+			if name == "concurrent" {
+				app.scheduleHook = orderedSchedule(4, 3, 2, 1, 0)
+			}
+
+			resp := finalizeAndCommit(t, app, 1, txs...)
+			for i, res := range resp.TxResults {
+				require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+			}
+			// the writes are merged in block order, none of the txs writing
+			// the counter had to be executed again
+			require.Equal(t, []byte("16"), queryStore(t, app, "main", "counter", 1).Value)
+			require.Zero(t, app.LastReexecutions().Total)
+			require.Equal(t, []byte("5"), queryStore(t, app, "main", "other", 1).Value)
+			// special keys are written as is
+			require.Equal(t, []byte("4"), queryStore(t, app, "main", TxIndexKey, 1).Value)
+
+			// a key written by a single tx keeps last-write semantics
+			finalizeAndCommit(t, app, 2, []byte("counter=4"), []byte("other=6"), []byte("other=1"))
+			require.Equal(t, []byte("4"), queryStore(t, app, "main", "counter", 2).Value)
+			require.Equal(t, []byte("12"), queryStore(t, app, "main", "other", 2).Value)
+			require.Empty(t, app.PendingWrites())
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package mock
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// HandlerMiddleware wraps the handler of the kvstore msgs, e.g. to log,
+// meter or shape the gas of their execution. It returns the handler the
+// router runs in place of next, which does the actual write.
+type HandlerMiddleware func(next sdk.Handler) sdk.Handler
+
+// chainMiddlewares wraps handler with middlewares, the first of which is the
+// outermost: it runs first and calls the second one, down to handler.
+func chainMiddlewares(handler sdk.Handler, middlewares []HandlerMiddleware) sdk.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,45 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestHandlerMiddlewares(t *testing.T) {
+	var calls []string
+	record := func(name string) HandlerMiddleware {
+		return func(next sdk.Handler) sdk.Handler {
+			return func(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+				calls = append(calls, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+	count := 0
+	counting := func(next sdk.Handler) sdk.Handler {
+		return func(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+			count++
+			return next(ctx, msg)
+		}
+	}
+
+	app := setupTestApp(t, nil, SetHandlerMiddlewares(counting, record("outer"), record("inner")))
+	responses, err := RunBlocks(app, [][][]byte{
+		{[]byte("a=1"), []byte("b=2&c=3")},
+		// msgs of other types are not wrapped
+		{NewSwapTx("a", "b")},
+	})
+	require.NoError(t, err)
+	for _, resp := range responses {
+		for _, res := range resp.TxResults {
+			require.Equal(t, uint32(0), res.Code, res.Log)
+		}
+	}
+
+	require.Equal(t, 3, count)
+	require.Equal(t, []string{"outer", "inner", "outer", "inner", "outer", "inner"}, calls)
+	require.Equal(t, []byte("3"), queryStore(t, app, "main", "c", 1).Value)
+}
@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// setKVIsolated applies a msg on a branch of the store, metered against the
+// msg gas limit if one is set. The branch is only written back if the msg
+// succeeds; a failing msg fails the tx unless partial msg application is
+// enabled, in which case it is skipped.
+func (app *App) setKVIsolated(ctx sdk.Context, msg kvstoreTx) (*sdk.Result, error) {
+	msgCtx, write := ctx.CacheContext()
+	if app.msgGasLimit > 0 {
+		msgCtx = msgCtx.WithGasMeter(sdk.NewGasMeter(app.msgGasLimit, 1, 1))
+	}
+
+	res, err := app.setKVMetered(msgCtx, msg)
+	if app.msgGasLimit > 0 {
+		// charge the tx for what the msg consumed, up to the msg limit
+		ctx.GasMeter().ConsumeGas(msgCtx.GasMeter().GasConsumedToLimit(), "kvstore msg")
+	}
+	if err != nil {
+		if !app.partialMsgs {
+			return nil, err
+		}
+		return &sdk.Result{Log: fmt.Sprintf("skipped %s: %s", msg.key, err)}, nil
+	}
+
+	write()
+	return res, nil
+}
+
+// setKVMetered turns running out of the msg gas limit into ErrMsgOutOfGas.
+// Running out of the tx gas is left to the BaseApp recovery.
+func (app *App) setKVMetered(ctx sdk.Context, msg kvstoreTx) (res *sdk.Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			oog, ok := r.(sdk.ErrorOutOfGas)
+			if !ok || app.msgGasLimit == 0 {
+				panic(r)
+			}
+			err = sdkerrors.Wrapf(ErrMsgOutOfGas, "out of gas in location: %v; msg gas limit: %d", oog.Descriptor, app.msgGasLimit)
+		}
+	}()
+
+	return app.setKV(ctx, msg)
+}
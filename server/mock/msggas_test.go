@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestMsgGasLimit(t *testing.T) {
+	cfg := storetypes.KVGasConfig()
+	writeGas := func(kv string) int64 {
+		return int64(cfg.WriteCostFlat + cfg.WriteCostPerByte*uint64(len(kv)-1))
+	}
+	const limit = 3000
+	expensive := "expensive=" + strings.Repeat("x", 100)
+	tx := []byte("a=1&" + expensive + "&b=2")
+
+	t.Run("whole tx fails", func(t *testing.T) {
+		app := setupTestApp(t, nil, SetMsgGasLimit(limit))
+		res := finalizeAndCommit(t, app, 1, tx).TxResults[0]
+		require.Equal(t, ErrMsgOutOfGas.ABCICode(), res.Code, res.Log)
+		require.Equal(t, Codespace, res.Codespace)
+		require.Nil(t, queryStore(t, app, "main", "a", 1).Value)
+	})
+
+	t.Run("partial application", func(t *testing.T) {
+		app := setupTestApp(t, nil, SetMsgGasLimit(limit), SetPartialMsgApplication(true))
+		res := finalizeAndCommit(t, app, 1, tx).TxResults[0]
+		require.Equal(t, uint32(0), res.Code, res.Log)
+		require.Contains(t, res.Log, "skipped expensive")
+		// the expensive msg is charged its limit, the others what they used
+		require.Equal(t, writeGas("a=1")+limit+writeGas("b=2"), res.GasUsed)
+
+		require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 1).Value)
+		require.Nil(t, queryStore(t, app, "main", "expensive", 1).Value)
+		require.Equal(t, []byte("2"), queryStore(t, app, "main", "b", 1).Value)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		app := setupTestApp(t, nil)
+		res := finalizeAndCommit(t, app, 1, tx).TxResults[0]
+		require.Equal(t, uint32(0), res.Code, res.Log)
+		require.Equal(t, writeGas("a=1")+writeGas(expensive)+writeGas("b=2"), res.GasUsed)
+		require.Equal(t, []byte(strings.Repeat("x", 100)), queryStore(t, app, "main", "expensive", 1).Value)
+	})
+}
+
+func TestPartialMsgApplication(t *testing.T) {
+	app := setupTestApp(t, nil, SetPartialMsgApplication(true))
+
+	res := finalizeAndCommit(t, app, 1, []byte("a=1&"+ReservedKeyPrefix+"x=y&b=2")).TxResults[0]
+	require.Equal(t, uint32(0), res.Code, res.Log)
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 1).Value)
+	require.Nil(t, queryStore(t, app, "main", ReservedKeyPrefix+"x", 1).Value)
+	require.Equal(t, []byte("2"), queryStore(t, app, "main", "b", 1).Value)
+}
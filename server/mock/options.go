@@ -0,0 +1,428 @@
+package mock
+
+import (
+	"io"
+	"time"
+
+	dbm "github.com/tendermint/tm-db"
+	"go.opentelemetry.io/otel/trace"
+
+	bam "github.com/cosmos/cosmos-sdk/baseapp"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// File for storing the optional functions accepted by NewApp, for options
+// that need access to non-exported fields of the App
+
+// Option is a functional option applied to the App by NewApp before the
+// underlying BaseApp is created.
+type Option func(*App)
+
+// SetTxEventIndexSize returns an option that bounds how many txs the app
+// remembers events for. Once full, the oldest entries are evicted first.
+func SetTxEventIndexSize(size int) Option {
+	return func(app *App) { app.txEventIndexSize = size }
+}
+
+// SetStrictGenesis returns an option that makes InitChain reject app state
+// containing fields that are not part of the GenesisJSON shape, e.g. a
+// misspelled "values" key, instead of silently ignoring them.
+func SetStrictGenesis(strict bool) Option {
+	return func(app *App) { app.strictGenesis = strict }
+}
+
+// SetOnCacheEvent returns an option that installs an observer notified at
+// each cache multistore boundary of a block, see the CacheEvent constants for
+// the reported kinds.
+func SetOnCacheEvent(onCacheEvent func(kind string, height int64)) Option {
+	return func(app *App) { app.onCacheEvent = onCacheEvent }
+}
+
+// SetInMemory returns an option that backs the app with an in-memory db
+// rather than a LevelDB under the root directory, which is then unused. The
+// state of such an app is lost once it is closed.
+func SetInMemory(enabled bool) Option {
+	return func(app *App) { app.inMemory = enabled }
+}
+
+// SetDBWrapper returns an option that wraps the db backing the app, e.g. with
+// a FaultDB to simulate storage failures.
+func SetDBWrapper(wrapper func(dbm.DB) dbm.DB) Option {
+	return func(app *App) { app.dbWrapper = wrapper }
+}
+
+// SetKVGasConfig returns an option that meters accesses to the named store
+// with gasConfig instead of the default KVStore gas config, e.g. to price
+// writes much higher than reads.
+func SetKVGasConfig(storeName string, gasConfig storetypes.GasConfig) Option {
+	return func(app *App) {
+		if app.kvGasConfigs == nil {
+			app.kvGasConfigs = make(map[string]storetypes.GasConfig)
+		}
+		app.kvGasConfigs[storeName] = gasConfig
+	}
+}
+
+// SetUpgradeRules returns an option that makes the handlers switch to rules
+// from rules.Height on, e.g. to a new gas schedule or a stricter validation
+// of the kvstore txs.
+func SetUpgradeRules(rules UpgradeRules) Option {
+	return func(app *App) { app.upgradeRules = &rules }
+}
+
+// SetConflictMerge returns an option that resolves the writes of several txs
+// to the same key with merge instead of keeping the last one. The kvstore
+// txs of a block stage their writes to keys other than the special keys,
+// which are merged into the keys in block order once the block is executed,
+// so txs writing the same key don't conflict when executed concurrently and
+// don't see each other's writes either. A key only one tx of the block
+// writes is set as usual, merge is never called for it.
+func SetConflictMerge(merge MergeFunc) Option {
+	return func(app *App) { app.mergeFunc = merge }
+}
+
+// SetHandlerMiddlewares returns an option that wraps the handler of the
+// kvstore msgs with middlewares, in order: the first one is the outermost and
+// sees every msg first. Each call replaces the middlewares of the previous
+// one.
+func SetHandlerMiddlewares(middlewares ...HandlerMiddleware) Option {
+	return func(app *App) { app.middlewares = middlewares }
+}
+
+// SetWriteOnce returns an option that makes the keys of the main store
+// write-once: a tx setting, swapping, deleting or crediting a key already
+// present fails with ErrImmutableKey, leaving its value untouched. Genesis
+// values and the special keys are exempt. Combined with SetConflictMerge, a
+// write staged by an earlier tx of the block counts as presence, so only the
+// first of the txs writing a new key gets to create it.
+func SetWriteOnce(enabled bool) Option {
+	return func(app *App) { app.writeOnce = enabled }
+}
+
+// SetStrictReads returns an option that fails the read and swap txs reading
+// a key absent from the main store with ErrUnsetKey, rather than reading it
+// as nil. A key deleted since it was set is absent as well.
+func SetStrictReads(enabled bool) Option {
+	return func(app *App) { app.strictReads = enabled }
+}
+
+// SetMaxValueSize returns an option that rejects writes of values longer than
+// max bytes to the main store with ErrValueTooLarge. Unlike the limit of the
+// upgrade rules, which kvstore txs check before writing, it is enforced by the
+// store handed to the handlers, so no tx can get an oversized value written
+// whatever path it takes. The reserved keys and the other stores are exempt,
+// but the writes staged under reserved keys by SetConflictMerge are checked
+// when staged, and again once merged, an oversized merged value failing
+// FinalizeBlock. Genesis values are subject to it as well: ValidateInitChain rejects an
+// oversized one with ErrValueTooLarge and InitChain panics on it.
+func SetMaxValueSize(max int) Option {
+	return func(app *App) { app.maxValueSize = max }
+}
+
+// SetAmplificationTracking returns an option that counts the logical store
+// accesses of every block and the db accesses they amplify into, reported by
+// LastAmplification once the block is committed.
+func SetAmplificationTracking(enabled bool) Option {
+	return func(app *App) {
+		if enabled {
+			app.accessCounts = &accessCounts{}
+		} else {
+			app.accessCounts = nil
+		}
+	}
+}
+
+// SetTracerProvider returns an option that makes FinalizeBlock record a
+// span per block, with a child span per tx execution, using a tracer of tp.
+func SetTracerProvider(tp trace.TracerProvider) Option {
+	return func(app *App) { app.tracer = tp.Tracer(tracerName) }
+}
+
+// SetFlatFee returns an option that makes the ante handler deduct fee from
+// the balance of the fee payer every tx names with FeeTxPrefix, crediting it
+// to the balance of FeeCollector, queryable at "/mock/fees". A tx naming no
+// payer or one that can't cover the fee fails in the ante handler, in
+// CheckTx as in FinalizeBlock. Fees only move coins between balances, so
+// they leave the supply unchanged.
+func SetFlatFee(fee sdk.Coins) Option {
+	return func(app *App) { app.flatFee = fee }
+}
+
+// SetInFlightKeyTracking returns an option that makes CheckTx reject a tx
+// targeting a key which is already targeted by a tx waiting in the mempool,
+// until that tx is committed or evicted.
+func SetInFlightKeyTracking(enabled bool) Option {
+	return func(app *App) {
+		if enabled {
+			app.inFlight = newInFlightKeys()
+		} else {
+			app.inFlight = nil
+		}
+	}
+}
+
+// SetShuffleProposals returns an option that makes PrepareProposal shuffle
+// the proposed txs, deterministically for a given block height.
+func SetShuffleProposals(shuffle bool) Option {
+	return func(app *App) { app.shuffleProposals = shuffle }
+}
+
+// SetRetainWindow returns an option that makes Commit ask consensus to prune
+// every block older than the last window blocks, i.e. to report a retain
+// height of height-window once the chain is taller than window.
+func SetRetainWindow(window int64) Option {
+	return func(app *App) { app.retainWindow = window }
+}
+
+// SetBlockGasLimit returns an option that caps the gas the txs of a block can
+// consume altogether. A zero limit leaves block gas unlimited.
+func SetBlockGasLimit(limit uint64) Option {
+	return func(app *App) { app.blockGasLimit = limit }
+}
+
+// SetExtraStores returns an option that mounts additional KV stores with the
+// given names next to the main store.
+func SetExtraStores(names ...string) Option {
+	return func(app *App) { app.extraStoreNames = append(app.extraStoreNames, names...) }
+}
+
+// SetShadowStore returns an option that mounts a store named ShadowStoreName
+// next to the main store and mirrors every write to the main store into it,
+// modelling the dual-write phase of a store migration. AssertShadowEqual
+// checks both stores still agree.
+func SetShadowStore(enabled bool) Option {
+	return func(app *App) { app.shadowStore = enabled }
+}
+
+// SetCommitSync returns an option that sets whether the writes to the db
+// backing the app are synced to disk. By default the stores decide, syncing
+// only the commit info of every height: syncing every write is more durable
+// but slower, syncing none faster but leaves recent heights to be lost on a
+// machine crash. A db wrapper set with SetDBWrapper sees the forced flag.
+func SetCommitSync(sync bool) Option {
+	return func(app *App) { app.commitSync = &sync }
+}
+
+// SetStoreLoader returns an option that overrides how the mounted stores are
+// loaded from disk, e.g. with an upgrade store loader staging store additions
+// at a given height.
+func SetStoreLoader(loader bam.StoreLoader) Option {
+	return func(app *App) { app.storeLoader = loader }
+}
+
+// SetStoreUpgrades returns an option that loads the latest version with the
+// given store upgrades applied, so stores can be added, renamed or deleted
+// between two runs over the same data directory. The upgrades are applied
+// on every load, use SetStoreLoader with an upgrade store loader to only
+// apply them at a given height.
+func SetStoreUpgrades(upgrades *storetypes.StoreUpgrades) Option {
+	return SetStoreLoader(func(ms sdk.CommitMultiStore) error {
+		return ms.LoadLatestVersionAndUpgrade(upgrades)
+	})
+}
+
+// SetRecoveryHandler returns an option that registers a handler for panics
+// raised while running a tx. It runs before the default BaseApp recovery,
+// so returning an error from it decides how the panic surfaces.
+func SetRecoveryHandler(handler bam.RecoveryHandler) Option {
+	return func(app *App) { app.recoveryHandlers = append(app.recoveryHandlers, handler) }
+}
+
+// SetTwoPhaseCommit returns an option that writes the block state and
+// computes the resulting app hash in FinalizeBlock, leaving only persisting
+// it to the following Commit. The tentative app hash is returned as the
+// FinalizeBlock AppHash.
+func SetTwoPhaseCommit(enabled bool) Option {
+	return func(app *App) { app.twoPhaseCommit = enabled }
+}
+
+// SetCommitInterval returns an option that only persists the state of every
+// n-th block on Commit, the writes of the blocks in between accumulating in
+// the deliver state until then. The versions of those blocks are still
+// committed, holding the state of the last commit boundary, so heights keep
+// matching versions. CheckTx runs against the state of the last boundary,
+// and the retain height reported to consensus never goes past it. The halt
+// height and snapshot interval of BaseApp only take effect on boundaries.
+// This trades durability for throughput: an app closed between boundaries
+// loses the blocks finalized since the last one. Writes already flushed to
+// the commit multistore within a block, e.g. by a two-phase commit or
+// FlushPerTx, are committed with the block as usual.
+func SetCommitInterval(n int64) Option {
+	return func(app *App) { app.commitInterval = n }
+}
+
+// SetAppHashEvents returns an option that emits an EventTypeLastAppHash
+// block event from FinalizeBlock, carrying the height and hex encoded app
+// hash of the last committed block, so the hash chain can be followed from
+// the block events alone.
+func SetAppHashEvents(enabled bool) Option {
+	return func(app *App) { app.appHashEvents = enabled }
+}
+
+// SetTxCounting returns an option that counts the txs successfully applied
+// since genesis under TxCountKey, as served by the /mock/txcount query. The
+// count is updated once per block, after its txs have executed.
+func SetTxCounting(enabled bool) Option {
+	return func(app *App) { app.txCounting = enabled }
+}
+
+// SetBlockStatsCSV returns an option that writes a CSV row to w for every
+// finalized block, after a header row: its height, number of txs, block gas
+// used, execution time in nanoseconds and number of tx re-executions by the
+// concurrent scheduler.
+func SetBlockStatsCSV(w io.Writer) Option {
+	return func(app *App) { app.blockStats = newBlockStatsWriter(w) }
+}
+
+// SetSupplyInvariant returns an option that checks after every block that
+// the balances add up to the supply: the balances set by genesis plus the
+// coins credited by the kvBankTxs since. A block breaking the invariant fails
+// FinalizeBlock with ErrSupplyInvariant. The supply is tracked from genesis
+// on, so the option must be set before InitChain runs.
+func SetSupplyInvariant(enabled bool) Option {
+	return func(app *App) { app.supplyInvariant = enabled }
+}
+
+// SetTxDelay returns an option that sleeps for delay before executing each
+// tx in FinalizeBlock, simulating slow execution.
+func SetTxDelay(delay time.Duration) Option {
+	return func(app *App) { app.txDelay = delay }
+}
+
+// SetTxRateLimit returns an option that limits tx execution with a token
+// bucket holding up to capacity tokens and refilled with perBlock tokens at
+// the start of every block. Each executed tx takes a token, txs finding the
+// bucket empty fail with ErrRateLimited. The bucket counts blocks and txs
+// rather than wall clock time, so the same blocks are always limited the
+// same way.
+func SetTxRateLimit(capacity, perBlock int) Option {
+	return func(app *App) { app.rateLimiter = newTxRateLimiter(capacity, perBlock) }
+}
+
+// SetPruning returns an option that prunes the committed versions of the
+// stores according to opts, by default every version is kept.
+func SetPruning(opts storetypes.PruningOptions) Option {
+	return func(app *App) { app.pruning = &opts }
+}
+
+// SetMsgGasLimit returns an option that caps the gas each msg of a tx may
+// consume, so a single expensive msg cannot exhaust the whole tx budget. A
+// msg going over the limit fails with ErrMsgOutOfGas and is charged the
+// limit.
+func SetMsgGasLimit(limit uint64) Option {
+	return func(app *App) { app.msgGasLimit = limit }
+}
+
+// SetPartialMsgApplication returns an option that applies the msgs of a tx
+// independently: a failing msg is skipped, its writes discarded, while the
+// other msgs of the tx still apply.
+func SetPartialMsgApplication(enabled bool) Option {
+	return func(app *App) { app.partialMsgs = enabled }
+}
+
+// SetConcurrentExecution returns an option that executes the txs of a block
+// through the optimistic concurrency scheduler with the given number of
+// workers, a zero count executing them sequentially.
+func SetConcurrentExecution(workers int) Option {
+	return func(app *App) { app.concurrencyWorkers = workers }
+}
+
+// SetReexecutionBudget returns an option that caps how many times the OCC
+// scheduler re-executes a single tx of a concurrently executed block. Once a
+// tx runs out of budget, its further executions are skipped and the block is
+// executed again sequentially, so the results stay the same. A zero budget
+// falls back on the first conflict, a negative one leaves the re-executions
+// unbounded, which is the default.
+func SetReexecutionBudget(budget int) Option {
+	return func(app *App) { app.reexecutionBudget = budget }
+}
+
+// SetScheduleHook returns an option that wraps every tx execution of the
+// concurrent scheduler, letting tests control how executions interleave,
+// e.g. by holding back run until another tx has executed. The hook must call
+// run exactly once, it is called again for every re-execution of a tx.
+func SetScheduleHook(hook func(txIndex int, run func())) Option {
+	return func(app *App) { app.scheduleHook = hook }
+}
+
+// SetRejectNoopTxs returns an option that rejects CheckTx txs which would
+// set every key they target to the value it already holds in the last
+// committed state.
+func SetRejectNoopTxs(enabled bool) Option {
+	return func(app *App) { app.rejectNoopTxs = enabled }
+}
+
+// SetDedupTxs returns an option that skips txs byte-identical to an earlier
+// tx of the same block, failing them with ErrDuplicateTx without executing
+// them.
+func SetDedupTxs(enabled bool) Option {
+	return func(app *App) { app.dedupTxs = enabled }
+}
+
+// SetRefundFailedTxGas returns an option that returns the gas used by a
+// failed tx to the block, so it does not count against the block gas limit.
+func SetRefundFailedTxGas(enabled bool) Option {
+	return func(app *App) { app.refundFailedTxGas = enabled }
+}
+
+// SetAppHashFunc returns an option computing fn over the committed state after
+// every Commit, exposed through ExperimentalAppHash. It lets tests check the
+// store's app hash against an independent derivation.
+func SetAppHashFunc(fn AppHashFunc) Option {
+	return func(app *App) { app.appHashFunc = fn }
+}
+
+// SetMaxBlockTxs returns an option capping the number of txs of a block.
+// PrepareProposal leaves the overflow out of the proposal, and FinalizeBlock
+// fails the txs past the cap of a block proposed anyway with ErrBlockTxLimit
+// without executing them. A zero max leaves the tx count unlimited.
+func SetMaxBlockTxs(max int) Option {
+	return func(app *App) { app.maxBlockTxs = max }
+}
+
+// SetTimeSource returns an option stamping the blocks whose FinalizeBlock
+// request leaves the time unset with the time returned by now, so tests can
+// control the block time from a single clock.
+func SetTimeSource(now func() time.Time) Option {
+	return func(app *App) { app.timeSource = now }
+}
+
+// SetDeterminismCheck returns an option that also executes every block
+// sequentially when concurrent execution is enabled, failing FinalizeBlock if
+// the concurrent execution yields different tx results or state. It is meant
+// to turn nondeterminism into a hard failure while testing.
+func SetDeterminismCheck(enabled bool) Option {
+	return func(app *App) { app.determinismCheck = enabled }
+}
+
+// SetSimpleStores returns an option that mounts the stores with the given
+// names, the main store included, as plain db-backed stores instead of IAVL
+// trees. Such stores are cheaper to commit, but keep no history and provide
+// no proofs: their content doesn't contribute to the app hash, they are only
+// served by the "/mock" queries, and those return the latest content whatever
+// the height queried.
+func SetSimpleStores(names ...string) Option {
+	return func(app *App) {
+		if app.simpleStores == nil {
+			app.simpleStores = make(map[string]struct{})
+		}
+		for _, name := range names {
+			app.simpleStores[name] = struct{}{}
+		}
+	}
+}
+
+// SetFlushPolicy returns an option that sets when the writes of a block are
+// flushed to the commit multistore, FlushPerBlock by default. FlushPerTx
+// can't be combined with SetSupplyInvariant or SetTxCounting.
+func SetFlushPolicy(policy FlushPolicy) Option {
+	return func(app *App) { app.flushPolicy = policy }
+}
+
+// SetSchedulerHints returns an option that has concurrent execution honor
+// the keys txs declare depending on with NewHintedTx. Hints are ignored
+// otherwise.
+func SetSchedulerHints(enabled bool) Option {
+	return func(app *App) { app.schedulerHints = enabled }
+}
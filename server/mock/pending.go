@@ -0,0 +1,67 @@
+package mock
+
+import (
+	"bytes"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// PendingWrites returns the writes and deletes the deliver state cache holds
+// on top of the commit multistore, i.e. what committing the block being
+// finalized would change, store by store in mount order and key order
+// within a store. It can be called once FinalizeBlock returns and until the
+// block is committed, or from a SetOnCacheEvent observer on CacheEventWrite.
+// Writes already flushed to the commit multistore, e.g. by FlushPerTx or a
+// two-phase commit, are no longer pending. It returns nil when no block is
+// being finalized.
+func (app *App) PendingWrites() []KVChange {
+	if !app.pendingBlock {
+		return nil
+	}
+
+	ctx := app.GetContextForDeliverTx(nil)
+	var changes []KVChange
+	for _, key := range app.storeKeys() {
+		changes = append(changes, diffKVStores(key, ctx.MultiStore().GetKVStore(key), app.CommitMultiStore().GetKVStore(key))...)
+	}
+	return changes
+}
+
+// diffKVStores walks both stores in key order and returns the changes turning
+// the content of base into the one of pending.
+func diffKVStores(storeKey sdk.StoreKey, pending, base sdk.KVStore) []KVChange {
+	iterP, iterB := pending.Iterator(nil, nil), base.Iterator(nil, nil)
+	defer iterP.Close()
+	defer iterB.Close()
+
+	var changes []KVChange
+	for iterP.Valid() || iterB.Valid() {
+		cmp := -1
+		switch {
+		case !iterP.Valid():
+			cmp = 1
+		case iterB.Valid():
+			cmp = bytes.Compare(iterP.Key(), iterB.Key())
+		}
+
+		switch {
+		case cmp < 0:
+			changes = append(changes, KVChange{StoreKey: storeKey.Name(), Key: copyBytes(iterP.Key()), Value: copyBytes(iterP.Value())})
+			iterP.Next()
+		case cmp > 0:
+			changes = append(changes, KVChange{StoreKey: storeKey.Name(), Key: copyBytes(iterB.Key()), Delete: true})
+			iterB.Next()
+		default:
+			if !bytes.Equal(iterP.Value(), iterB.Value()) {
+				changes = append(changes, KVChange{StoreKey: storeKey.Name(), Key: copyBytes(iterP.Key()), Value: copyBytes(iterP.Value())})
+			}
+			iterP.Next()
+			iterB.Next()
+		}
+	}
+	return changes
+}
+
+func copyBytes(bz []byte) []byte {
+	return append([]byte{}, bz...)
+}
@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestPendingWrites(t *testing.T) {
+	var atWrite []KVChange
+	var app *App
+	app = setupTestApp(t, []KV{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}},
+		SetExtraStores("extra"),
+		SetOnCacheEvent(func(kind string, _ int64) {
+			if kind == CacheEventWrite {
+				atWrite = app.PendingWrites()
+			}
+		}),
+	)
+	finalizeAndCommit(t, app, 1)
+	require.Nil(t, app.PendingWrites())
+
+	// the failed txs leave nothing pending, the swap deletes b
+	_, err := app.FinalizeBlock(context.Background(), &abci.RequestFinalizeBlock{Height: 2, Txs: [][]byte{
+		[]byte("a=3"),
+		[]byte(PanicKey + "=boom"),
+		[]byte(ReservedKeyPrefix + "x=1"),
+		[]byte("c=4"),
+		NewSwapTx("b", "d"),
+	}})
+	require.NoError(t, err)
+
+	expected := []KVChange{
+		{StoreKey: "main", Key: []byte("a"), Value: []byte("3")},
+		{StoreKey: "main", Key: []byte("b"), Delete: true},
+		{StoreKey: "main", Key: []byte("c"), Value: []byte("4")},
+		{StoreKey: "main", Key: []byte("d"), Value: []byte("2")},
+	}
+	require.Equal(t, expected, app.PendingWrites())
+
+	_, err = app.Commit(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, expected, atWrite)
+	require.Nil(t, app.PendingWrites())
+}
@@ -0,0 +1,34 @@
+package mock
+
+import (
+	"math/rand"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// prepareProposalHandler proposes the txs handed over by the mempool. When
+// proposal shuffling is enabled they are shuffled using the block height as
+// the seed, so the same height always yields the same ordering. When a block
+// tx limit is set the overflow is left out of the proposal, staying in the
+// mempool for a later block.
+func (app *App) prepareProposalHandler(_ sdk.Context, req *abci.RequestPrepareProposal) (*abci.ResponsePrepareProposal, error) {
+	txs := make([][]byte, len(req.Txs))
+	copy(txs, req.Txs)
+
+	if app.shuffleProposals {
+		rng := rand.New(rand.NewSource(req.Height))
+		rng.Shuffle(len(txs), func(i, j int) { txs[i], txs[j] = txs[j], txs[i] })
+	}
+	if app.maxBlockTxs > 0 && len(txs) > app.maxBlockTxs {
+		txs = txs[:app.maxBlockTxs]
+	}
+
+	records := make([]*abci.TxRecord, len(txs))
+	for i, tx := range txs {
+		records[i] = &abci.TxRecord{Action: abci.TxRecord_UNMODIFIED, Tx: tx}
+	}
+
+	return &abci.ResponsePrepareProposal{TxRecords: records}, nil
+}
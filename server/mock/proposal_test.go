@@ -0,0 +1,73 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func prepareProposal(t *testing.T, app abci.Application, height int64, txs [][]byte) [][]byte {
+	t.Helper()
+
+	resp, err := app.PrepareProposal(context.Background(), &abci.RequestPrepareProposal{Height: height, Txs: txs})
+	require.NoError(t, err)
+
+	proposed := make([][]byte, len(resp.TxRecords))
+	for i, record := range resp.TxRecords {
+		require.Equal(t, abci.TxRecord_UNMODIFIED, record.Action)
+		proposed[i] = record.Tx
+	}
+	return proposed
+}
+
+func TestPrepareProposalShuffle(t *testing.T) {
+	txs := make([][]byte, 20)
+	for i := range txs {
+		txs[i] = []byte(fmt.Sprintf("key%d=v", i))
+	}
+
+	app := setupTestApp(t, nil)
+	require.Equal(t, txs, prepareProposal(t, app, 1, txs), "txs are proposed as is by default")
+
+	app = setupTestApp(t, nil, SetShuffleProposals(true))
+	first := prepareProposal(t, app, 1, txs)
+	require.ElementsMatch(t, txs, first)
+	require.NotEqual(t, txs, first)
+	require.Equal(t, first, prepareProposal(t, app, 1, txs), "same height should yield the same ordering")
+	require.NotEqual(t, first, prepareProposal(t, app, 2, txs), "different heights should yield different orderings")
+
+	// the ordering only depends on the height, not on the app instance
+	other := setupTestApp(t, nil, SetShuffleProposals(true))
+	require.Equal(t, first, prepareProposal(t, other, 1, txs))
+}
+
+func TestMaxBlockTxs(t *testing.T) {
+	txs := make([][]byte, 5)
+	for i := range txs {
+		txs[i] = []byte(fmt.Sprintf("key%d=v", i))
+	}
+
+	app := setupTestApp(t, nil, SetMaxBlockTxs(3))
+	require.Equal(t, txs[:3], prepareProposal(t, app, 1, txs))
+
+	app = setupTestApp(t, nil, SetMaxBlockTxs(3), SetShuffleProposals(true))
+	proposed := prepareProposal(t, app, 1, txs)
+	require.Len(t, proposed, 3)
+	require.Subset(t, txs, proposed)
+
+	// a proposer ignoring the cap only gets the txs within it executed
+	resp := finalizeAndCommit(t, app, 1, txs...)
+	for i, res := range resp.TxResults {
+		if i < 3 {
+			require.Equal(t, uint32(0), res.Code, res.Log)
+			continue
+		}
+		require.Equal(t, ErrBlockTxLimit.ABCICode(), res.Code)
+		require.Equal(t, Codespace, res.Codespace)
+	}
+	require.Equal(t, []byte("v"), queryStore(t, app, "main", "key2", 1).Value)
+	require.Nil(t, queryStore(t, app, "main", "key3", 1).Value)
+}
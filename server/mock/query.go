@@ -0,0 +1,649 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/prefix"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/query"
+)
+
+// Query implements the ABCI interface. Paths under "/mock" are served by the
+// mock app itself, everything else is delegated to BaseApp.
+func (app *App) Query(ctx context.Context, req *abci.RequestQuery) (*abci.ResponseQuery, error) {
+	requestPath, rawQuery := req.Path, ""
+	if i := strings.IndexByte(requestPath, '?'); i >= 0 {
+		requestPath, rawQuery = requestPath[:i], requestPath[i+1:]
+	}
+	path := splitPath(requestPath)
+	if len(path) == 0 || path[0] != "mock" {
+		return app.BaseApp.Query(ctx, req)
+	}
+
+	var resp abci.ResponseQuery
+	if len(path) < 2 {
+		resp = sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrUnknownRequest, "no mock query path provided"))
+		return &resp, nil
+	}
+
+	switch path[1] {
+	case "txevents":
+		resp = app.handleQueryTxEvents(*req)
+
+	case "events":
+		resp = app.handleQueryEvents(rawQuery)
+
+	case "kv":
+		resp = app.handleQueryKV(*req)
+
+	case "size":
+		resp = app.handleQuerySize(*req)
+
+	case "range":
+		resp = app.handleQueryRange(*req)
+
+	case "prefix":
+		resp = app.handleQueryPrefix(*req)
+
+	case "history":
+		resp = app.handleQueryHistory(*req)
+
+	case "genesis":
+		resp = app.handleQueryGenesis(*req)
+
+	case "heights":
+		resp = app.handleQueryHeights(*req)
+
+	case "appversion":
+		resp = app.handleQueryAppVersion(*req)
+
+	case "stores":
+		resp = app.handleQueryStores(*req)
+
+	case "params":
+		resp = app.handleQueryParams(*req)
+
+	case "initialized":
+		resp = app.handleQueryInitialized(*req)
+
+	case "fees":
+		resp = app.handleQueryFees(*req)
+
+	case "txcount":
+		resp = app.handleQueryTxCount(*req)
+
+	case "balances":
+		resp = app.handleQueryBalances(*req)
+
+	case "version":
+		resp = app.handleQueryVersion(*req)
+
+	default:
+		resp = sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrUnknownRequest, "unknown mock query: %s", path[1]))
+	}
+	return &resp, nil
+}
+
+// handleQueryTxEvents returns the JSON encoded events of the tx whose sha256
+// hash is given as the request data.
+func (app *App) handleQueryTxEvents(req abci.RequestQuery) abci.ResponseQuery {
+	if len(req.Data) != 32 {
+		return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid tx hash length %d", len(req.Data)))
+	}
+
+	var hash [32]byte
+	copy(hash[:], req.Data)
+
+	events, ok := app.txEvents.get(hash)
+	if !ok {
+		return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrKeyNotFound, "no events indexed for tx %X", req.Data))
+	}
+
+	bz, err := json.Marshal(events)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode tx events"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Value:     bz,
+	}
+}
+
+// handleQueryEvents returns the JSON encoded events of the given type
+// emitted by the txs of the last committed block, in the order they were
+// emitted. The type is passed in the query string of the path, i.e.
+// "/mock/events?type=kvstore".
+func (app *App) handleQueryEvents(rawQuery string) abci.ResponseQuery {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, err.Error()))
+	}
+	eventType := values.Get("type")
+	if eventType == "" {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, "no event type provided"))
+	}
+
+	events := []BlockEvent{}
+	app.eventsMtx.RLock()
+	for _, event := range app.committedEvents {
+		if event.Event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	height := app.committedHeight
+	app.eventsMtx.RUnlock()
+
+	bz, err := json.Marshal(events)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode block events"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    height,
+		Value:     bz,
+	}
+}
+
+// QueryInfoDefault is set as the info of a "/mock/kv" response whose value
+// is the requested default rather than a stored value.
+const QueryInfoDefault = "default"
+
+// QueryKVParams defines the params of the "/mock/kv" query, passed JSON
+// encoded as the request data. Default, if set, is returned in place of the
+// value when the key is absent from the main store.
+//
+// KeyEncoding sets how the key is encoded in the JSON params, and
+// ValueEncoding how the default is, both being base64 encoded by default like
+// any other byte slice. A response value is returned as is unless
+// ValueEncoding is set, in which case it is encoded with it. Only this query
+// takes encodings: the keys and bounds of "/mock/range", "/mock/prefix" and
+// "/mock/history" are plain byte slices.
+type QueryKVParams struct {
+	Key           []byte
+	Default       []byte
+	KeyEncoding   Encoding
+	ValueEncoding Encoding
+}
+
+// queryKVParamsJSON is the JSON representation of QueryKVParams.
+type queryKVParamsJSON struct {
+	Key           string   `json:"key"`
+	Default       *string  `json:"default,omitempty"`
+	KeyEncoding   Encoding `json:"key_encoding,omitempty"`
+	ValueEncoding Encoding `json:"value_encoding,omitempty"`
+}
+
+// MarshalJSON encodes the key and default with their encodings.
+func (p QueryKVParams) MarshalJSON() ([]byte, error) {
+	params := queryKVParamsJSON{KeyEncoding: p.KeyEncoding, ValueEncoding: p.ValueEncoding}
+	var err error
+	if params.Key, err = p.KeyEncoding.Encode(p.Key); err != nil {
+		return nil, err
+	}
+	if p.Default != nil {
+		def, err := p.ValueEncoding.Encode(p.Default)
+		if err != nil {
+			return nil, err
+		}
+		params.Default = &def
+	}
+	return json.Marshal(params)
+}
+
+// UnmarshalJSON decodes the key and default with their encodings.
+func (p *QueryKVParams) UnmarshalJSON(bz []byte) error {
+	var params queryKVParamsJSON
+	if err := json.Unmarshal(bz, &params); err != nil {
+		return err
+	}
+	key, err := params.KeyEncoding.Decode(params.Key)
+	if err != nil {
+		return fmt.Errorf("key: %w", err)
+	}
+	*p = QueryKVParams{Key: key, KeyEncoding: params.KeyEncoding, ValueEncoding: params.ValueEncoding}
+	if params.Default != nil {
+		if p.Default, err = params.ValueEncoding.Decode(*params.Default); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	return nil
+}
+
+// handleQueryKV returns the value stored under the requested key of the main
+// store, falling back to the requested default when the key is absent. When
+// the request asks for a proof, the response carries the proof of the
+// presence of the key or, if it is absent, of its absence, to verify against
+// the app hash of the queried height.
+func (app *App) handleQueryKV(req abci.RequestQuery) abci.ResponseQuery {
+	var params QueryKVParams
+	if err := json.Unmarshal(req.Data, &params); err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrJSONUnmarshal, err.Error()))
+	}
+	if len(params.Key) == 0 {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, "empty key"))
+	}
+	if _, err := params.ValueEncoding.Encode(nil); err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, err.Error()))
+	}
+
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	resp := abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Key:       params.Key,
+		Value:     ctx.KVStore(app.capKeyMainStore).Get(params.Key),
+	}
+	if resp.Value == nil && params.Default != nil {
+		resp.Value = params.Default
+		resp.Info = QueryInfoDefault
+	}
+	if req.Prove {
+		// the proof of the presence or absence of the key, a default having
+		// no bearing on it
+		proved, err := app.BaseApp.Query(context.Background(), &abci.RequestQuery{
+			Path:   fmt.Sprintf("/store/%s/key", app.capKeyMainStore.Name()),
+			Data:   params.Key,
+			Height: resp.Height,
+			Prove:  true,
+		})
+		if err != nil {
+			return sdkerrors.QueryResult(err)
+		}
+		if proved.Code != 0 {
+			return *proved
+		}
+		resp.ProofOps = proved.ProofOps
+	}
+	if resp.Value != nil && params.ValueEncoding != "" {
+		value, _ := params.ValueEncoding.Encode(resp.Value)
+		resp.Value = []byte(value)
+	}
+	return resp
+}
+
+// handleQuerySize returns the JSON encoded byte length of the value stored
+// under the key given as the request data, so the size of a large value can be
+// learnt without transferring it.
+func (app *App) handleQuerySize(req abci.RequestQuery) abci.ResponseQuery {
+	if len(req.Data) == 0 {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, "empty key"))
+	}
+
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	value := ctx.KVStore(app.capKeyMainStore).Get(req.Data)
+	if value == nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrKeyNotFound, "key %q", req.Data))
+	}
+
+	bz, err := json.Marshal(len(value))
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode value size"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Key:       req.Data,
+		Value:     bz,
+	}
+}
+
+// QueryRangeParams defines the params of the "/mock/range" query, passed JSON
+// encoded as the request data. Start is inclusive and End exclusive, a nil
+// bound leaves that side of the domain open.
+type QueryRangeParams struct {
+	Start   []byte `json:"start,omitempty"`
+	End     []byte `json:"end,omitempty"`
+	Reverse bool   `json:"reverse,omitempty"`
+}
+
+// handleQueryRange returns the JSON encoded key/value pairs of the main store
+// within the requested domain. Pairs are always returned in byte-lexicographic
+// key order, ascending unless Reverse is set, so modules relying on the
+// iteration order get deterministic results.
+func (app *App) handleQueryRange(req abci.RequestQuery) abci.ResponseQuery {
+	var params QueryRangeParams
+	if len(req.Data) > 0 {
+		if err := json.Unmarshal(req.Data, &params); err != nil {
+			return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrJSONUnmarshal, err.Error()))
+		}
+	}
+
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+	store := ctx.KVStore(app.capKeyMainStore)
+
+	var iter sdk.Iterator
+	if params.Reverse {
+		iter = store.ReverseIterator(params.Start, params.End)
+	} else {
+		iter = store.Iterator(params.Start, params.End)
+	}
+	defer iter.Close()
+
+	kvs := []KV{}
+	for ; iter.Valid(); iter.Next() {
+		kvs = append(kvs, KV{Key: string(iter.Key()), Value: string(iter.Value())})
+	}
+
+	bz, err := json.Marshal(kvs)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode range"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Value:     bz,
+	}
+}
+
+// QueryPrefixParams defines the params of the "/mock/prefix" query, passed
+// JSON encoded as the request data. Pages are selected as by a
+// query.PageRequest: either by the Key cursor returned as the NextKey of the
+// previous page, or by Offset. A zero Limit defaults to query.DefaultLimit.
+type QueryPrefixParams struct {
+	Prefix []byte `json:"prefix,omitempty"`
+	Key    []byte `json:"key,omitempty"`
+	Offset uint64 `json:"offset,omitempty"`
+	Limit  uint64 `json:"limit,omitempty"`
+}
+
+// QueryPrefixResponse is the JSON encoded response of the "/mock/prefix"
+// query. NextKey is nil on the last page.
+type QueryPrefixResponse struct {
+	Pairs   []KV   `json:"pairs"`
+	NextKey []byte `json:"next_key,omitempty"`
+}
+
+// handleQueryPrefix returns a page of the key/value pairs of the main store
+// under the requested prefix, in ascending key order. The keys are returned
+// prefix included.
+func (app *App) handleQueryPrefix(req abci.RequestQuery) abci.ResponseQuery {
+	var params QueryPrefixParams
+	if len(req.Data) > 0 {
+		if err := json.Unmarshal(req.Data, &params); err != nil {
+			return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrJSONUnmarshal, err.Error()))
+		}
+	}
+
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+	store := prefix.NewStore(ctx.KVStore(app.capKeyMainStore), params.Prefix)
+
+	res := QueryPrefixResponse{Pairs: []KV{}}
+	pageRes, err := query.Paginate(store, &query.PageRequest{
+		Key:    params.Key,
+		Offset: params.Offset,
+		Limit:  params.Limit,
+	}, func(key []byte, value []byte) error {
+		res.Pairs = append(res.Pairs, KV{Key: string(params.Prefix) + string(key), Value: string(value)})
+		return nil
+	})
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, err.Error()))
+	}
+	res.NextKey = pageRes.NextKey
+
+	bz, err := json.Marshal(res)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode prefix page"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Value:     bz,
+	}
+}
+
+// QueryHistoryParams defines the params of the "/mock/history" query, passed
+// JSON encoded as the request data. The height range is inclusive; a zero
+// From starts at height 1 and a zero To ends at the latest height.
+type QueryHistoryParams struct {
+	Key  []byte `json:"key"`
+	From int64  `json:"from,omitempty"`
+	To   int64  `json:"to,omitempty"`
+}
+
+// HistoryEntry is the value a key took at a height, nil if it was absent.
+type HistoryEntry struct {
+	Height int64  `json:"height"`
+	Value  []byte `json:"value"`
+}
+
+// handleQueryHistory returns the JSON encoded values the requested key of the
+// main store took over the requested height range, with an entry for the
+// first height the key is present at and one for every height it changed at
+// after that, deletions included.
+func (app *App) handleQueryHistory(req abci.RequestQuery) abci.ResponseQuery {
+	var params QueryHistoryParams
+	if err := json.Unmarshal(req.Data, &params); err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrJSONUnmarshal, err.Error()))
+	}
+	if len(params.Key) == 0 {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, "empty key"))
+	}
+	latest := app.LastBlockHeight()
+	if params.From == 0 {
+		params.From = 1
+	}
+	if params.To == 0 {
+		params.To = latest
+	}
+	if params.From < 1 || params.From > params.To || params.To > latest {
+		return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrInvalidRequest,
+			"invalid height range [%d, %d], latest height is %d", params.From, params.To, latest))
+	}
+
+	history := []HistoryEntry{}
+	var prev []byte
+	for height := params.From; height <= params.To; height++ {
+		ctx, err := app.CreateQueryContext(height, false)
+		if err != nil {
+			return sdkerrors.QueryResult(err)
+		}
+		value := ctx.KVStore(app.capKeyMainStore).Get(params.Key)
+		if !bytes.Equal(value, prev) || (value == nil) != (prev == nil) {
+			history = append(history, HistoryEntry{Height: height, Value: value})
+		}
+		prev = value
+	}
+
+	bz, err := json.Marshal(history)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode history"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    params.To,
+		Key:       params.Key,
+		Value:     bz,
+	}
+}
+
+// handleQueryGenesis returns the raw app state the chain was initialized with.
+func (app *App) handleQueryGenesis(req abci.RequestQuery) abci.ResponseQuery {
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	appState := ctx.KVStore(app.capKeyMainStore).Get([]byte(GenesisKey))
+	if appState == nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrKeyNotFound, "no genesis app state committed"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Value:     appState,
+	}
+}
+
+// handleQueryInitialized returns whether InitChain has run, JSON encoded.
+func (app *App) handleQueryInitialized(_ abci.RequestQuery) abci.ResponseQuery {
+	bz, err := json.Marshal(app.Initialized())
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode initialized flag"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    app.LastBlockHeight(),
+		Value:     bz,
+	}
+}
+
+// handleQueryHeights returns the JSON encoded, ascending list of the versions
+// of the main store that survived pruning.
+func (app *App) handleQueryHeights(_ abci.RequestQuery) abci.ResponseQuery {
+	store, ok := app.CommitMultiStore().GetCommitKVStore(app.capKeyMainStore).(interface{ GetAllVersions() []int })
+	if !ok {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, "main store does not list its versions"))
+	}
+
+	versions := store.GetAllVersions()
+	heights := make([]int64, 0, len(versions))
+	for _, v := range versions {
+		heights = append(heights, int64(v))
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	bz, err := json.Marshal(heights)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode heights"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    app.LastBlockHeight(),
+		Value:     bz,
+	}
+}
+
+// handleQueryVersion returns the JSON encoded version the commit multistore
+// last committed, i.e. the latest committed height.
+func (app *App) handleQueryVersion(_ abci.RequestQuery) abci.ResponseQuery {
+	version := app.CommitMultiStore().LastCommitID().Version
+	bz, err := json.Marshal(version)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode version"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    version,
+		Value:     bz,
+	}
+}
+
+// handleQueryStores returns the JSON encoded, sorted names of the stores
+// mounted on the commit multistore.
+func (app *App) handleQueryStores(_ abci.RequestQuery) abci.ResponseQuery {
+	keys := app.CommitMultiStore().StoreKeys()
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, key.Name())
+	}
+	sort.Strings(names)
+
+	bz, err := json.Marshal(names)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode store names"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    app.LastBlockHeight(),
+		Value:     bz,
+	}
+}
+
+// QueryParamsResponse is the response of the /mock/params query, holding
+// the tunables the app was configured with by the NewApp options. Zero
+// limits mean unlimited.
+type QueryParamsResponse struct {
+	// KVGasConfigs holds the gas config metering every mounted store.
+	KVGasConfigs       map[string]storetypes.GasConfig `json:"kv_gas_configs"`
+	BlockGasLimit      uint64                          `json:"block_gas_limit"`
+	MsgGasLimit        uint64                          `json:"msg_gas_limit"`
+	MaxBlockTxs        int                             `json:"max_block_txs"`
+	TxEventIndexSize   int                             `json:"tx_event_index_size"`
+	RetainWindow       int64                           `json:"retain_window"`
+	ConcurrencyWorkers int                             `json:"concurrency_workers"`
+	FlushPolicy        FlushPolicy                     `json:"flush_policy"`
+	Upgrade            *UpgradeRules                   `json:"upgrade,omitempty"`
+	FlatFee            sdk.Coins                       `json:"flat_fee,omitempty"`
+}
+
+// handleQueryParams returns the JSON encoded QueryParamsResponse of the app.
+func (app *App) handleQueryParams(_ abci.RequestQuery) abci.ResponseQuery {
+	params := QueryParamsResponse{
+		KVGasConfigs:       make(map[string]storetypes.GasConfig),
+		BlockGasLimit:      app.blockGasLimit,
+		MsgGasLimit:        app.msgGasLimit,
+		MaxBlockTxs:        app.maxBlockTxs,
+		TxEventIndexSize:   app.txEventIndexSize,
+		RetainWindow:       app.retainWindow,
+		ConcurrencyWorkers: app.concurrencyWorkers,
+		FlushPolicy:        app.flushPolicy,
+		Upgrade:            app.upgradeRules,
+		FlatFee:            app.flatFee,
+	}
+	for _, key := range app.storeKeys() {
+		gasConfig, ok := app.kvGasConfigs[key.Name()]
+		if !ok {
+			gasConfig = storetypes.KVGasConfig()
+		}
+		params.KVGasConfigs[key.Name()] = gasConfig
+	}
+
+	bz, err := json.Marshal(params)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode params"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    app.LastBlockHeight(),
+		Value:     bz,
+	}
+}
+
+// splitPath splits a string path using the delimiter '/'.
+func splitPath(requestPath string) []string {
+	path := strings.Split(requestPath, "/")
+
+	// first element is empty string
+	if len(path) > 0 && path[0] == "" {
+		path = path[1:]
+	}
+
+	return path
+}
@@ -0,0 +1,552 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func queryRange(t *testing.T, app abci.Application, params QueryRangeParams) []KV {
+	t.Helper()
+
+	bz, err := json.Marshal(params)
+	require.NoError(t, err)
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/range", Data: bz})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+
+	var kvs []KV
+	require.NoError(t, json.Unmarshal(qres.Value, &kvs))
+	return kvs
+}
+
+func TestQueryRangeOrder(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%02d", i)
+	}
+	shuffled := append([]string{}, keys...)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	txs := make([][]byte, len(shuffled))
+	for i, k := range shuffled {
+		txs[i] = []byte(k + "=v")
+	}
+	finalizeAndCommit(t, app, 1, txs...)
+
+	// skip the reserved keys, which sort before the test keys
+	kvs := queryRange(t, app, QueryRangeParams{Start: []byte("key")})
+	require.Len(t, kvs, len(keys))
+	require.True(t, sort.SliceIsSorted(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key }))
+
+	kvs = queryRange(t, app, QueryRangeParams{Start: []byte("key"), Reverse: true})
+	require.Len(t, kvs, len(keys))
+	require.True(t, sort.SliceIsSorted(kvs, func(i, j int) bool { return kvs[i].Key > kvs[j].Key }))
+
+	// bounded domain, start inclusive and end exclusive
+	kvs = queryRange(t, app, QueryRangeParams{Start: []byte("key10"), End: []byte("key13"), Reverse: true})
+	require.Equal(t, []KV{{"key12", "v"}, {"key11", "v"}, {"key10", "v"}}, kvs)
+}
+
+func TestQueryGenesis(t *testing.T) {
+	app, err := NewApp(t.TempDir(), log.NewNopLogger())
+	require.NoError(t, err)
+	goCtx := context.Background()
+
+	// nothing is committed before the first block
+	qres, err := app.Query(goCtx, &abci.RequestQuery{Path: "/mock/genesis"})
+	require.NoError(t, err)
+	require.NotEqual(t, uint32(0), qres.Code)
+
+	appState := []byte(`{
+  "values": [{"key": "custom", "value": "genesis"}]
+}`)
+	_, err = app.InitChain(goCtx, &abci.RequestInitChain{AppStateBytes: appState})
+	require.NoError(t, err)
+	finalizeAndCommit(t, app, 1)
+
+	qres, err = app.Query(goCtx, &abci.RequestQuery{Path: "/mock/genesis"})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, appState, qres.Value)
+}
+
+func TestQueryKVDefault(t *testing.T) {
+	app := setupTestApp(t, []KV{{"key", "value"}})
+	finalizeAndCommit(t, app, 1)
+
+	queryKV := func(params QueryKVParams) *abci.ResponseQuery {
+		bz, err := json.Marshal(params)
+		require.NoError(t, err)
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/kv", Data: bz})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		return qres
+	}
+
+	// a stored value wins over the default
+	qres := queryKV(QueryKVParams{Key: []byte("key"), Default: []byte("fallback")})
+	require.Equal(t, []byte("value"), qres.Value)
+	require.Empty(t, qres.Info)
+
+	// a missing key yields the default, flagged as synthesized
+	qres = queryKV(QueryKVParams{Key: []byte("missing"), Default: []byte("fallback")})
+	require.Equal(t, []byte("fallback"), qres.Value)
+	require.Equal(t, QueryInfoDefault, qres.Info)
+
+	// without a default a missing key yields no value
+	qres = queryKV(QueryKVParams{Key: []byte("missing")})
+	require.Nil(t, qres.Value)
+	require.Empty(t, qres.Info)
+}
+
+func TestQuerySize(t *testing.T) {
+	app := setupTestApp(t, nil)
+	large := strings.Repeat("x", 1<<20)
+	finalizeAndCommit(t, app, 1, []byte("large="+large), []byte("small=abc"))
+
+	querySize := func(key string) *abci.ResponseQuery {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/size", Data: []byte(key)})
+		require.NoError(t, err)
+		return qres
+	}
+
+	qres := querySize("large")
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, int64(1), qres.Height)
+	var size int
+	require.NoError(t, json.Unmarshal(qres.Value, &size))
+	require.Equal(t, len(large), size)
+
+	qres = querySize("small")
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, []byte("3"), qres.Value)
+
+	qres = querySize("missing")
+	require.Equal(t, sdkerrors.ErrKeyNotFound.ABCICode(), qres.Code)
+
+	qres = querySize("")
+	require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), qres.Code)
+}
+
+func TestQueryKVProof(t *testing.T) {
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1, []byte("a=1"), []byte("c=3"))
+	// proofs are only served from the second height on
+	finalizeAndCommit(t, app, 2, []byte("d=4"))
+	appHash := app.LastCommitID().Hash
+	prt := rootmulti.DefaultProofRuntime()
+
+	queryKV := func(params QueryKVParams) *abci.ResponseQuery {
+		bz, err := json.Marshal(params)
+		require.NoError(t, err)
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/kv", Data: bz, Prove: true})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		require.NotNil(t, qres.ProofOps)
+		return qres
+	}
+
+	qres := queryKV(QueryKVParams{Key: []byte("a")})
+	require.Equal(t, []byte("1"), qres.Value)
+	require.NoError(t, prt.VerifyValue(qres.ProofOps, appHash, "/main/a", []byte("1")))
+
+	// an absent key between two present ones is proven absent
+	qres = queryKV(QueryKVParams{Key: []byte("b")})
+	require.Nil(t, qres.Value)
+	require.NoError(t, prt.VerifyAbsence(qres.ProofOps, appHash, "/main/b"))
+	require.Error(t, prt.VerifyAbsence(qres.ProofOps, appHash, "/main/a"))
+	require.Error(t, prt.VerifyAbsence(qres.ProofOps, []byte("wrong hash"), "/main/b"))
+
+	// so is one past every key, even when a default stands in for it
+	qres = queryKV(QueryKVParams{Key: []byte("z"), Default: []byte("fallback")})
+	require.Equal(t, QueryInfoDefault, qres.Info)
+	require.NoError(t, prt.VerifyAbsence(qres.ProofOps, appHash, "/main/z"))
+
+	// without the request for it there is no proof
+	bz, err := json.Marshal(QueryKVParams{Key: []byte("b")})
+	require.NoError(t, err)
+	res, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/kv", Data: bz})
+	require.NoError(t, err)
+	require.Nil(t, res.ProofOps)
+}
+
+func TestQueryKVEncoding(t *testing.T) {
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1, []byte{0x00, 0xff, '=', 0x01, 0x02})
+
+	queryKV := func(data string) *abci.ResponseQuery {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/kv", Data: []byte(data)})
+		require.NoError(t, err)
+		return qres
+	}
+
+	qres := queryKV(`{"key":"00ff","key_encoding":"hex","value_encoding":"base64"}`)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, []byte{0x00, 0xff}, qres.Key)
+	require.Equal(t, []byte("AQI="), qres.Value)
+
+	// the default is decoded and the value encoded alike
+	qres = queryKV(`{"key":"missing","key_encoding":"raw","default":"0a0b","value_encoding":"hex"}`)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, []byte("0a0b"), qres.Value)
+	require.Equal(t, QueryInfoDefault, qres.Info)
+
+	// without encodings, keys are base64 encoded like any JSON byte slice
+	qres = queryKV(`{"key":"AP8="}`)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	require.Equal(t, []byte{0x01, 0x02}, qres.Value)
+
+	// params round trip through their JSON encoding
+	params := QueryKVParams{Key: []byte{0x00, 0xff}, Default: []byte{0x03}, KeyEncoding: EncodingHex, ValueEncoding: EncodingRaw}
+	bz, err := json.Marshal(params)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key":"00ff","default":"\u0003","key_encoding":"hex","value_encoding":"raw"}`, string(bz))
+	var decoded QueryKVParams
+	require.NoError(t, json.Unmarshal(bz, &decoded))
+	require.Equal(t, params, decoded)
+
+	for _, data := range []string{
+		`{"key":"zz","key_encoding":"hex"}`,
+		`{"key":"00ff","key_encoding":"rot13"}`,
+		`{"key":"00ff","key_encoding":"hex","value_encoding":"rot13"}`,
+	} {
+		require.NotEqual(t, uint32(0), queryKV(data).Code, data)
+	}
+}
+
+func TestQueryHeights(t *testing.T) {
+	queryHeights := func(app abci.Application) []int64 {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/heights"})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+
+		var heights []int64
+		require.NoError(t, json.Unmarshal(qres.Value, &heights))
+		return heights
+	}
+
+	for name, tc := range map[string]struct {
+		opts     []Option
+		expected []int64
+	}{
+		"keep everything": {nil, []int64{1, 2, 3, 4, 5, 6}},
+		// keep the 2 most recent heights and every 3rd one, pruning every 2 heights
+		"pruned": {[]Option{SetPruning(storetypes.NewPruningOptions(2, 3, 2))}, []int64{3, 4, 5, 6}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, tc.opts...)
+			_, err := RunBlocks(app, make([][][]byte, 6))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, queryHeights(app))
+		})
+	}
+}
+
+func TestQueryVersion(t *testing.T) {
+	queryVersion := func(app abci.Application) int64 {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/version"})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+
+		var version int64
+		require.NoError(t, json.Unmarshal(qres.Value, &version))
+		require.Equal(t, qres.Height, version)
+		return version
+	}
+
+	app := setupTestApp(t, nil)
+	require.Zero(t, queryVersion(app))
+	for height := int64(1); height <= 3; height++ {
+		finalizeAndCommit(t, app, height, []byte("a=1"))
+		require.Equal(t, height, queryVersion(app))
+	}
+}
+
+func queryPrefix(t *testing.T, app abci.Application, params QueryPrefixParams) QueryPrefixResponse {
+	t.Helper()
+
+	bz, err := json.Marshal(params)
+	require.NoError(t, err)
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/prefix", Data: bz})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+
+	var res QueryPrefixResponse
+	require.NoError(t, json.Unmarshal(qres.Value, &res))
+	return res
+}
+
+func TestQueryPrefixPagination(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	var txs [][]byte
+	for i := 0; i < 25; i++ {
+		txs = append(txs, []byte(fmt.Sprintf("item/%02d=%d", i, i)))
+	}
+	txs = append(txs, []byte("items=x"), []byte("other=y"))
+	finalizeAndCommit(t, app, 1, txs...)
+
+	// page through with the cursor
+	var pairs []KV
+	params := QueryPrefixParams{Prefix: []byte("item/"), Limit: 10}
+	pages := 0
+	for {
+		res := queryPrefix(t, app, params)
+		require.LessOrEqual(t, len(res.Pairs), 10)
+		pairs = append(pairs, res.Pairs...)
+		pages++
+		if res.NextKey == nil {
+			break
+		}
+		params.Key = res.NextKey
+	}
+	require.Equal(t, 3, pages)
+	require.Len(t, pairs, 25)
+	for i, pair := range pairs {
+		require.Equal(t, KV{Key: fmt.Sprintf("item/%02d", i), Value: fmt.Sprint(i)}, pair)
+	}
+
+	// or by offset
+	res := queryPrefix(t, app, QueryPrefixParams{Prefix: []byte("item/"), Offset: 20, Limit: 10})
+	require.Equal(t, pairs[20:], res.Pairs)
+	require.Nil(t, res.NextKey)
+
+	// the limit defaults to query.DefaultLimit
+	res = queryPrefix(t, app, QueryPrefixParams{Prefix: []byte("item/")})
+	require.Equal(t, pairs, res.Pairs)
+
+	// an unmatched prefix yields an empty page
+	res = queryPrefix(t, app, QueryPrefixParams{Prefix: []byte("none/")})
+	require.Empty(t, res.Pairs)
+	require.Nil(t, res.NextKey)
+}
+
+func TestQueryPrefixInvalid(t *testing.T) {
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1)
+
+	bz, err := json.Marshal(QueryPrefixParams{Key: []byte("a"), Offset: 1})
+	require.NoError(t, err)
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/prefix", Data: bz})
+	require.NoError(t, err)
+	require.NotEqual(t, uint32(0), qres.Code, "key and offset are mutually exclusive")
+}
+
+func queryHistory(t *testing.T, app abci.Application, params QueryHistoryParams) *abci.ResponseQuery {
+	t.Helper()
+
+	bz, err := json.Marshal(params)
+	require.NoError(t, err)
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/history", Data: bz})
+	require.NoError(t, err)
+	return qres
+}
+
+func TestQueryHistory(t *testing.T) {
+	app := setupTestApp(t, nil)
+	blocks := [][][]byte{
+		{[]byte("other=1")},
+		{[]byte("k=1")},
+		{[]byte("other=2")},
+		{[]byte("k=2")},
+		{[]byte("k=2")},
+		{NewSwapTx("k", "absent")},
+		{[]byte("k=3")},
+	}
+	_, err := RunBlocks(app, blocks)
+	require.NoError(t, err)
+
+	history := func(params QueryHistoryParams) []HistoryEntry {
+		qres := queryHistory(t, app, params)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		var entries []HistoryEntry
+		require.NoError(t, json.Unmarshal(qres.Value, &entries))
+		return entries
+	}
+
+	require.Equal(t, []HistoryEntry{
+		{Height: 2, Value: []byte("1")},
+		{Height: 4, Value: []byte("2")},
+		{Height: 6, Value: nil},
+		{Height: 7, Value: []byte("3")},
+	}, history(QueryHistoryParams{Key: []byte("k")}))
+
+	// a range starting with the key present opens with its value
+	require.Equal(t, []HistoryEntry{
+		{Height: 3, Value: []byte("1")},
+		{Height: 4, Value: []byte("2")},
+	}, history(QueryHistoryParams{Key: []byte("k"), From: 3, To: 5}))
+
+	require.Empty(t, history(QueryHistoryParams{Key: []byte("never")}))
+
+	for _, params := range []QueryHistoryParams{
+		{},
+		{Key: []byte("k"), From: 5, To: 4},
+		{Key: []byte("k"), To: 8},
+	} {
+		require.NotEqual(t, uint32(0), queryHistory(t, app, params).Code, "%+v", params)
+	}
+}
+
+func TestQueryEvents(t *testing.T) {
+	app := setupTestApp(t, []KV{{Key: "b", Value: "1"}})
+	finalizeAndCommit(t, app, 1, []byte("a=1"), NewReadTx("a", "b"), []byte("c=2"))
+
+	queryEvents := func(eventType string) []BlockEvent {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/events?type=" + eventType})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		require.Equal(t, app.LastBlockHeight(), qres.Height)
+		var events []BlockEvent
+		require.NoError(t, json.Unmarshal(qres.Value, &events))
+		return events
+	}
+
+	kvEvents := queryEvents(EventTypeKVStore)
+	require.Len(t, kvEvents, 2)
+	for i, expected := range []struct {
+		txIndex int
+		key     string
+	}{{0, "a"}, {2, "c"}} {
+		require.Equal(t, expected.txIndex, kvEvents[i].TxIndex)
+		require.Equal(t, EventTypeKVStore, kvEvents[i].Event.Type)
+		require.Equal(t, expected.key, string(kvEvents[i].Event.Attributes[0].Value))
+	}
+
+	readEvents := queryEvents(EventTypeRead)
+	require.Len(t, readEvents, 2)
+	for _, event := range readEvents {
+		require.Equal(t, 1, event.TxIndex)
+		require.Equal(t, EventTypeRead, event.Event.Type)
+	}
+
+	require.Empty(t, queryEvents("unknown"))
+
+	// only the events of the last committed block are returned
+	finalizeAndCommit(t, app, 2, NewReadTx("a"))
+	require.Empty(t, queryEvents(EventTypeKVStore))
+	require.Len(t, queryEvents(EventTypeRead), 1)
+
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/events"})
+	require.NoError(t, err)
+	require.NotEqual(t, uint32(0), qres.Code)
+}
+
+func TestQueryEventsConcurrentCommit(t *testing.T) {
+	app := setupTestApp(t, nil)
+
+	// queries run alongside the commits replacing the events they read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for height := int64(1); height <= 20; height++ {
+			finalizeAndCommit(t, app, height, []byte(fmt.Sprintf("k%d=v", height)))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/events?type=" + EventTypeKVStore})
+			require.NoError(t, err)
+			require.Equal(t, int64(20), qres.Height)
+			var events []BlockEvent
+			require.NoError(t, json.Unmarshal(qres.Value, &events))
+			require.Len(t, events, 1)
+			require.Equal(t, "k20", string(events[0].Event.Attributes[0].Value))
+			return
+		default:
+		}
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/events?type=" + EventTypeKVStore})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+	}
+}
+
+func TestQueryParams(t *testing.T) {
+	queryParams := func(app *App) QueryParamsResponse {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/params"})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		var params QueryParamsResponse
+		require.NoError(t, json.Unmarshal(qres.Value, &params))
+		return params
+	}
+
+	params := queryParams(setupTestApp(t, nil))
+	require.Equal(t, map[string]storetypes.GasConfig{"main": storetypes.KVGasConfig()}, params.KVGasConfigs)
+	require.Zero(t, params.BlockGasLimit)
+	require.Equal(t, DefaultTxEventIndexSize, params.TxEventIndexSize)
+	require.Nil(t, params.Upgrade)
+
+	gasConfig := storetypes.KVGasConfig()
+	gasConfig.WriteCostPerByte = 100
+	upgrade := UpgradeRules{Height: 5, MaxValueSize: 16}
+	params = queryParams(setupTestApp(t, nil,
+		SetExtraStores("other"),
+		SetKVGasConfig("other", gasConfig),
+		SetBlockGasLimit(1000),
+		SetMsgGasLimit(100),
+		SetTxEventIndexSize(10),
+		SetConcurrentExecution(4),
+		SetFlushPolicy(FlushPerTx),
+		SetUpgradeRules(upgrade),
+	))
+	require.Equal(t, QueryParamsResponse{
+		KVGasConfigs:       map[string]storetypes.GasConfig{"main": storetypes.KVGasConfig(), "other": gasConfig},
+		BlockGasLimit:      1000,
+		MsgGasLimit:        100,
+		TxEventIndexSize:   10,
+		ConcurrencyWorkers: 4,
+		FlushPolicy:        FlushPerTx,
+		Upgrade:            &upgrade,
+	}, params)
+}
+
+func TestQueryInitialized(t *testing.T) {
+	dir := t.TempDir()
+	app, err := NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+
+	initialized := func(app abci.Application) bool {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/initialized"})
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), qres.Code, qres.Log)
+		var initialized bool
+		require.NoError(t, json.Unmarshal(qres.Value, &initialized))
+		require.Equal(t, app.(*App).Initialized(), initialized)
+		return initialized
+	}
+
+	require.False(t, initialized(app))
+	_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: []byte(`{"values":[]}`)})
+	require.NoError(t, err)
+	require.True(t, initialized(app))
+
+	_, err = RunBlocks(app, [][][]byte{{[]byte("a=1")}})
+	require.NoError(t, err)
+	require.True(t, initialized(app))
+
+	// the marker is part of the committed state
+	require.NoError(t, app.(*App).Close())
+	app, err = NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	defer app.(*App).Close()
+	require.True(t, initialized(app))
+}
@@ -0,0 +1,35 @@
+package mock
+
+// txRateLimiter is a token bucket throttling tx execution. It starts empty
+// and is refilled at the start of every block rather than per unit of wall
+// clock time, so the txs it rejects only depend on the sequence of blocks
+// executed.
+type txRateLimiter struct {
+	capacity  int
+	perBlock  int
+	available int
+}
+
+func newTxRateLimiter(capacity, perBlock int) *txRateLimiter {
+	return &txRateLimiter{
+		capacity: capacity,
+		perBlock: perBlock,
+	}
+}
+
+// refill adds the tokens granted for a new block, up to the capacity.
+func (l *txRateLimiter) refill() {
+	l.available += l.perBlock
+	if l.available > l.capacity {
+		l.available = l.capacity
+	}
+}
+
+// take consumes a token, it returns false if the bucket is empty.
+func (l *txRateLimiter) take() bool {
+	if l.available == 0 {
+		return false
+	}
+	l.available--
+	return true
+}
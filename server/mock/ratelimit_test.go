@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxRateLimit(t *testing.T) {
+	app := setupTestApp(t, nil, SetTxRateLimit(3, 2))
+	txs := [][]byte{[]byte("a=1"), []byte("b=2"), []byte("c=3")}
+
+	// blocks of 3 txs against 2 tokens per block, unused tokens carry over
+	// up to the capacity
+	for i, expected := range [][]bool{
+		{true, true, false},
+		{true, true, false},
+	} {
+		height := int64(i + 1)
+		resp := finalizeAndCommit(t, app, height, txs...)
+		for j, ok := range expected {
+			res := resp.TxResults[j]
+			if ok {
+				require.Equal(t, uint32(0), res.Code, "height %d tx %d: %s", height, j, res.Log)
+			} else {
+				require.Equal(t, ErrRateLimited.ABCICode(), res.Code, "height %d tx %d", height, j)
+				require.Equal(t, Codespace, res.Codespace)
+			}
+		}
+	}
+
+	finalizeAndCommit(t, app, 3)
+	finalizeAndCommit(t, app, 4)
+	resp := finalizeAndCommit(t, app, 5, append(txs, []byte("d=4"))...)
+	codes := make([]uint32, len(resp.TxResults))
+	for i, res := range resp.TxResults {
+		codes[i] = res.Code
+	}
+	require.Equal(t, []uint32{0, 0, 0, ErrRateLimited.ABCICode()}, codes)
+}
+
+func TestTxDelay(t *testing.T) {
+	const delay = 10 * time.Millisecond
+	app := setupTestApp(t, nil, SetTxDelay(delay))
+
+	start := time.Now()
+	finalizeAndCommit(t, app, 1, []byte("a=1"), []byte("b=2"))
+	require.GreaterOrEqual(t, time.Since(start), 2*delay)
+}
@@ -0,0 +1,110 @@
+package mock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// ReadTxPrefix starts the bytes of a kvReadTx, i.e. "read:a,b".
+const ReadTxPrefix = "read:"
+
+// kvReadTx reads keys of the main store without writing anything, recording
+// every key read, the value found and whether one was found as a read event.
+// It is an sdk.Tx which is its own sdk.Msg.
+type kvReadTx struct {
+	keys  [][]byte
+	bytes []byte
+}
+
+// dummy implementation of proto.Message
+func (msg kvReadTx) Reset()         {}
+func (msg kvReadTx) String() string { return "TODO" }
+func (msg kvReadTx) ProtoMessage()  {}
+
+var _ sdk.Tx = kvReadTx{}
+var _ sdk.Msg = kvReadTx{}
+
+// NewReadTx returns the bytes of a tx reading keys.
+func NewReadTx(keys ...string) []byte {
+	return []byte(ReadTxPrefix + strings.Join(keys, ","))
+}
+
+func (tx kvReadTx) Route() string {
+	return "read"
+}
+
+func (tx kvReadTx) Type() string {
+	return "read_tx"
+}
+
+func (tx kvReadTx) GetMsgs() []sdk.Msg {
+	return []sdk.Msg{tx}
+}
+
+func (tx kvReadTx) GetMemo() string {
+	return ""
+}
+
+func (tx kvReadTx) GetSignBytes() []byte {
+	return tx.bytes
+}
+
+func (tx kvReadTx) ValidateBasic() error {
+	return nil
+}
+
+func (tx kvReadTx) GetSigners() []sdk.AccAddress {
+	return nil
+}
+
+func (tx kvReadTx) GetGasEstimate() uint64 {
+	return 0
+}
+
+// decodeReadTx decodes the bytes of a kvReadTx, ReadTxPrefix included.
+func decodeReadTx(txBytes []byte) (sdk.Tx, error) {
+	tx := kvReadTx{bytes: txBytes}
+	for _, key := range strings.Split(string(txBytes[len(ReadTxPrefix):]), ",") {
+		if len(key) == 0 {
+			return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "empty key")
+		}
+		tx.keys = append(tx.keys, []byte(key))
+	}
+	return tx, nil
+}
+
+// readHandler reads the keys of a kvReadTx in order, emitting a read event
+// with the value found for each, empty if the key is absent. With strict
+// reads an absent key fails the tx instead.
+func (app *App) readHandler(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+	rTx, ok := msg.(kvReadTx)
+	if !ok {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrUnknownRequest, "unexpected read msg %T", msg)
+	}
+
+	ctx = ctx.WithEventManager(sdk.NewEventManager())
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	for _, key := range rTx.keys {
+		value, err := app.get(store, key)
+		if err != nil {
+			return nil, err
+		}
+		ctx.EventManager().EmitEvent(
+			sdk.NewEvent(
+				EventTypeRead,
+				sdk.NewAttribute(AttributeKeyKey, string(key)),
+				sdk.NewAttribute(AttributeKeyValue, string(value)),
+				sdk.NewAttribute(AttributeKeyFound, strconv.FormatBool(value != nil)),
+			),
+		)
+	}
+
+	return &sdk.Result{
+		Log:    fmt.Sprintf("read %d keys", len(rTx.keys)),
+		Events: ctx.EventManager().ABCIEvents(),
+	}, nil
+}
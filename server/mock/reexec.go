@@ -0,0 +1,23 @@
+package mock
+
+// ReexecutionInfo is the re-execution accounting of the last finalized
+// block. A tx executed n times by the OCC scheduler counts n-1
+// re-executions; a block executed sequentially has none.
+type ReexecutionInfo struct {
+	// Total is the number of re-executions of every tx of the block.
+	Total int
+	// MaxPerTx is the highest number of re-executions of a single tx.
+	MaxPerTx int
+	// Budget is the re-execution budget of every tx, negative if unlimited.
+	Budget int
+	// Exceeded is set when a tx ran out of budget, in which case the
+	// concurrent execution was discarded and the block executed again
+	// sequentially.
+	Exceeded bool
+}
+
+// LastReexecutions returns the re-execution accounting of the last
+// finalized block.
+func (app *App) LastReexecutions() ReexecutionInfo {
+	return app.lastReexecutions
+}
@@ -0,0 +1,186 @@
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// blockStreamFormat is the version of the block stream file layout.
+const blockStreamFormat = 1
+
+// blockStreamHeader is the first line of a block stream file, JSON encoded.
+// One recordedBlock line per block follows it, in height order.
+type blockStreamHeader struct {
+	Format   uint32          `json:"format"`
+	AppState json.RawMessage `json:"app_state"`
+	Blocks   int             `json:"blocks"`
+	Height   int64           `json:"height"`
+	Hash     []byte          `json:"hash"`
+}
+
+// recordedBlock is a block of a block stream file along with the app hash
+// committing it produced when it was recorded.
+type recordedBlock struct {
+	Height  int64     `json:"height"`
+	Time    time.Time `json:"time"`
+	Txs     [][]byte  `json:"txs"`
+	AppHash []byte    `json:"app_hash"`
+}
+
+// RecordBlocks initializes a fresh app with genesis, runs blocks on it as
+// RunBlocks does and writes them to a single file at path, which
+// ReplayFromFile replays. The header of the file records the genesis and
+// the app hash of the last block, so the file is a self-contained regression
+// fixture. The app is built with the default options, as the replay is.
+func RecordBlocks(path string, genesis GenesisJSON, blocks [][][]byte, opts ...RunBlocksOption) error {
+	var cfg runBlocksConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	appState, err := json.Marshal(genesis)
+	if err != nil {
+		return err
+	}
+
+	app, err := newReplayApp(appState, log.NewNopLogger())
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	goCtx := context.Background()
+	records := make([]recordedBlock, 0, len(blocks))
+	for i, txs := range blocks {
+		height := int64(i) + 1
+		blockTime := cfg.genesisTime.Add(time.Duration(height) * cfg.blockTimeDelta)
+		if _, err := app.FinalizeBlock(goCtx, NewFinalizeRequest(height, txs, WithBlockTime(blockTime))); err != nil {
+			return fmt.Errorf("failed to finalize block %d: %w", height, err)
+		}
+		appHash, _, err := CommitBlock(app)
+		if err != nil {
+			return fmt.Errorf("failed to commit block %d: %w", height, err)
+		}
+		records = append(records, recordedBlock{Height: height, Time: blockTime, Txs: txs, AppHash: appHash})
+	}
+
+	header := blockStreamHeader{
+		Format:   blockStreamFormat,
+		AppState: appState,
+		Blocks:   len(records),
+		Height:   app.LastBlockHeight(),
+		Hash:     app.LastCommitID().Hash,
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create block stream file %q: %w", path, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("failed to encode block stream header: %w", err)
+	}
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode block %d: %w", record.Height, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write block stream file %q: %w", path, err)
+	}
+	return f.Close()
+}
+
+// ReplayFromFile returns a fresh app rebuilt by replaying the block stream
+// recorded to path by RecordBlocks: the chain is initialized with the
+// recorded genesis and every block is finalized and committed with its
+// recorded height, time and txs. It returns an error naming the first block
+// whose app hash differs from the recording, or if the final app hash
+// doesn't match the one in the header. The app lives in a temporary
+// directory removed when it is closed.
+func ReplayFromFile(path string, logger log.Logger) (abci.Application, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open block stream file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var header blockStreamHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("failed to decode block stream header: %w", err)
+	}
+	if header.Format != blockStreamFormat {
+		return nil, fmt.Errorf("unsupported block stream format %d", header.Format)
+	}
+
+	app, err := newReplayApp(header.AppState, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := replayBlocks(app, dec, header); err != nil {
+		app.Close()
+		return nil, err
+	}
+	return app, nil
+}
+
+func replayBlocks(app *App, dec *json.Decoder, header blockStreamHeader) error {
+	goCtx := context.Background()
+	for i := 0; i < header.Blocks; i++ {
+		var record recordedBlock
+		if err := dec.Decode(&record); err != nil {
+			return fmt.Errorf("failed to decode block %d of %d: %w", i+1, header.Blocks, err)
+		}
+		if _, err := app.FinalizeBlock(goCtx, NewFinalizeRequest(record.Height, record.Txs, WithBlockTime(record.Time))); err != nil {
+			return fmt.Errorf("failed to finalize block %d: %w", record.Height, err)
+		}
+		appHash, _, err := CommitBlock(app)
+		if err != nil {
+			return fmt.Errorf("failed to commit block %d: %w", record.Height, err)
+		}
+		if !bytes.Equal(appHash, record.AppHash) {
+			return fmt.Errorf("block %d: app hash %X != recorded %X", record.Height, appHash, record.AppHash)
+		}
+	}
+
+	if height := app.LastBlockHeight(); height != header.Height {
+		return fmt.Errorf("replayed height %d != recorded %d", height, header.Height)
+	}
+	if hash := app.LastCommitID().Hash; !bytes.Equal(hash, header.Hash) {
+		return fmt.Errorf("replayed app hash %X != recorded %X", hash, header.Hash)
+	}
+	return nil
+}
+
+// newReplayApp returns an app in a temporary directory, removed when it is
+// closed, with its chain initialized from appState.
+func newReplayApp(appState []byte, logger log.Logger) (*App, error) {
+	dir, err := ioutil.TempDir("", "mock-replay")
+	if err != nil {
+		return nil, err
+	}
+	app, err := NewApp(dir, logger)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	mockApp := app.(*App)
+	mockApp.tempDir = dir
+
+	if _, err := app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: appState}); err != nil {
+		mockApp.Close()
+		return nil, fmt.Errorf("failed to init chain: %w", err)
+	}
+	return mockApp, nil
+}
@@ -0,0 +1,54 @@
+package mock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestReplayFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.jsonl")
+	genesis := GenesisJSON{Values: []KV{{Key: "a", Value: "0"}}}
+	blocks := [][][]byte{
+		{[]byte("a=1"), []byte("b=2"), []byte(TimeKey)},
+		{NewSwapTx("a", "b"), NewDeleteTx("a")},
+		{[]byte("c=3")},
+	}
+	opts := []RunBlocksOption{WithGenesisTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)), WithBlockTimeDelta(time.Second)}
+	require.NoError(t, RecordBlocks(path, genesis, blocks, opts...))
+
+	replayed, err := ReplayFromFile(path, log.NewNopLogger())
+	require.NoError(t, err)
+	defer replayed.(*App).Close()
+
+	// the replay reaches the state of an app running the same blocks directly
+	app := setupTestApp(t, genesis.Values)
+	_, err = RunBlocks(app, blocks, opts...)
+	require.NoError(t, err)
+	require.Equal(t, app.LastBlockHeight(), replayed.(*App).LastBlockHeight())
+	require.Equal(t, app.LastCommitID().Hash, replayed.(*App).LastCommitID().Hash)
+	require.NoError(t, AssertStateEqual(app, replayed))
+
+	// a tampered block is caught at its height
+	bz, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(string(bz), "\n")
+	var record recordedBlock
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &record))
+	record.Txs[0] = []byte("a=2")
+	line, err := json.Marshal(record)
+	require.NoError(t, err)
+	lines[2] = string(line)
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+	_, err = ReplayFromFile(path, log.NewNopLogger())
+	require.ErrorContains(t, err, "block 2: app hash")
+
+	_, err = ReplayFromFile(filepath.Join(t.TempDir(), "missing"), log.NewNopLogger())
+	require.Error(t, err)
+}
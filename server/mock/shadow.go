@@ -0,0 +1,60 @@
+package mock
+
+import (
+	"fmt"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ShadowStoreName is the name of the store mirroring the writes to the main
+// store when enabled with SetShadowStore.
+const ShadowStoreName = "shadow"
+
+// shadowKVStore writes every change to the wrapped store to shadow as well,
+// reads being served by the wrapped store alone.
+type shadowKVStore struct {
+	sdk.KVStore
+
+	shadow sdk.KVStore
+}
+
+func (s *shadowKVStore) Set(key, value []byte) {
+	s.KVStore.Set(key, value)
+	s.shadow.Set(key, value)
+}
+
+func (s *shadowKVStore) Delete(key []byte) {
+	s.KVStore.Delete(key)
+	s.shadow.Delete(key)
+}
+
+// withShadow wraps store, a view of the main store of ctx, so its writes are
+// mirrored to the shadow store if it is mounted.
+func (app *App) withShadow(ctx sdk.Context, store sdk.KVStore) sdk.KVStore {
+	if app.shadowStoreKey == nil {
+		return store
+	}
+	return &shadowKVStore{KVStore: store, shadow: ctx.MultiStore().GetKVStore(app.shadowStoreKey)}
+}
+
+// AssertShadowEqual compares the committed main store of a mock app with the
+// shadow store mirroring it and returns an error describing the first key
+// whose value differs, or that is only present in one of them.
+func AssertShadowEqual(app abci.Application) error {
+	mockApp, ok := app.(*App)
+	if !ok {
+		return fmt.Errorf("unexpected app type %T", app)
+	}
+	if mockApp.shadowStoreKey == nil {
+		return fmt.Errorf("no %s store mounted", ShadowStoreName)
+	}
+
+	main := mockApp.CommitMultiStore().GetKVStore(mockApp.capKeyMainStore)
+	shadow := mockApp.CommitMultiStore().GetKVStore(mockApp.shadowStoreKey)
+	if err := assertKVStoreEqual(main, shadow); err != nil {
+		return fmt.Errorf("store %s: %w", ShadowStoreName, err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowStore(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"sequential": {SetShadowStore(true)},
+		"concurrent": {SetShadowStore(true), SetConcurrentExecution(4)},
+		"merge":      {SetShadowStore(true), SetConflictMerge(sumMerge)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, []KV{{"g", "genesis"}}, opts...)
+			_, err := RunBlocks(app, [][][]byte{
+				GenWorkload(WorkloadSpec{Txs: 100, Keys: 20, SetWeight: 2, DeleteWeight: 1, IncrementWeight: 1, ZipfS: 1.2, Seed: 1}),
+				{NewSwapTx("key0", "key1"), []byte(TimeKey), []byte("a=1")},
+			})
+			require.NoError(t, err)
+			require.NoError(t, AssertShadowEqual(app))
+			require.Equal(t, []byte("genesis"), queryStore(t, app, ShadowStoreName, "g", 2).Value)
+		})
+	}
+
+	// a write bypassing the main store is caught
+	app := setupTestApp(t, nil, SetShadowStore(true))
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+	app.CommitMultiStore().GetKVStore(app.shadowStoreKey).Set([]byte("a"), []byte("2"))
+	require.EqualError(t, AssertShadowEqual(app), `store shadow: key "a": value "1" != "2"`)
+
+	require.Error(t, AssertShadowEqual(setupTestApp(t, nil)))
+}
@@ -0,0 +1,48 @@
+package mock
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestSimpleStores(t *testing.T) {
+	dir := t.TempDir()
+	app, err := NewApp(dir, log.NewNopLogger(), SetSimpleStores("main"))
+	require.NoError(t, err)
+	mockApp := app.(*App)
+	require.Equal(t, storetypes.StoreTypeDB, mockApp.CommitMultiStore().GetCommitKVStore(mockApp.capKeyMainStore).GetStoreType())
+
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+	hash := mockApp.LastCommitID().Hash
+	finalizeAndCommit(t, app, 2, []byte("a=2"), []byte("b=3"))
+	require.Equal(t, []KV{{"a", "2"}, {"b", "3"}}, queryRange(t, app, QueryRangeParams{Start: []byte("a"), End: []byte("c")}))
+	require.Equal(t, hash, mockApp.LastCommitID().Hash, "simple stores don't contribute to the app hash")
+
+	// the content survives a restart
+	require.NoError(t, mockApp.Close())
+	app, err = NewApp(dir, log.NewNopLogger(), SetSimpleStores("main"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), app.(*App).LastBlockHeight())
+	require.Equal(t, []KV{{"a", "2"}, {"b", "3"}}, queryRange(t, app, QueryRangeParams{Start: []byte("a"), End: []byte("c")}))
+}
+
+func BenchmarkCommit(b *testing.B) {
+	txs := make([][]byte, 100)
+	for i := range txs {
+		txs[i] = []byte(fmt.Sprintf("key%03d=value", i))
+	}
+
+	for name, opts := range map[string][]Option{
+		"iavl":   nil,
+		"simple": {SetSimpleStores("main")},
+	} {
+		b.Run(name, func(b *testing.B) {
+			benchmarkBlocks(b, txs, opts...)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package mock
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gogo/protobuf/proto"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/snapshots"
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+)
+
+// SnapshotMetadataFile is the file of a snapshot directory holding the
+// protobuf encoded snapshottypes.Snapshot describing its chunks. The chunks
+// themselves are stored alongside it, in files named after their index.
+const SnapshotMetadataFile = "metadata"
+
+// WriteSnapshot exports the state of the app at height into dir, chunked and
+// encoded the same way the snapshot manager serves it over state sync.
+func WriteSnapshot(app abci.Application, dir string, height uint64) error {
+	snapshotter, err := appSnapshotter(app)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory %q: %w", dir, err)
+	}
+
+	snapshot := &snapshottypes.Snapshot{
+		Height: height,
+		Format: snapshottypes.CurrentFormat,
+	}
+	snapshotHasher := sha256.New()
+	err = streamSnapshot(snapshotter, height, func(body []byte) error {
+		path := filepath.Join(dir, strconv.FormatUint(uint64(snapshot.Chunks), 10))
+		if err := ioutil.WriteFile(path, body, 0644); err != nil {
+			return fmt.Errorf("failed to write snapshot chunk %q: %w", path, err)
+		}
+		chunkHash := sha256.Sum256(body)
+		snapshot.Metadata.ChunkHashes = append(snapshot.Metadata.ChunkHashes, chunkHash[:])
+		snapshotHasher.Write(body)
+		snapshot.Chunks++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	snapshot.Hash = snapshotHasher.Sum(nil)
+
+	bz, err := proto.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot metadata: %w", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, SnapshotMetadataFile), bz, 0644)
+}
+
+// ReadSnapshot restores the snapshot written to dir by WriteSnapshot into
+// app, which must not have committed any state yet. Every chunk is checked
+// against the hash recorded in the metadata before it is applied.
+func ReadSnapshot(app abci.Application, dir string) error {
+	mockApp, ok := app.(*App)
+	if !ok {
+		return fmt.Errorf("unexpected app type %T", app)
+	}
+	snapshotter, err := appSnapshotter(app)
+	if err != nil {
+		return err
+	}
+
+	bz, err := ioutil.ReadFile(filepath.Join(dir, SnapshotMetadataFile))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot metadata: %w", err)
+	}
+	var snapshot snapshottypes.Snapshot
+	if err := proto.Unmarshal(bz, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode snapshot metadata: %w", err)
+	}
+	if snapshot.Format != snapshottypes.CurrentFormat {
+		return fmt.Errorf("unsupported snapshot format %d", snapshot.Format)
+	}
+	if len(snapshot.Metadata.ChunkHashes) != int(snapshot.Chunks) {
+		return fmt.Errorf("snapshot has %d chunks but %d chunk hashes", snapshot.Chunks, len(snapshot.Metadata.ChunkHashes))
+	}
+
+	bodies := make([][]byte, snapshot.Chunks)
+	for i := range bodies {
+		body, err := ioutil.ReadFile(filepath.Join(dir, strconv.Itoa(i)))
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot chunk %d: %w", i, err)
+		}
+		if chunkHash := sha256.Sum256(body); !bytes.Equal(chunkHash[:], snapshot.Metadata.ChunkHashes[i]) {
+			return fmt.Errorf("snapshot chunk %d hash %X != %X", i, chunkHash, snapshot.Metadata.ChunkHashes[i])
+		}
+		bodies[i] = body
+	}
+
+	if err := restoreSnapshot(snapshotter, snapshot.Height, snapshot.Format, bodies); err != nil {
+		return fmt.Errorf("failed to restore snapshot at height %d: %w", snapshot.Height, err)
+	}
+	mockApp.setCommittedEvents(nil)
+	return mockApp.loadAppVersion()
+}
+
+// streamSnapshot generates the snapshot of the multistore at height and
+// hands each chunk to fn, in order. The first error of either the snapshot
+// or fn stops the stream and is returned.
+func streamSnapshot(snapshotter snapshottypes.Snapshotter, height uint64, fn func(body []byte) error) error {
+	chunks := make(chan io.ReadCloser)
+	go func() {
+		streamWriter := snapshots.NewStreamWriter(chunks)
+		if streamWriter == nil {
+			return
+		}
+		defer streamWriter.Close()
+		if err := snapshotter.Snapshot(height, streamWriter); err != nil {
+			streamWriter.CloseWithError(err)
+		}
+	}()
+
+	var index int
+	for chunk := range chunks {
+		body, err := ioutil.ReadAll(chunk)
+		chunk.Close()
+		if err != nil {
+			snapshots.DrainChunks(chunks)
+			return fmt.Errorf("failed to generate snapshot chunk %d: %w", index, err)
+		}
+		if err := fn(body); err != nil {
+			snapshots.DrainChunks(chunks)
+			return err
+		}
+		index++
+	}
+	return nil
+}
+
+// restoreSnapshot restores the snapshot made of the given chunk bodies into
+// the multistore.
+func restoreSnapshot(snapshotter snapshottypes.Snapshotter, height uint64, format uint32, bodies [][]byte) error {
+	chunks := make(chan io.ReadCloser, len(bodies))
+	for _, body := range bodies {
+		chunks <- ioutil.NopCloser(bytes.NewReader(body))
+	}
+	close(chunks)
+
+	streamReader, err := snapshots.NewStreamReader(chunks)
+	if err != nil {
+		return err
+	}
+	defer streamReader.Close()
+	_, err = snapshotter.Restore(height, format, streamReader)
+	return err
+}
+
+// appSnapshotter returns the multistore of app as a snapshotter.
+func appSnapshotter(app abci.Application) (snapshottypes.Snapshotter, error) {
+	mockApp, ok := app.(*App)
+	if !ok {
+		return nil, fmt.Errorf("unexpected app type %T", app)
+	}
+	snapshotter, ok := mockApp.CommitMultiStore().(snapshottypes.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("multistore %T does not support snapshots", mockApp.CommitMultiStore())
+	}
+	return snapshotter, nil
+}
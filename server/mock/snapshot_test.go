@@ -0,0 +1,100 @@
+package mock
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestSnapshotFiles(t *testing.T) {
+	app := setupTestApp(t, []KV{{Key: "genesis", Value: "value"}}, SetExtraStores("extra"))
+	finalizeAndCommit(t, app, 1, []byte("a=1"), []byte("b=2"))
+	finalizeAndCommit(t, app, 2, []byte("a=3"), []byte(UpgradeKey+"=2"))
+
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, WriteSnapshot(app, dir, 2))
+
+	restored, err := NewApp(t.TempDir(), log.NewNopLogger(), SetExtraStores("extra"))
+	require.NoError(t, err)
+	require.NoError(t, ReadSnapshot(restored, dir))
+
+	require.NoError(t, AssertStateEqual(app, restored))
+	require.Equal(t, app.LastCommitID(), restored.(*App).LastCommitID())
+	require.Equal(t, uint64(2), restored.(*App).AppVersion())
+
+	// a second export of the same height is byte for byte the same
+	again := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, WriteSnapshot(restored, again, 2))
+	for _, name := range []string{SnapshotMetadataFile, "0"} {
+		require.Equal(t, readFile(t, filepath.Join(dir, name)), readFile(t, filepath.Join(again, name)))
+	}
+}
+
+func TestSnapshotFilesCorrupted(t *testing.T) {
+	app := setupTestApp(t, nil)
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+
+	dir := t.TempDir()
+	require.NoError(t, WriteSnapshot(app, dir, 1))
+	chunk := filepath.Join(dir, "0")
+	bz := readFile(t, chunk)
+	bz[len(bz)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(chunk, bz, 0644))
+
+	restored, err := NewApp(t.TempDir(), log.NewNopLogger())
+	require.NoError(t, err)
+	require.Error(t, ReadSnapshot(restored, dir))
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	bz, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return bz
+}
+
+func TestSnapshotChunksDeterministic(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a multi chunk snapshot")
+	}
+
+	// enough incompressible data for the snapshot to span several chunks
+	rng := rand.New(rand.NewSource(1))
+	var txs [][]byte
+	for i := 0; i < 64; i++ {
+		value := make([]byte, 200_000)
+		rng.Read(value)
+		txs = append(txs, []byte(fmt.Sprintf("key%02d=%s", i, hex.EncodeToString(value))))
+	}
+	reversed := make([][]byte, len(txs))
+	for i, tx := range txs {
+		reversed[len(txs)-1-i] = tx
+	}
+
+	// the same state, written in the opposite order by a concurrently
+	// executed block
+	a := setupTestApp(t, nil)
+	finalizeAndCommit(t, a, 1, txs...)
+	b := setupTestApp(t, nil, SetConcurrentExecution(4))
+	finalizeAndCommit(t, b, 1, reversed...)
+	require.NoError(t, AssertStateEqual(a, b))
+	require.Len(t, queryStore(t, a, "main", "key00", 1).Value, 400_000)
+
+	dirA, dirB := t.TempDir(), t.TempDir()
+	require.NoError(t, WriteSnapshot(a, dirA, 1))
+	require.NoError(t, WriteSnapshot(b, dirB, 1))
+
+	files, err := ioutil.ReadDir(dirA)
+	require.NoError(t, err)
+	require.Greater(t, len(files), 2)
+	for _, file := range files {
+		require.Equal(t, readFile(t, filepath.Join(dirA, file.Name())), readFile(t, filepath.Join(dirB, file.Name())), file.Name())
+	}
+}
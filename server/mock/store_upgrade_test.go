@@ -0,0 +1,126 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	upgradetypes "github.com/cosmos/cosmos-sdk/x/upgrade/types"
+)
+
+func queryStore(t *testing.T, app abci.Application, store, key string, height int64) *abci.ResponseQuery {
+	t.Helper()
+
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{
+		Path:   "/store/" + store + "/key",
+		Data:   []byte(key),
+		Height: height,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	return qres
+}
+
+func queryStores(t *testing.T, app abci.Application) []string {
+	t.Helper()
+
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/stores"})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+
+	var names []string
+	require.NoError(t, json.Unmarshal(qres.Value, &names))
+	return names
+}
+
+func TestStagedStoreAddition(t *testing.T) {
+	const upgradeHeight = 3
+	dir := t.TempDir()
+
+	app, err := NewApp(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: []byte(`{"values":[]}`)})
+	require.NoError(t, err)
+	_, err = RunBlocks(app, [][][]byte{{[]byte("a=1")}, {[]byte("b=2")}})
+	require.NoError(t, err)
+	require.NoError(t, app.(*App).Close())
+
+	// reopen with the new store staged for the upgrade height
+	upgrades := &storetypes.StoreUpgrades{Added: []string{"new"}}
+	app, err = NewApp(dir, log.NewNopLogger(),
+		SetExtraStores("new"),
+		SetStoreLoader(upgradetypes.UpgradeStoreLoader(upgradeHeight, upgrades)),
+	)
+	require.NoError(t, err)
+	_, err = RunBlocks(app, [][][]byte{{[]byte("c=3")}, {[]byte("d=4")}})
+	require.NoError(t, err)
+
+	cms := app.(*App).CommitMultiStore()
+	require.Equal(t, int64(upgradeHeight+1), cms.LastCommitID().Version)
+	newStore := cms.GetCommitKVStore(app.(*App).extraStoreKeys[0])
+	require.Equal(t, int64(upgradeHeight+1), newStore.LastCommitID().Version, "the new store follows the chain versions")
+
+	// the new store only exists from the upgrade height on
+	mainStore := cms.GetCommitKVStore(app.(*App).capKeyMainStore)
+	for height := int64(1); height <= upgradeHeight+1; height++ {
+		require.True(t, mainStore.VersionExists(height), "main store should exist at height %d", height)
+		require.Equal(t, height >= upgradeHeight, newStore.VersionExists(height), "new store at height %d", height)
+	}
+	qres := queryStore(t, app, "main", "a", 1)
+	require.Equal(t, []byte("1"), qres.Value)
+}
+
+func TestStoreUpgrades(t *testing.T) {
+	dir := t.TempDir()
+
+	app, err := NewApp(dir, log.NewNopLogger(), SetExtraStores("old", "gone"))
+	require.NoError(t, err)
+	_, err = app.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: []byte(`{"values":[]}`)})
+	require.NoError(t, err)
+	// write straight into the extra stores, the next commit persists them
+	cms := app.(*App).CommitMultiStore()
+	cms.GetCommitKVStore(app.(*App).extraStoreKeys[0]).Set([]byte("x"), []byte("1"))
+	cms.GetCommitKVStore(app.(*App).extraStoreKeys[1]).Set([]byte("y"), []byte("2"))
+	_, err = RunBlocks(app, [][][]byte{{[]byte("a=1")}})
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), queryStore(t, app, "old", "x", 1).Value)
+	require.Equal(t, []string{"gone", "main", "old"}, queryStores(t, app))
+	require.NoError(t, app.(*App).Close())
+
+	upgrades := &storetypes.StoreUpgrades{
+		Added:   []string{"added"},
+		Renamed: []storetypes.StoreRename{{OldKey: "old", NewKey: "renamed"}},
+		Deleted: []string{"gone"},
+	}
+	app, err = NewApp(dir, log.NewNopLogger(),
+		SetExtraStores("added", "renamed"),
+		SetStoreUpgrades(upgrades),
+	)
+	require.NoError(t, err)
+	_, err = RunBlocks(app, [][][]byte{{[]byte("b=2")}})
+	require.NoError(t, err)
+
+	// the data of the renamed store moved over, the main store is untouched
+	require.Equal(t, []byte("1"), queryStore(t, app, "renamed", "x", 2).Value)
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 2).Value)
+	require.Equal(t, []byte("2"), queryStore(t, app, "main", "b", 2).Value)
+
+	cms = app.(*App).CommitMultiStore()
+	added := cms.GetCommitKVStore(app.(*App).extraStoreKeys[0])
+	require.Equal(t, int64(2), added.LastCommitID().Version)
+	require.False(t, added.VersionExists(1))
+	renamed := cms.GetCommitKVStore(app.(*App).extraStoreKeys[1])
+	require.Equal(t, int64(2), renamed.LastCommitID().Version, "the renamed store follows the chain versions")
+	// the old and deleted stores are no longer served
+	require.Equal(t, []string{"added", "main", "renamed"}, queryStores(t, app))
+	for _, store := range []string{"old", "gone"} {
+		qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/store/" + store + "/key", Data: []byte("x")})
+		require.NoError(t, err)
+		require.NotEqual(t, uint32(0), qres.Code, store)
+	}
+}
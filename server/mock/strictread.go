@@ -0,0 +1,17 @@
+package mock
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// get returns the value of key in store. If strict reads are enforced, a key
+// absent from store, neither set by a tx nor by genesis, fails the tx with
+// ErrUnsetKey instead of reading as nil.
+func (app *App) get(store sdk.KVStore, key []byte) ([]byte, error) {
+	value := store.Get(key)
+	if value == nil && app.strictReads {
+		return nil, sdkerrors.Wrapf(ErrUnsetKey, "key %s", key)
+	}
+	return value, nil
+}
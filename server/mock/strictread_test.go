@@ -0,0 +1,49 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictReads(t *testing.T) {
+	blocks := [][][]byte{
+		{[]byte("a=1")},
+		{
+			NewReadTx("a", "g"),
+			NewReadTx("a", "unset"),
+			NewSwapTx("a", "unset"),
+			NewDeleteTx("a"),
+		},
+		{NewReadTx("a")},
+	}
+
+	app := setupTestApp(t, []KV{{"g", "genesis"}}, SetStrictReads(true))
+	responses, err := RunBlocks(app, blocks)
+	require.NoError(t, err)
+	for i, code := range []uint32{0, ErrUnsetKey.ABCICode(), ErrUnsetKey.ABCICode(), 0} {
+		require.Equal(t, code, responses[1].TxResults[i].Code, "tx %d: %s", i, responses[1].TxResults[i].Log)
+	}
+	require.Equal(t, Codespace, responses[1].TxResults[1].Codespace)
+	// the failed swap didn't move the value of a
+	require.Nil(t, queryStore(t, app, "main", "unset", 2).Value)
+	// a deleted key is unset again
+	require.Equal(t, ErrUnsetKey.ABCICode(), responses[2].TxResults[0].Code)
+
+	// without the option an unset key reads as nil
+	app = setupTestApp(t, []KV{{"g", "genesis"}})
+	responses, err = RunBlocks(app, blocks)
+	require.NoError(t, err)
+	for _, resp := range responses {
+		for i, res := range resp.TxResults {
+			require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+		}
+	}
+	var found []string
+	for _, event := range responses[1].TxResults[1].Events {
+		if event.Type == EventTypeRead {
+			found = append(found, string(event.Attributes[2].Value))
+		}
+	}
+	require.Equal(t, []string{"true", "false"}, found)
+}
@@ -0,0 +1,61 @@
+package mock
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// SupplyKey is the reserved key the supply of coins is tracked under when
+// the supply invariant is checked, as a coins string: the balances set by
+// genesis plus the coins credited by every kvBankTx since.
+const SupplyKey = ReservedKeyPrefix + "supply"
+
+// totalBalances returns the sum of the balances stored in store.
+func totalBalances(store sdk.KVStore) (sdk.Coins, error) {
+	total := sdk.NewCoins()
+	iter := sdk.KVStorePrefixIterator(store, []byte(BalanceKeyPrefix))
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		balance, err := sdk.ParseCoinsNormalized(string(iter.Value()))
+		if err != nil {
+			return nil, sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid stored balance of %s: %s", iter.Key()[len(BalanceKeyPrefix):], err)
+		}
+		total = total.Add(balance...)
+	}
+	return total, nil
+}
+
+// initSupply records the balances set by genesis as the initial supply.
+func (app *App) initSupply(store sdk.KVStore) error {
+	supply, err := totalBalances(store)
+	if err != nil {
+		return err
+	}
+	store.Set([]byte(SupplyKey), []byte(supply.String()))
+	return nil
+}
+
+// checkSupply adds the coins minted by the kvBankTxs of a block to the supply
+// and fails with ErrSupplyInvariant unless the balances add up to it, e.g.
+// because a handler credited coins without minting them, or a kvstore tx
+// overwrote a balance.
+func (app *App) checkSupply(ctx sdk.Context, minted sdk.Coins) error {
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	supply, err := sdk.ParseCoinsNormalized(string(store.Get([]byte(SupplyKey))))
+	if err != nil {
+		return sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid supply: %s", err)
+	}
+	supply = supply.Add(minted...)
+
+	total, err := totalBalances(store)
+	if err != nil {
+		return err
+	}
+	// Coins.IsEqual panics on differing denoms, compare the normalized
+	// strings instead
+	if total.String() != supply.String() {
+		return sdkerrors.Wrapf(ErrSupplyInvariant, "total balance %s != supply %s at height %d", total, supply, ctx.BlockHeight())
+	}
+	store.Set([]byte(SupplyKey), []byte(supply.String()))
+	return nil
+}
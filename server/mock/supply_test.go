@@ -0,0 +1,62 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestSupplyInvariant(t *testing.T) {
+	genesis := []KV{{Key: BalanceKeyPrefix + "alice", Value: "10stake"}}
+	stake := func(amount int64) sdk.Coins { return sdk.NewCoins(sdk.NewInt64Coin("stake", amount)) }
+	blocks := [][][]byte{
+		{NewBankTx("alice", stake(5)), []byte("a=1"), NewBankTx("bob", sdk.NewCoins(sdk.NewInt64Coin("atom", 2)))},
+		// a failed credit mints nothing
+		{NewBankTx("bob", stake(3)), []byte("bank:carol=0stake")},
+	}
+
+	for name, opts := range map[string][]Option{
+		"sequential": {SetSupplyInvariant(true)},
+		"concurrent": {SetSupplyInvariant(true), SetConcurrentExecution(4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, genesis, opts...)
+			responses, err := RunBlocks(app, blocks)
+			require.NoError(t, err)
+			require.Equal(t, sdkerrors.ErrInvalidCoins.ABCICode(), responses[1].TxResults[1].Code)
+
+			require.Equal(t, "2atom,15stake", string(queryStore(t, app, "main", SupplyKey, 1).Value))
+			require.Equal(t, "2atom,18stake", string(queryStore(t, app, "main", SupplyKey, 2).Value))
+		})
+	}
+}
+
+func TestSupplyInvariantBroken(t *testing.T) {
+	// a buggy handler crediting coins it never minted along with every write
+	var app *App
+	buggy := func(next sdk.Handler) sdk.Handler {
+		return func(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+			app.kvStore(ctx, app.capKeyMainStore).Set([]byte(BalanceKeyPrefix+"mallory"), []byte("1stake"))
+			return next(ctx, msg)
+		}
+	}
+
+	app = setupTestApp(t, nil, SetSupplyInvariant(true), SetHandlerMiddlewares(buggy))
+	_, err := app.FinalizeBlock(context.Background(), &abci.RequestFinalizeBlock{Height: 1, Txs: [][]byte{[]byte("a=1")}})
+	require.ErrorIs(t, err, ErrSupplyInvariant)
+
+	// so does a tx overwriting a balance
+	app = setupTestApp(t, nil, SetSupplyInvariant(true))
+	_, err = app.FinalizeBlock(context.Background(), &abci.RequestFinalizeBlock{Height: 1, Txs: [][]byte{[]byte(BalanceKeyPrefix + "alice=100stake")}})
+	require.ErrorIs(t, err, ErrSupplyInvariant)
+
+	// without the invariant the block goes through
+	app = setupTestApp(t, nil, SetHandlerMiddlewares(buggy))
+	finalizeAndCommit(t, app, 1, []byte("a=1"))
+	require.Equal(t, []byte("1stake"), queryStore(t, app, "main", BalanceKeyPrefix+"mallory", 1).Value)
+}
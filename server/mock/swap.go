@@ -0,0 +1,116 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// SwapTxPrefix starts the bytes of a kvSwapTx, i.e. "swap:a,b".
+const SwapTxPrefix = "swap:"
+
+// kvSwapTx exchanges the values of two keys of the main store, reading both
+// before writing either. It is an sdk.Tx which is its own sdk.Msg.
+type kvSwapTx struct {
+	keyA  []byte
+	keyB  []byte
+	bytes []byte
+}
+
+// dummy implementation of proto.Message
+func (msg kvSwapTx) Reset()         {}
+func (msg kvSwapTx) String() string { return "TODO" }
+func (msg kvSwapTx) ProtoMessage()  {}
+
+var _ sdk.Tx = kvSwapTx{}
+var _ sdk.Msg = kvSwapTx{}
+
+// NewSwapTx returns the bytes of a tx swapping the values of keyA and keyB.
+func NewSwapTx(keyA, keyB string) []byte {
+	return []byte(fmt.Sprintf("%s%s,%s", SwapTxPrefix, keyA, keyB))
+}
+
+func (tx kvSwapTx) Route() string {
+	return "swap"
+}
+
+func (tx kvSwapTx) Type() string {
+	return "swap_tx"
+}
+
+func (tx kvSwapTx) GetMsgs() []sdk.Msg {
+	return []sdk.Msg{tx}
+}
+
+func (tx kvSwapTx) GetMemo() string {
+	return ""
+}
+
+func (tx kvSwapTx) GetSignBytes() []byte {
+	return tx.bytes
+}
+
+// ValidateBasic rejects swaps touching the keys reserved for the app.
+func (tx kvSwapTx) ValidateBasic() error {
+	for _, key := range [][]byte{tx.keyA, tx.keyB} {
+		if bytes.HasPrefix(key, []byte(ReservedKeyPrefix)) {
+			return sdkerrors.Wrapf(ErrReservedKey, "key %s", key)
+		}
+	}
+	return nil
+}
+
+func (tx kvSwapTx) GetSigners() []sdk.AccAddress {
+	return nil
+}
+
+func (tx kvSwapTx) GetGasEstimate() uint64 {
+	return 0
+}
+
+// decodeSwapTx decodes the bytes of a kvSwapTx, SwapTxPrefix included.
+func decodeSwapTx(txBytes []byte) (sdk.Tx, error) {
+	split := strings.Split(string(txBytes[len(SwapTxPrefix):]), ",")
+	if len(split) != 2 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "expected two keys")
+	}
+	if len(split[0]) == 0 || len(split[1]) == 0 {
+		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "empty key")
+	}
+
+	return kvSwapTx{keyA: []byte(split[0]), keyB: []byte(split[1]), bytes: txBytes}, nil
+}
+
+// swapHandler exchanges the values of the keys of a kvSwapTx. A key swapped
+// with an absent one is deleted, unless strict reads fail the swap.
+func (app *App) swapHandler(ctx sdk.Context, msg sdk.Msg) (*sdk.Result, error) {
+	sTx, ok := msg.(kvSwapTx)
+	if !ok {
+		return nil, sdkerrors.Wrapf(sdkerrors.ErrUnknownRequest, "unexpected swap msg %T", msg)
+	}
+
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	if err := app.checkWriteOnce(store, sTx.keyA, sTx.keyB); err != nil {
+		return nil, err
+	}
+	valueA, err := app.get(store, sTx.keyA)
+	if err != nil {
+		return nil, err
+	}
+	valueB, err := app.get(store, sTx.keyB)
+	if err != nil {
+		return nil, err
+	}
+	for _, write := range []struct{ key, value []byte }{{sTx.keyA, valueB}, {sTx.keyB, valueA}} {
+		if write.value == nil {
+			store.Delete(write.key)
+		} else {
+			store.Set(write.key, write.value)
+		}
+	}
+
+	return &sdk.Result{Log: fmt.Sprintf("swapped %s and %s", sTx.keyA, sTx.keyB)}, nil
+}
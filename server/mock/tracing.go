@@ -0,0 +1,68 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Names of the spans recorded when a tracer provider is set.
+const (
+	// SpanFinalizeBlock spans the execution of a block.
+	SpanFinalizeBlock = "FinalizeBlock"
+	// SpanDeliverTx spans an execution of a tx, a child of the span of its
+	// block. A tx re-executed by the OCC scheduler has a span per execution.
+	SpanDeliverTx = "DeliverTx"
+)
+
+// tracerName is the instrumentation name of the tracer the spans are
+// recorded with.
+const tracerName = "github.com/cosmos/cosmos-sdk/server/mock"
+
+// startBlockSpan starts the span of the block of req, a child of the span
+// carried by the context of the FinalizeBlock call if any, returning ctx
+// carrying it and a function ending it.
+func (app *App) startBlockSpan(ctx sdk.Context, req *abci.RequestFinalizeBlock) (sdk.Context, func()) {
+	if app.tracer == nil {
+		return ctx, func() {}
+	}
+	parent := app.finalizeCtx
+	if parent == nil {
+		parent = context.Background()
+	}
+	spanCtx, span := app.tracer.Start(parent, SpanFinalizeBlock, trace.WithAttributes(
+		attribute.Int64("height", req.Height),
+		attribute.Int("txs", len(req.Txs)),
+	))
+	return ctx.WithTraceSpanContext(spanCtx), func() { span.End() }
+}
+
+// startTxSpan starts the span of an execution of the tx with checksum as a
+// child of the span carried by ctx, returning ctx carrying it and a function
+// ending it with the result of the execution.
+func (app *App) startTxSpan(ctx sdk.Context, checksum [32]byte) (sdk.Context, func(abci.ResponseDeliverTx)) {
+	if app.tracer == nil {
+		return ctx, func(abci.ResponseDeliverTx) {}
+	}
+	parent := ctx.TraceSpanContext()
+	if parent == nil {
+		parent = context.Background()
+	}
+	spanCtx, span := app.tracer.Start(parent, SpanDeliverTx, trace.WithAttributes(
+		attribute.Int("tx_index", ctx.TxIndex()),
+		attribute.String("tx_hash", fmt.Sprintf("%X", checksum)),
+	))
+	return ctx.WithTraceSpanContext(spanCtx), func(res abci.ResponseDeliverTx) {
+		span.SetAttributes(attribute.Int64("code", int64(res.Code)))
+		if res.Code != 0 {
+			span.SetStatus(codes.Error, res.Log)
+		}
+		span.End()
+	}
+}
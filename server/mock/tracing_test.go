@@ -0,0 +1,69 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracerProvider(t *testing.T) {
+	txs := [][]byte{[]byte("a=1"), []byte(ReservedKeyPrefix + "x=1"), NewSwapTx("a", "b")}
+
+	for name, opts := range map[string][]Option{
+		"sequential": nil,
+		"concurrent": {SetConcurrentExecution(len(txs))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			app := setupTestApp(t, nil, append(opts, SetTracerProvider(tp))...)
+
+			// the block span is a child of the span of the caller
+			goCtx, parent := tp.Tracer("test").Start(context.Background(), "test")
+			_, err := app.FinalizeBlock(goCtx, NewFinalizeRequest(1, txs))
+			require.NoError(t, err)
+			parent.End()
+			_, err = app.Commit(context.Background())
+			require.NoError(t, err)
+
+			spans := exporter.GetSpans()
+			var block tracetest.SpanStub
+			txSpans := make(map[int64]tracetest.SpanStub)
+			for _, span := range spans {
+				switch span.Name {
+				case SpanFinalizeBlock:
+					block = span
+				case SpanDeliverTx:
+					txSpans[attributeInt(t, span.Attributes, "tx_index")] = span
+				}
+			}
+			require.Equal(t, SpanFinalizeBlock, block.Name)
+			require.Equal(t, parent.SpanContext().SpanID(), block.Parent.SpanID())
+			require.Equal(t, int64(1), attributeInt(t, block.Attributes, "height"))
+			require.Equal(t, int64(len(txs)), attributeInt(t, block.Attributes, "txs"))
+
+			require.Len(t, txSpans, len(txs))
+			for i, span := range txSpans {
+				require.Equal(t, block.SpanContext.SpanID(), span.Parent.SpanID(), "tx %d", i)
+				require.Equal(t, block.SpanContext.TraceID(), span.SpanContext.TraceID(), "tx %d", i)
+			}
+			require.Equal(t, int64(ErrReservedKey.ABCICode()), attributeInt(t, txSpans[1].Attributes, "code"))
+			require.Zero(t, attributeInt(t, txSpans[2].Attributes, "code"))
+		})
+	}
+}
+
+func attributeInt(t *testing.T, attrs []attribute.KeyValue, key string) int64 {
+	t.Helper()
+	for _, attr := range attrs {
+		if string(attr.Key) == key {
+			return attr.Value.AsInt64()
+		}
+	}
+	t.Fatalf("no attribute %s", key)
+	return 0
+}
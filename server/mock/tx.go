@@ -14,6 +14,13 @@ type kvstoreTx struct {
 	key   []byte
 	value []byte
 	bytes []byte
+	// meta is opaque client data echoed into the tx result info.
+	meta []byte
+	// gasLimit caps the gas the tx runs with unless zero.
+	gasLimit uint64
+	// msgs holds every pair of a tx setting more than one key, key and
+	// value then being those of the first pair.
+	msgs []kvstoreTx
 }
 
 // dummy implementation of proto.Message
@@ -33,6 +40,24 @@ func NewTx(key, value string) kvstoreTx {
 	}
 }
 
+// MetaSeparator separates the opaque metadata from the key/value pair in
+// the tx bytes, i.e. "key=value#meta". Everything after the first separator
+// is metadata.
+const MetaSeparator = '#'
+
+// PairSeparator separates the key/value pairs of a tx setting several keys,
+// i.e. "k1=v1&k2=v2". Each pair is a msg of its own, applied in order, so a
+// key appearing in several pairs ends up with the value of the last one.
+const PairSeparator = '&'
+
+// NewTxWithMeta returns the bytes of a tx setting key to value and carrying
+// meta, which is echoed as the Info of the tx result.
+func NewTxWithMeta(key, value string, meta []byte) []byte {
+	bz := []byte(fmt.Sprintf("%s=%s", key, value))
+	bz = append(bz, MetaSeparator)
+	return append(bz, meta...)
+}
+
 func (tx kvstoreTx) Route() string {
 	return "kvstore"
 }
@@ -42,7 +67,14 @@ func (tx kvstoreTx) Type() string {
 }
 
 func (tx kvstoreTx) GetMsgs() []sdk.Msg {
-	return []sdk.Msg{tx}
+	if len(tx.msgs) == 0 {
+		return []sdk.Msg{tx}
+	}
+	msgs := make([]sdk.Msg, len(tx.msgs))
+	for i, msg := range tx.msgs {
+		msgs[i] = msg
+	}
+	return msgs
 }
 
 func (tx kvstoreTx) GetMemo() string {
@@ -69,18 +101,69 @@ func (tx kvstoreTx) GetGasEstimate() uint64 {
 // takes raw transaction bytes and decodes them into an sdk.Tx. An sdk.Tx has
 // all the signatures and can be used to authenticate.
 func decodeTx(txBytes []byte) (sdk.Tx, error) {
-	var tx sdk.Tx
+	if bytes.HasPrefix(txBytes, []byte(BankTxPrefix)) {
+		return decodeBankTx(txBytes)
+	}
+	if bytes.HasPrefix(txBytes, []byte(SwapTxPrefix)) {
+		return decodeSwapTx(txBytes)
+	}
+	if bytes.HasPrefix(txBytes, []byte(ReadTxPrefix)) {
+		return decodeReadTx(txBytes)
+	}
+	if bytes.HasPrefix(txBytes, []byte(DeleteTxPrefix)) {
+		return decodeDeleteTx(txBytes)
+	}
+	if bytes.HasPrefix(txBytes, []byte(GasTxPrefix)) {
+		return decodeGasLimitedTx(txBytes)
+	}
+	if bytes.HasPrefix(txBytes, []byte(HintsTxPrefix)) {
+		return decodeHintedTx(txBytes)
+	}
+	if bytes.HasPrefix(txBytes, []byte(FeeTxPrefix)) {
+		return decodeFeeTx(txBytes)
+	}
+
+	tx := kvstoreTx{bytes: txBytes}
 
-	split := bytes.Split(txBytes, []byte("="))
+	kv := txBytes
+	if i := bytes.IndexByte(txBytes, MetaSeparator); i >= 0 {
+		kv, tx.meta = txBytes[:i], txBytes[i+1:]
+	}
+
+	pairs := bytes.Split(kv, []byte{PairSeparator})
+	for _, pair := range pairs {
+		msg, err := decodePair(pair)
+		if err != nil {
+			return nil, err
+		}
+		tx.msgs = append(tx.msgs, msg)
+	}
+	tx.key, tx.value = tx.msgs[0].key, tx.msgs[0].value
+	if len(tx.msgs) == 1 {
+		tx.msgs = nil
+	}
+
+	return tx, nil
+}
+
+// decodePair decodes a single "key=value" pair, a bare key being its own
+// value. A pair ending with '=' sets an empty value, which is stored as such:
+// the key is present afterwards, unlike a deleted one.
+func decodePair(pair []byte) (kvstoreTx, error) {
+	msg := kvstoreTx{bytes: pair}
+
+	split := bytes.Split(pair, []byte("="))
 	if len(split) == 1 {
-		k := split[0]
-		tx = kvstoreTx{k, k, txBytes}
+		msg.key, msg.value = split[0], split[0]
 	} else if len(split) == 2 {
-		k, v := split[0], split[1]
-		tx = kvstoreTx{k, v, txBytes}
+		msg.key, msg.value = split[0], split[1]
 	} else {
-		return nil, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "too many '='")
+		return kvstoreTx{}, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "too many '='")
+	}
+	// the store panics on empty keys, reject them before they get that far
+	if len(msg.key) == 0 {
+		return kvstoreTx{}, sdkerrors.Wrap(sdkerrors.ErrTxDecode, "empty key")
 	}
 
-	return tx, nil
+	return msg, nil
 }
@@ -0,0 +1,66 @@
+package mock
+
+import (
+	"encoding/json"
+	"strconv"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// TxCountKey is the reserved key the number of txs successfully applied since
+// genesis is stored under, as a decimal string.
+const TxCountKey = ReservedKeyPrefix + "txcount"
+
+// txCount returns the tx count stored in store, 0 if none is.
+func txCount(store sdk.KVStore) (uint64, error) {
+	bz := store.Get([]byte(TxCountKey))
+	if bz == nil {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(bz), 10, 64)
+}
+
+// addTxCount adds the number of txs of a block which executed successfully to
+// the tx count. It runs once the txs of the block have executed rather than
+// from each tx, so that counting does not make the txs of a concurrently
+// executed block conflict.
+func (app *App) addTxCount(ctx sdk.Context, applied uint64) error {
+	if applied == 0 {
+		return nil
+	}
+
+	store := app.kvStore(ctx, app.capKeyMainStore)
+	count, err := txCount(store)
+	if err != nil {
+		return sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid tx count: %s", err)
+	}
+	store.Set([]byte(TxCountKey), []byte(strconv.FormatUint(count+applied, 10)))
+	return nil
+}
+
+// handleQueryTxCount returns the JSON encoded number of txs successfully
+// applied up to the requested height.
+func (app *App) handleQueryTxCount(req abci.RequestQuery) abci.ResponseQuery {
+	ctx, err := app.CreateQueryContext(req.Height, false)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+
+	count, err := txCount(ctx.KVStore(app.capKeyMainStore))
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrLogic, "invalid tx count: %s", err))
+	}
+	bz, err := json.Marshal(count)
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to JSON encode tx count"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    ctx.BlockHeight(),
+		Value:     bz,
+	}
+}
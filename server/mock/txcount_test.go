@@ -0,0 +1,55 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func queryTxCount(t *testing.T, app abci.Application, height int64) uint64 {
+	t.Helper()
+	qres, err := app.Query(context.Background(), &abci.RequestQuery{Path: "/mock/txcount", Height: height})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), qres.Code, qres.Log)
+	var count uint64
+	require.NoError(t, json.Unmarshal(qres.Value, &count))
+	return count
+}
+
+func TestTxCount(t *testing.T) {
+	blocks := [][][]byte{
+		{[]byte("a=1"), []byte("b=2"), []byte("c=3")},
+		// neither a failing tx nor an undecodable one, which is not
+		// executed, is counted
+		{[]byte(ReservedKeyPrefix + "x=1"), []byte("a=b=c"), NewSwapTx("a", "b")},
+		{},
+		{[]byte("a=4"), NewDeleteTx("c")},
+	}
+
+	for name, opts := range map[string][]Option{
+		"sequential": {SetTxCounting(true)},
+		"concurrent": {SetTxCounting(true), SetConcurrentExecution(4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, opts...)
+			require.Zero(t, queryTxCount(t, app, 0))
+
+			responses, err := RunBlocks(app, blocks)
+			require.NoError(t, err)
+			require.Equal(t, ErrReservedKey.ABCICode(), responses[1].TxResults[0].Code)
+
+			for i, expected := range []uint64{3, 4, 4, 6} {
+				require.Equal(t, expected, queryTxCount(t, app, int64(i+1)))
+			}
+		})
+	}
+
+	// without counting nothing is stored
+	app := setupTestApp(t, nil)
+	_, err := RunBlocks(app, blocks)
+	require.NoError(t, err)
+	require.Zero(t, queryTxCount(t, app, 0))
+}
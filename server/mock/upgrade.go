@@ -0,0 +1,25 @@
+package mock
+
+import (
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// UpgradeRules are the rules the handlers switch to from Height on, the way
+// a module migrates its behaviour in place at an upgrade height. Blocks
+// below Height execute with the rules configured by the other options.
+type UpgradeRules struct {
+	Height int64 `json:"height"`
+
+	// GasConfig, if set, meters the accesses to every store instead of the
+	// gas configs set with SetKVGasConfig.
+	GasConfig *storetypes.GasConfig `json:"gas_config,omitempty"`
+	// MaxValueSize, if positive, makes the kvstore handler reject values
+	// longer than it with ErrValueTooLarge.
+	MaxValueSize int `json:"max_value_size,omitempty"`
+}
+
+// upgraded reports whether the block of ctx executes with the upgrade rules.
+func (app *App) upgraded(ctx sdk.Context) bool {
+	return app.upgradeRules != nil && app.upgradeRules.Height > 0 && ctx.BlockHeight() >= app.upgradeRules.Height
+}
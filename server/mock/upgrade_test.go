@@ -0,0 +1,34 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestUpgradeRules(t *testing.T) {
+	gasConfig := storetypes.KVGasConfig()
+	gasConfig.WriteCostPerByte *= 10
+	app := setupTestApp(t, nil, SetUpgradeRules(UpgradeRules{Height: 3, GasConfig: &gasConfig, MaxValueSize: 3}))
+
+	var gasUsed []int64
+	for height := int64(1); height <= 4; height++ {
+		resp := finalizeAndCommit(t, app, height, []byte("a=1"), []byte("b=long"))
+		require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+		gasUsed = append(gasUsed, resp.TxResults[0].GasUsed)
+
+		if height < 3 {
+			require.Equal(t, uint32(0), resp.TxResults[1].Code, resp.TxResults[1].Log)
+		} else {
+			require.Equal(t, ErrValueTooLarge.ABCICode(), resp.TxResults[1].Code)
+		}
+	}
+
+	// the gas schedule switches exactly at the upgrade height
+	require.Equal(t, gasUsed[0], gasUsed[1])
+	require.Greater(t, gasUsed[2], gasUsed[1])
+	require.Equal(t, gasUsed[2], gasUsed[3])
+	require.Equal(t, []byte("long"), queryStore(t, app, "main", "b", 4).Value)
+}
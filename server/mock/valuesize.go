@@ -0,0 +1,48 @@
+package mock
+
+import (
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// valueSizeError is the panic raised by a sizeLimitKVStore rejecting a write,
+// turned back into err by recoverValueSize once the tx is aborted.
+type valueSizeError struct {
+	err error
+}
+
+// sizeLimitKVStore rejects writes of values longer than max bytes to keys
+// other than the reserved ones. KVStore.Set has no way to return an error, so
+// it panics, which aborts the tx whichever handler or path issued the write.
+type sizeLimitKVStore struct {
+	sdk.KVStore
+
+	max int
+}
+
+func (s *sizeLimitKVStore) Set(key, value []byte) {
+	if err := checkValueSize(s.max, key, value); err != nil {
+		panic(valueSizeError{err})
+	}
+	s.KVStore.Set(key, value)
+}
+
+// checkValueSize returns ErrValueTooLarge if value is longer than max bytes,
+// unless max is zero or key is reserved.
+func checkValueSize(max int, key, value []byte) error {
+	if max > 0 && len(value) > max && !strings.HasPrefix(string(key), ReservedKeyPrefix) {
+		return sdkerrors.Wrapf(ErrValueTooLarge, "value of %s is %d bytes, max %d", key, len(value), max)
+	}
+	return nil
+}
+
+// recoverValueSize is the tx recovery handler returning the error of a write
+// rejected by a sizeLimitKVStore.
+func recoverValueSize(recoveryObj interface{}) error {
+	if e, ok := recoveryObj.(valueSizeError); ok {
+		return e.err
+	}
+	return nil
+}
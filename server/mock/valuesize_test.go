@@ -0,0 +1,75 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestMaxValueSize(t *testing.T) {
+	app := setupTestApp(t, []KV{{"g", "genesis"}}, SetMaxValueSize(10))
+
+	resp := finalizeAndCommit(t, app, 1, []byte("a="+strings.Repeat("x", 11)), []byte("b="+strings.Repeat("x", 10)))
+	require.Equal(t, ErrValueTooLarge.ABCICode(), resp.TxResults[0].Code, resp.TxResults[0].Log)
+	require.Equal(t, Codespace, resp.TxResults[0].Codespace)
+	require.Equal(t, uint32(0), resp.TxResults[1].Code, resp.TxResults[1].Log)
+	require.Nil(t, queryStore(t, app, "main", "a", 1).Value)
+	require.Equal(t, []byte(strings.Repeat("x", 10)), queryStore(t, app, "main", "b", 1).Value)
+
+	// the store rejects the write even when the handler is called directly,
+	// bypassing any validation
+	ms := app.CommitMultiStore().CacheMultiStore()
+	ctx := sdk.NewContext(ms, tmproto.Header{Height: 2}, false, log.NewNopLogger())
+	func() {
+		defer func() {
+			err := recoverValueSize(recover())
+			require.True(t, sdkerrors.IsOf(err, ErrValueTooLarge), err)
+		}()
+		app.setKV(ctx, kvstoreTx{key: []byte("c"), value: []byte(strings.Repeat("x", 11))})
+	}()
+	require.False(t, ms.GetKVStore(app.capKeyMainStore).Has([]byte("c")))
+
+	// genesis values are subject to the limit too
+	bigApp, err := NewApp(t.TempDir(), log.NewNopLogger(), SetMaxValueSize(10))
+	require.NoError(t, err)
+	appState, err := json.Marshal(GenesisJSON{Values: []KV{{"g", strings.Repeat("x", 11)}}})
+	require.NoError(t, err)
+	err = bigApp.(*App).ValidateInitChain(abci.RequestInitChain{AppStateBytes: appState})
+	require.True(t, sdkerrors.IsOf(err, ErrValueTooLarge), err)
+	require.Panics(t, func() {
+		_, _ = bigApp.InitChain(context.Background(), &abci.RequestInitChain{AppStateBytes: appState})
+	})
+
+	// the other stores are not capped
+	extraApp := setupTestApp(t, nil, SetMaxValueSize(10), SetExtraStores("extra"))
+	ms = extraApp.CommitMultiStore().CacheMultiStore()
+	ctx = sdk.NewContext(ms, tmproto.Header{Height: 1}, false, log.NewNopLogger())
+	extraApp.kvStore(ctx, extraApp.extraStoreKeys[0]).Set([]byte("c"), []byte(strings.Repeat("x", 11)))
+	require.True(t, ms.GetKVStore(extraApp.extraStoreKeys[0]).Has([]byte("c")))
+}
+
+func TestMaxValueSizeConflictMerge(t *testing.T) {
+	app := setupTestApp(t, nil, SetConflictMerge(concatMerge), SetMaxValueSize(4))
+
+	// a staged write is checked like a direct one
+	resp := finalizeAndCommit(t, app, 1, []byte("k="+strings.Repeat("x", 100)), []byte("k=ab"), []byte("k=cd"))
+	require.Equal(t, ErrValueTooLarge.ABCICode(), resp.TxResults[0].Code, resp.TxResults[0].Log)
+	require.Equal(t, uint32(0), resp.TxResults[1].Code, resp.TxResults[1].Log)
+	require.Equal(t, uint32(0), resp.TxResults[2].Code, resp.TxResults[2].Log)
+	require.Equal(t, []byte("abcd"), queryStore(t, app, "main", "k", 1).Value)
+
+	// and so is the merged value, which fails the block as no tx is left to
+	// fail
+	_, err := app.FinalizeBlock(context.Background(), &abci.RequestFinalizeBlock{Height: 2, Txs: [][]byte{[]byte("k=abc"), []byte("k=de")}})
+	require.True(t, sdkerrors.IsOf(err, ErrValueTooLarge), err)
+	require.Equal(t, []byte("abcd"), queryStore(t, app, "main", "k", 1).Value)
+}
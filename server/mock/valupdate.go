@@ -0,0 +1,107 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// ValidatorKeyPrefix prefixes the reserved keys the power of every validator
+// updated by a tx is stored under, followed by its hex encoded public key.
+const ValidatorKeyPrefix = ReservedKeyPrefix + "validator/"
+
+// ValUpdateKey is a special key: a tx setting it to "<pubkey>!<power>", with
+// pubkey the hex encoded ed25519 public key of a validator, updates the power
+// of that validator, a zero power removing it. The updates of a block are
+// reported in its ValidatorUpdates, sorted by validator address.
+const ValUpdateKey = "__valupdate__"
+
+// NewValUpdateTx returns the bytes of a tx updating the power of the
+// validator with the given ed25519 public key.
+func NewValUpdateTx(pubKey []byte, power int64) []byte {
+	return []byte(fmt.Sprintf("%s=%X!%d", ValUpdateKey, pubKey, power))
+}
+
+// parseValUpdate parses the value of a ValUpdateKey write into the reserved
+// key and value the validator power is stored as.
+func parseValUpdate(value []byte) (key []byte, power []byte, err error) {
+	split := strings.Split(string(value), "!")
+	if len(split) != 2 {
+		return nil, nil, sdkerrors.Wrapf(ErrInvalidValUpdate, "expected pubkey!power, got %q", value)
+	}
+	pubKey, err := hex.DecodeString(split[0])
+	if err != nil {
+		return nil, nil, sdkerrors.Wrapf(ErrInvalidValUpdate, "pubkey %q: %s", split[0], err)
+	}
+	if len(pubKey) != ed25519.PubKeySize {
+		return nil, nil, sdkerrors.Wrapf(ErrInvalidValUpdate, "pubkey size %d, expected %d", len(pubKey), ed25519.PubKeySize)
+	}
+	p, err := strconv.ParseInt(split[1], 10, 64)
+	if err != nil || p < 0 {
+		return nil, nil, sdkerrors.Wrapf(ErrInvalidValUpdate, "invalid power %q", split[1])
+	}
+	return []byte(ValidatorKeyPrefix + fmt.Sprintf("%X", pubKey)), []byte(strconv.FormatInt(p, 10)), nil
+}
+
+// validatorUpdates collects the validator updates applied by the successful
+// txs of a block. A validator updated more than once keeps its last power,
+// and the updates are sorted by validator address so that the same block
+// always reports them the same way, whatever order the txs executed in.
+func validatorUpdates(txResults []*abci.ExecTxResult) []abci.ValidatorUpdate {
+	powers := make(map[string]int64)
+	for _, res := range txResults {
+		if res == nil || res.Code != 0 {
+			continue
+		}
+		for _, event := range res.Events {
+			if event.Type != EventTypeKVStore || len(event.Attributes) < 2 {
+				continue
+			}
+			key := string(event.Attributes[0].Value)
+			if !strings.HasPrefix(key, ValidatorKeyPrefix) {
+				continue
+			}
+			power, err := strconv.ParseInt(string(event.Attributes[1].Value), 10, 64)
+			if err != nil {
+				continue
+			}
+			powers[strings.TrimPrefix(key, ValidatorKeyPrefix)] = power
+		}
+	}
+	if len(powers) == 0 {
+		return nil
+	}
+
+	type update struct {
+		address []byte
+		update  abci.ValidatorUpdate
+	}
+	updates := make([]update, 0, len(powers))
+	for hexKey, power := range powers {
+		pubKey, err := hex.DecodeString(hexKey)
+		if err != nil {
+			continue
+		}
+		updates = append(updates, update{
+			address: ed25519.PubKey(pubKey).Address(),
+			update:  abci.Ed25519ValidatorUpdate(pubKey, power),
+		})
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		return bytes.Compare(updates[i].address, updates[j].address) < 0
+	})
+
+	res := make([]abci.ValidatorUpdate, len(updates))
+	for i, u := range updates {
+		res[i] = u.update
+	}
+	return res
+}
@@ -0,0 +1,86 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+)
+
+func TestValidatorUpdatesOrder(t *testing.T) {
+	pubKeys := make([][]byte, 4)
+	for i := range pubKeys {
+		pubKeys[i] = ed25519.GenPrivKeyFromSecret([]byte{byte(i)}).PubKey().Bytes()
+	}
+	txs := [][]byte{
+		NewValUpdateTx(pubKeys[0], 10),
+		NewValUpdateTx(pubKeys[1], 20),
+		NewValUpdateTx(pubKeys[2], 30),
+		NewValUpdateTx(pubKeys[3], 40),
+		// a later update of the same validator wins
+		NewValUpdateTx(pubKeys[1], 0),
+	}
+
+	var expected []abci.ValidatorUpdate
+	for name, order := range map[string][]int{
+		"in order": {0, 1, 2, 3, 4},
+		"reverse":  {3, 2, 1, 0, 4},
+		"shuffled": {2, 0, 3, 1, 4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			block := make([][]byte, len(order))
+			for i, j := range order {
+				block[i] = txs[j]
+			}
+			for _, opts := range [][]Option{nil, {SetConcurrentExecution(len(block))}} {
+				app := setupTestApp(t, nil, opts...)
+				resp := finalizeAndCommit(t, app, 1, block...)
+				for i, res := range resp.TxResults {
+					require.Equal(t, uint32(0), res.Code, "tx %d: %s", i, res.Log)
+				}
+
+				updates := resp.ValidatorUpdates
+				require.Len(t, updates, len(pubKeys))
+				for i := 1; i < len(updates); i++ {
+					prev := ed25519.PubKey(updates[i-1].PubKey.GetEd25519()).Address()
+					cur := ed25519.PubKey(updates[i].PubKey.GetEd25519()).Address()
+					require.Negative(t, bytes.Compare(prev, cur), "updates must be sorted by address")
+				}
+				for _, update := range updates {
+					if bytes.Equal(update.PubKey.GetEd25519(), pubKeys[1]) {
+						require.Zero(t, update.Power)
+					}
+				}
+
+				if expected == nil {
+					expected = updates
+				}
+				require.Equal(t, expected, updates)
+			}
+		})
+	}
+}
+
+func TestValidatorUpdatesInvalid(t *testing.T) {
+	pubKey := ed25519.GenPrivKey().PubKey().Bytes()
+	app := setupTestApp(t, nil)
+	resp := finalizeAndCommit(t, app, 1,
+		NewValUpdateTx(pubKey[:16], 10),
+		NewValUpdateTx(pubKey, -1),
+		[]byte(ValUpdateKey+"=nopower"),
+		[]byte(PanicKey+"=boom"),
+	)
+	for i, res := range resp.TxResults[:3] {
+		require.Equal(t, ErrInvalidValUpdate.ABCICode(), res.Code, "tx %d: %s", i, res.Log)
+	}
+	require.Empty(t, resp.ValidatorUpdates)
+
+	// the power of an updated validator is kept in the state
+	resp = finalizeAndCommit(t, app, 2, NewValUpdateTx(pubKey, 5))
+	require.Equal(t, []abci.ValidatorUpdate{abci.Ed25519ValidatorUpdate(pubKey, 5)}, resp.ValidatorUpdates)
+	key := fmt.Sprintf("%s%X", ValidatorKeyPrefix, pubKey)
+	require.Equal(t, []byte("5"), queryStore(t, app, "main", key, 2).Value)
+}
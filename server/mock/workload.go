@@ -0,0 +1,68 @@
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// WorkloadSpec describes the txs generated by GenWorkload. The weights give
+// the ratio of sets, deletes and increments among the txs; a spec with every
+// weight zero generates sets only.
+type WorkloadSpec struct {
+	// Txs is the number of txs generated.
+	Txs int
+	// Keys is the size of the key space, the balances of the accounts key0
+	// through key<Keys-1>.
+	Keys int
+
+	SetWeight       int
+	DeleteWeight    int
+	IncrementWeight int
+
+	// ZipfS is the exponent of the Zipf distribution keys are picked from,
+	// key0 being the hottest. Keys are picked uniformly when it is at most 1.
+	ZipfS float64
+	// Seed seeds the generator, the same spec always generating the same txs.
+	Seed int64
+}
+
+// GenWorkload returns txs following spec. Every tx writes the balance of the
+// account picked for it: sets write an amount unique to the tx, deletes
+// remove the balance, and increments credit one stake to it, a
+// read-modify-write. Txs of any kind on the same account therefore conflict
+// when executed concurrently.
+func GenWorkload(spec WorkloadSpec) [][]byte {
+	if spec.Txs <= 0 || spec.Keys <= 0 {
+		return nil
+	}
+
+	setWeight := spec.SetWeight
+	total := spec.SetWeight + spec.DeleteWeight + spec.IncrementWeight
+	if total <= 0 {
+		setWeight, total = 1, 1
+	}
+
+	r := rand.New(rand.NewSource(spec.Seed))
+	pick := func() int { return r.Intn(spec.Keys) }
+	if spec.ZipfS > 1 && spec.Keys > 1 {
+		zipf := rand.NewZipf(r, spec.ZipfS, 1, uint64(spec.Keys-1))
+		pick = func() int { return int(zipf.Uint64()) }
+	}
+
+	increment := sdk.NewCoins(sdk.NewInt64Coin("stake", 1))
+	txs := make([][]byte, spec.Txs)
+	for i := range txs {
+		account := fmt.Sprintf("key%d", pick())
+		switch w := r.Intn(total); {
+		case w < setWeight:
+			txs[i] = []byte(fmt.Sprintf("%s%s=%dstake", BalanceKeyPrefix, account, i))
+		case w < setWeight+spec.DeleteWeight:
+			txs[i] = NewDeleteTx(BalanceKeyPrefix + account)
+		default:
+			txs[i] = NewBankTx(account, increment)
+		}
+	}
+	return txs
+}
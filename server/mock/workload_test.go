@@ -0,0 +1,109 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenWorkload(t *testing.T) {
+	spec := WorkloadSpec{Txs: 1000, Keys: 50, SetWeight: 2, DeleteWeight: 1, IncrementWeight: 1, ZipfS: 1.5, Seed: 1}
+	txs := GenWorkload(spec)
+	require.Len(t, txs, spec.Txs)
+	require.Equal(t, txs, GenWorkload(spec))
+
+	spec.Seed = 2
+	require.NotEqual(t, txs, GenWorkload(spec))
+
+	// every kind of tx writes the balances, so they contend with each other
+	kinds := make(map[string]int)
+	writers := make(map[string]map[string]bool)
+	for _, tx := range txs {
+		decoded, err := decodeTx(tx)
+		require.NoError(t, err)
+		kind := fmt.Sprintf("%T", decoded)
+		kinds[kind]++
+
+		var key string
+		switch tx := decoded.(type) {
+		case kvstoreTx:
+			key = string(tx.key)
+		case kvDeleteTx:
+			require.Len(t, tx.keys, 1)
+			key = string(tx.keys[0])
+		case kvBankTx:
+			key = BalanceKeyPrefix + tx.recipient
+		}
+		require.True(t, strings.HasPrefix(key, BalanceKeyPrefix+"key"), "tx %q", tx)
+		if writers[key] == nil {
+			writers[key] = make(map[string]bool)
+		}
+		writers[key][kind] = true
+	}
+	require.InDelta(t, 500, kinds["mock.kvstoreTx"], 60)
+	require.InDelta(t, 250, kinds["mock.kvDeleteTx"], 60)
+	require.InDelta(t, 250, kinds["mock.kvBankTx"], 60)
+	require.Len(t, writers[BalanceKeyPrefix+"key0"], 3)
+
+	// key0 is the hottest key under Zipf, but not picked uniformly
+	countKey0 := func(txs [][]byte) int {
+		n := 0
+		for _, tx := range txs {
+			if bytes.Contains(tx, []byte("key0=")) {
+				n++
+			}
+		}
+		return n
+	}
+	hot := GenWorkload(WorkloadSpec{Txs: 1000, Keys: 50, ZipfS: 1.5})
+	uniform := GenWorkload(WorkloadSpec{Txs: 1000, Keys: 50})
+	require.Greater(t, countKey0(hot), 200)
+	require.Less(t, countKey0(uniform), 60)
+
+	require.Nil(t, GenWorkload(WorkloadSpec{Txs: 10}))
+}
+
+func TestWorkloadExecutionModes(t *testing.T) {
+	txs := GenWorkload(WorkloadSpec{Txs: 200, Keys: 20, SetWeight: 1, DeleteWeight: 1, IncrementWeight: 1, ZipfS: 1.2, Seed: 7})
+	blocks := [][][]byte{txs[:100], txs[100:]}
+
+	sequential := setupTestApp(t, nil)
+	concurrent := setupTestApp(t, nil, SetConcurrentExecution(4))
+	_, err := RunBlocks(sequential, blocks)
+	require.NoError(t, err)
+	_, err = RunBlocks(concurrent, blocks)
+	require.NoError(t, err)
+	require.NoError(t, AssertStateEqual(sequential, concurrent))
+}
+
+func TestDeleteTx(t *testing.T) {
+	app := setupTestApp(t, []KV{{Key: "a", Value: "1"}})
+
+	resp := finalizeAndCommit(t, app, 1, NewDeleteTx("a", "b"), NewDeleteTx(ReservedKeyPrefix+"x"))
+	require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+	require.Equal(t, ErrReservedKey.ABCICode(), resp.TxResults[1].Code)
+	require.Nil(t, queryStore(t, app, "main", "a", 1).Value)
+
+	_, err := decodeTx([]byte(DeleteTxPrefix))
+	require.Error(t, err)
+}
+
+func BenchmarkWorkload(b *testing.B) {
+	for _, hotness := range []struct {
+		name  string
+		zipfS float64
+	}{{"uniform", 0}, {"zipf", 1.5}} {
+		txs := GenWorkload(WorkloadSpec{Txs: 100, Keys: 100, SetWeight: 2, DeleteWeight: 1, IncrementWeight: 1, ZipfS: hotness.zipfS, Seed: 1})
+		for name, opts := range map[string][]Option{
+			"sequential": nil,
+			"concurrent": {SetConcurrentExecution(4)},
+		} {
+			b.Run(hotness.name+"/"+name, func(b *testing.B) {
+				benchmarkBlocks(b, txs, opts...)
+			})
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"bytes"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// checkWriteOnce returns ErrImmutableKey if write-once keys are enforced and
+// any of keys is already present in store, leaving the tx to fail before it
+// writes anything. With a merge function set, a key an earlier tx of the
+// block staged a write to is present too.
+func (app *App) checkWriteOnce(store sdk.KVStore, keys ...[]byte) error {
+	if !app.writeOnce {
+		return nil
+	}
+	for _, key := range keys {
+		if store.Has(key) || (app.mergeFunc != nil && hasStagedWrite(store, key)) {
+			return sdkerrors.Wrapf(ErrImmutableKey, "key %s", key)
+		}
+	}
+	return nil
+}
+
+// hasStagedWrite reports whether a write to key is staged in store.
+func hasStagedWrite(store sdk.KVStore, key []byte) bool {
+	it := sdk.KVStorePrefixIterator(store, []byte(MergeKeyPrefix))
+	defer it.Close()
+	for ; it.Valid(); it.Next() {
+		if bytes.Equal(it.Key()[len(MergeKeyPrefix)+8:], key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestWriteOnce(t *testing.T) {
+	app := setupTestApp(t, []KV{{"g", "genesis"}}, SetWriteOnce(true))
+
+	resp := finalizeAndCommit(t, app, 1, []byte("a=1"), []byte("a=2"), []byte(TimeKey), []byte(TimeKey))
+	require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+	require.Equal(t, ErrImmutableKey.ABCICode(), resp.TxResults[1].Code, resp.TxResults[1].Log)
+	require.Equal(t, Codespace, resp.TxResults[1].Codespace)
+	// special keys are rewritten by design
+	require.Equal(t, uint32(0), resp.TxResults[2].Code, resp.TxResults[2].Log)
+	require.Equal(t, uint32(0), resp.TxResults[3].Code, resp.TxResults[3].Log)
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 1).Value)
+
+	// every kind of write to a present key is rejected, writes to new keys aren't
+	coins := sdk.NewCoins(sdk.NewInt64Coin("stake", 1))
+	resp = finalizeAndCommit(t, app, 2,
+		[]byte("g=overwritten"),
+		NewSwapTx("a", "b"),
+		NewDeleteTx("a"),
+		NewBankTx("x", coins),
+		NewBankTx("x", coins),
+		[]byte("b=1"),
+	)
+	for i, code := range []uint32{ErrImmutableKey.ABCICode(), ErrImmutableKey.ABCICode(), ErrImmutableKey.ABCICode(), 0, ErrImmutableKey.ABCICode(), 0} {
+		require.Equal(t, code, resp.TxResults[i].Code, "tx %d: %s", i, resp.TxResults[i].Log)
+	}
+	require.Equal(t, []byte("genesis"), queryStore(t, app, "main", "g", 2).Value)
+	require.Equal(t, []byte("1"), queryStore(t, app, "main", "a", 2).Value)
+	require.Equal(t, []byte(coins.String()), queryStore(t, app, "main", BalanceKeyPrefix+"x", 2).Value)
+
+	// without the option keys can be rewritten
+	app = setupTestApp(t, nil)
+	resp = finalizeAndCommit(t, app, 1, []byte("a=1"), []byte("a=2"))
+	require.Equal(t, uint32(0), resp.TxResults[1].Code, resp.TxResults[1].Log)
+}
+
+func TestWriteOnceConflictMerge(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"sequential": {SetConflictMerge(concatMerge), SetWriteOnce(true)},
+		"concurrent": {SetConflictMerge(concatMerge), SetWriteOnce(true), SetConcurrentExecution(2), SetDeterminismCheck(true)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := setupTestApp(t, nil, opts...)
+
+			// the write of the first tx is only staged when the second runs,
+			// yet it already creates the key
+			resp := finalizeAndCommit(t, app, 1, []byte("k=a"), []byte("k=b"), []byte("l=c"))
+			require.Equal(t, uint32(0), resp.TxResults[0].Code, resp.TxResults[0].Log)
+			require.Equal(t, ErrImmutableKey.ABCICode(), resp.TxResults[1].Code, resp.TxResults[1].Log)
+			require.Equal(t, uint32(0), resp.TxResults[2].Code, resp.TxResults[2].Log)
+			require.Equal(t, []byte("a"), queryStore(t, app, "main", "k", 1).Value)
+			require.Equal(t, []byte("c"), queryStore(t, app, "main", "l", 1).Value)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package cachemulti
 import (
 	"fmt"
 	"io"
+	"sync"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	dbm "github.com/tendermint/tm-db"
@@ -25,6 +26,9 @@ type Store struct {
 	db     types.CacheKVStore
 	stores map[types.StoreKey]types.CacheWrap
 	keys   map[string]types.StoreKey
+	// order is the name order of the keys of stores, the order Write writes
+	// them in
+	order *storeOrder
 
 	traceWriter  io.Writer
 	traceContext types.TraceContext
@@ -35,6 +39,20 @@ type Store struct {
 
 var _ types.CacheMultiStore = Store{}
 
+// storeOrder sorts the keys of the stores of a Store by name the first time
+// they are needed. Branching a Store is on the path of every tx while sorting
+// is only needed once written, and the branches of a Store, which hold the
+// same stores, share its order so that it is sorted at most once.
+type storeOrder struct {
+	once sync.Once
+	keys []types.StoreKey
+}
+
+func (o *storeOrder) sorted(stores map[types.StoreKey]types.CacheWrap) []types.StoreKey {
+	o.once.Do(func() { o.keys = types.SortedStoreKeys(stores) })
+	return o.keys
+}
+
 // NewFromKVStore creates a new Store object from a mapping of store keys to
 // CacheWrapper objects and a KVStore as the database. Each CacheWrapper store
 // is a branched store.
@@ -54,6 +72,7 @@ func NewFromKVStore(
 		traceContext: traceContext,
 		listeners:    listeners,
 		closers:      []io.Closer{},
+		order:        &storeOrder{},
 	}
 
 	for key, store := range stores {
@@ -79,13 +98,18 @@ func NewStore(
 	return NewFromKVStore(dbadapter.Store{DB: db}, stores, keys, traceWriter, traceContext, listeners)
 }
 
+// newCacheMultiStoreFromCMS branches cms. The branch keeps the store keys and
+// the write order of cms, so StoreKeys lists the same stores however deeply it
+// is nested.
 func newCacheMultiStoreFromCMS(cms Store) Store {
 	stores := make(map[types.StoreKey]types.CacheWrapper)
 	for k, v := range cms.stores {
 		stores[k] = v
 	}
 
-	return NewFromKVStore(cms.db, stores, nil, cms.traceWriter, cms.traceContext, nil)
+	branch := NewFromKVStore(cms.db, stores, cms.keys, cms.traceWriter, cms.traceContext, nil)
+	branch.order = cms.order
+	return branch
 }
 
 // SetTracer sets the tracer for the MultiStore that the underlying
@@ -141,8 +165,10 @@ func (cms Store) GetStoreType() types.StoreType {
 // Write calls Write on each underlying store.
 func (cms Store) Write() {
 	cms.db.Write()
-	for _, store := range cms.stores {
-		store.Write()
+	// write in store name order, so tracers and listeners of the parent
+	// stores see the writes in the same order every time
+	for _, key := range cms.order.sorted(cms.stores) {
+		cms.stores[key].Write()
 	}
 }
 
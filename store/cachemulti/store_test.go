@@ -1,11 +1,14 @@
 package cachemulti
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
 	"github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
 )
 
 func TestStoreGetKVStore(t *testing.T) {
@@ -21,3 +24,80 @@ func TestStoreGetKVStore(t *testing.T) {
 	require.PanicsWithValue(errMsg,
 		func() { s.GetKVStore(key) })
 }
+
+func TestStoreKeysNested(t *testing.T) {
+	key := types.NewKVStoreKey("abc")
+	stores := map[types.StoreKey]types.CacheWrapper{key: dbadapter.Store{DB: dbm.NewMemDB()}}
+	s := NewFromKVStore(nil, stores, map[string]types.StoreKey{key.Name(): key}, nil, nil, nil)
+	require.Equal(t, []types.StoreKey{key}, s.StoreKeys())
+
+	// every level of branching keeps the keys, and the stores they name can
+	// still be reached through them
+	branch := s.CacheMultiStore()
+	nested := branch.CacheMultiStore()
+	require.Equal(t, []types.StoreKey{key}, nested.StoreKeys())
+	// and shares the write order, sorted once for all of them
+	require.Same(t, s.order, nested.(Store).order)
+	nested.GetKVStore(nested.StoreKeys()[0]).Set([]byte("a"), []byte("1"))
+	nested.Write()
+	require.True(t, branch.GetKVStore(key).Has([]byte("a")))
+	require.False(t, s.GetKVStore(key).Has([]byte("a")))
+	branch.Write()
+	require.True(t, s.GetKVStore(key).Has([]byte("a")))
+}
+
+func TestStoreWriteTraceOrder(t *testing.T) {
+	trace := func() string {
+		var buf bytes.Buffer
+		stores := map[types.StoreKey]types.CacheWrapper{}
+		for _, name := range []string{"c", "a", "d", "b"} {
+			stores[types.NewKVStoreKey(name)] = dbadapter.Store{DB: dbm.NewMemDB()}
+		}
+		s := NewFromKVStore(nil, stores, nil, &buf, nil, nil)
+		for key := range stores {
+			s.GetKVStore(key).Set([]byte(key.Name()), []byte("value"))
+		}
+		s.Write()
+		return buf.String()
+	}
+
+	// the stores are written in name order, whatever the map order
+	expected := trace()
+	require.Regexp(t, `(?s)"YQ==".*"Yg==".*"Yw==".*"ZA=="`, expected)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, expected, trace())
+	}
+}
+
+func BenchmarkCacheMultiStoreBranch(b *testing.B) {
+	stores := map[types.StoreKey]types.CacheWrapper{}
+	for i := 0; i < 20; i++ {
+		stores[types.NewKVStoreKey(fmt.Sprintf("store%d", i))] = dbadapter.Store{DB: dbm.NewMemDB()}
+	}
+	s := NewFromKVStore(nil, stores, nil, nil, nil, nil)
+	var key types.StoreKey
+	for key = range stores {
+		break
+	}
+
+	// a branch per tx, as the deliver state does, only some of which are
+	// written back
+	b.Run("branch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.CacheMultiStore()
+		}
+	})
+	b.Run("branch and write", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			branch := s.CacheMultiStore()
+			branch.GetKVStore(key).Set([]byte("a"), []byte("1"))
+			branch.Write()
+		}
+	})
+	// the cost of sorting the stores on every branch, for comparison
+	b.Run("sort", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			types.SortedStoreKeys(s.stores)
+		}
+	})
+}
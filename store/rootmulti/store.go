@@ -78,19 +78,6 @@ var (
 	_ types.Queryable        = (*Store)(nil)
 )
 
-// keysForStoreKeyMap returns a slice of keys for the provided map lexically sorted by StoreKey.Name()
-func keysForStoreKeyMap[V any](m map[types.StoreKey]V) []types.StoreKey {
-	keys := make([]types.StoreKey, 0, len(m))
-	for key := range m {
-		keys = append(keys, key)
-	}
-	sort.Slice(keys, func(i, j int) bool {
-		ki, kj := keys[i], keys[j]
-		return ki.Name() < kj.Name()
-	})
-	return keys
-}
-
 // NewStore returns a reference to a new Store object with the provided DB. The
 // store will be created with a PruneNothing pruning strategy by default. After
 // a store is created, KVStores must be mounted and finally LoadLatestVersion or
@@ -277,8 +264,8 @@ func (rs *Store) loadVersion(ver int64, upgrades *types.StoreUpgrades) error {
 		storeParams := rs.storesParams[key]
 		commitID := rs.getCommitID(infos, key.Name())
 
-		// If it has been added, set the initial version
-		if upgrades.IsAdded(key.Name()) {
+		// If it has been added or renamed, set the initial version
+		if upgrades.IsAdded(key.Name()) || upgrades.RenamedFrom(key.Name()) != "" {
 			storeParams.initialVersion = uint64(ver) + 1
 		}
 
@@ -302,6 +289,7 @@ func (rs *Store) loadVersion(ver int64, upgrades *types.StoreUpgrades) error {
 			oldKey := types.NewKVStoreKey(oldName)
 			oldParams := storeParams
 			oldParams.key = oldKey
+			oldParams.initialVersion = 0
 
 			// load from the old name
 			oldStore, err := rs.loadCommitStoreFromParams(oldKey, rs.getCommitID(infos, oldName), oldParams)
@@ -1013,7 +1001,7 @@ func (rs *Store) loadCommitStoreFromParams(key types.StoreKey, id types.CommitID
 }
 
 func (rs *Store) buildCommitInfo(version int64) *types.CommitInfo {
-	keys := keysForStoreKeyMap(rs.stores)
+	keys := types.SortedStoreKeys(rs.stores)
 	storeInfos := []types.StoreInfo{}
 	for _, key := range keys {
 		store := rs.stores[key]
@@ -1113,7 +1101,9 @@ func GetLatestVersion(db dbm.DB) int64 {
 func commitStores(version int64, storeMap map[types.StoreKey]types.CommitKVStore, bumpVersion bool) *types.CommitInfo {
 	storeInfos := make([]types.StoreInfo, 0, len(storeMap))
 
-	for key, store := range storeMap {
+	// commit in store name order, so that tracing is reproducible
+	for _, key := range types.SortedStoreKeys(storeMap) {
+		store := storeMap[key]
 		commitID := store.Commit(bumpVersion)
 
 		if store.GetStoreType() == types.StoreTypeTransient {
@@ -1228,7 +1218,7 @@ func (rs *Store) SetKVStores(handler func(key types.StoreKey, s types.KVStore) t
 }
 
 func (rs *Store) StoreKeys() []types.StoreKey {
-	res := make([]types.StoreKey, len(rs.keysByName))
+	res := make([]types.StoreKey, 0, len(rs.keysByName))
 	for _, sk := range rs.keysByName {
 		res = append(res, sk)
 	}
@@ -60,6 +60,15 @@ func TestStoreMount(t *testing.T) {
 	require.Panics(t, func() { store.MountStoreWithDB(dup1, types.StoreTypeIAVL, db) })
 }
 
+func TestStoreKeys(t *testing.T) {
+	var db dbm.DB = dbm.NewMemDB()
+	ms := newMultiStoreWithMounts(db, types.PruneNothing)
+	require.NoError(t, ms.LoadLatestVersion())
+
+	// one key per mounted store, none of them nil
+	require.ElementsMatch(t, []types.StoreKey{testStoreKey1, testStoreKey2, testStoreKey3}, ms.StoreKeys())
+}
+
 func TestCacheMultiStore(t *testing.T) {
 	var db dbm.DB = dbm.NewMemDB()
 	ms := newMultiStoreWithMounts(db, types.PruneNothing)
@@ -303,6 +312,32 @@ func TestMultistoreLoadWithUpgrade(t *testing.T) {
 	checkContains(t, ci.StoreInfos, []string{"store1", "restore2", "store4"})
 }
 
+func TestMultistoreLoadWithUpgradeInitialVersion(t *testing.T) {
+	var db dbm.DB = dbm.NewMemDB()
+	store := newMultiStoreWithMounts(db, types.PruneNothing)
+	require.NoError(t, store.LoadLatestVersion())
+
+	k2, v2 := []byte("second"), []byte("restore")
+	store.GetStoreByName("store2").(types.KVStore).Set(k2, v2)
+	for i := 0; i < 3; i++ {
+		store.Commit(true)
+	}
+
+	restore, upgrades := newMultiStoreWithModifiedMounts(db, types.PruneNothing)
+	require.NoError(t, restore.LoadLatestVersionAndUpgrade(upgrades))
+	commitID := restore.Commit(true)
+	require.Equal(t, int64(4), commitID.Version)
+
+	// both the added and the renamed store start at the version following
+	// the upgrade height, rather than at version 1
+	for _, name := range []string{"store4", "restore2"} {
+		s, ok := restore.GetStoreByName(name).(types.CommitKVStore)
+		require.True(t, ok, name)
+		require.Equal(t, commitID.Version, s.LastCommitID().Version, name)
+	}
+	require.Equal(t, v2, restore.GetStoreByName("restore2").(types.KVStore).Get(k2))
+}
+
 func TestParsePath(t *testing.T) {
 	_, _, err := parsePath("foo")
 	require.Error(t, err)
@@ -889,3 +924,36 @@ func TestStateListeners(t *testing.T) {
 	cacheMulti.Write()
 	require.Equal(t, 1, len(listener.stateCache))
 }
+
+// orderedCommitStore records the name of the store in commits when committed.
+type orderedCommitStore struct {
+	types.CommitKVStore
+	name    string
+	commits *[]string
+}
+
+func (s orderedCommitStore) Commit(bool) types.CommitID {
+	*s.commits = append(*s.commits, s.name)
+	return types.CommitID{}
+}
+
+func (s orderedCommitStore) GetStoreType() types.StoreType {
+	return types.StoreTypeIAVL
+}
+
+func TestCommitStoresOrder(t *testing.T) {
+	names := []string{"store3", "store1", "acc", "store2", "bank"}
+	for i := 0; i < 10; i++ {
+		var commits []string
+		storeMap := make(map[types.StoreKey]types.CommitKVStore)
+		for _, name := range names {
+			storeMap[types.NewKVStoreKey(name)] = orderedCommitStore{name: name, commits: &commits}
+		}
+
+		commitInfo := commitStores(1, storeMap, true)
+		require.Equal(t, []string{"acc", "bank", "store1", "store2", "store3"}, commits)
+		for i, storeInfo := range commitInfo.StoreInfos {
+			require.Equal(t, commits[i], storeInfo.Name)
+		}
+	}
+}
@@ -2,10 +2,23 @@ package types
 
 import (
 	"bytes"
+	"sort"
 
 	"github.com/cosmos/cosmos-sdk/types/kv"
 )
 
+// SortedStoreKeys returns the keys of m, sorted lexically by StoreKey.Name().
+func SortedStoreKeys[V any](m map[StoreKey]V) []StoreKey {
+	keys := make([]StoreKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name() < keys[j].Name()
+	})
+	return keys
+}
+
 // Iterator over all the keys with a certain prefix in ascending order
 func KVStorePrefixIterator(kvs KVStore, prefix []byte) Iterator {
 	return kvs.Iterator(prefix, PrefixEndBytes(prefix))
@@ -87,3 +87,15 @@ func TestInclusiveEndBytes(t *testing.T) {
 	bs := []byte("test")
 	require.True(t, bytes.Equal(append(bs, byte(0x00)), types.InclusiveEndBytes(bs)))
 }
+
+func TestSortedStoreKeys(t *testing.T) {
+	m := map[types.StoreKey]int{}
+	for _, name := range []string{"c", "a", "b"} {
+		m[types.NewKVStoreKey(name)] = 0
+	}
+	var names []string
+	for _, key := range types.SortedStoreKeys(m) {
+		names = append(names, key.Name())
+	}
+	require.Equal(t, []string{"a", "b", "c"}, names)
+}